@@ -1,6 +1,9 @@
 package main
 
-import "strconv"
+import (
+	"strconv"
+	"strings"
+)
 
 func (Bool) TypeName() string                  { return "bool" }
 func (Int8) TypeName() string                  { return "int8" }
@@ -8,7 +11,9 @@ func (Int16) TypeName() string                 { return "int16" }
 func (Int32) TypeName() string                 { return "int32" }
 func (Int64) TypeName() string                 { return "int64" }
 func (Float64) TypeName() string               { return "float64" }
+func (Uint16) TypeName() string                { return "uint16" }
 func (Uint32) TypeName() string                { return "uint32" }
+func (Uuid) TypeName() string                  { return "[16]byte" }
 func (Varint) TypeName() string                { return "int32" }
 func (Varlong) TypeName() string               { return "int64" }
 func (String) TypeName() string                { return "string" }
@@ -18,9 +23,15 @@ func (NullableBytes) TypeName() string         { return "[]byte" }
 func (VarintString) TypeName() string          { return "string" }
 func (VarintBytes) TypeName() string           { return "[]byte" }
 func (a Array) TypeName() string               { return "[]" + a.Inner.TypeName() }
-func (s Struct) TypeName() string              { return s.Name }
 func (FieldLengthMinusBytes) TypeName() string { return "[]byte" }
 
+func (s Struct) TypeName() string {
+	if s.Nullable {
+		return "*" + s.Name
+	}
+	return s.Name
+}
+
 // primAppend corresponds to the primitive append functions in
 // kmsg/primitives.go.
 func primAppend(name string, l *LineWriter) {
@@ -43,7 +54,9 @@ func (Int16) WriteAppend(l *LineWriter)        { primAppend("Int16", l) }
 func (Int32) WriteAppend(l *LineWriter)        { primAppend("Int32", l) }
 func (Int64) WriteAppend(l *LineWriter)        { primAppend("Int64", l) }
 func (Float64) WriteAppend(l *LineWriter)      { primAppend("Float64", l) }
+func (Uint16) WriteAppend(l *LineWriter)       { primAppend("Uint16", l) }
 func (Uint32) WriteAppend(l *LineWriter)       { primAppend("Uint32", l) }
+func (Uuid) WriteAppend(l *LineWriter)         { primAppend("Uuid", l) }
 func (Varint) WriteAppend(l *LineWriter)       { primAppend("Varint", l) }
 func (Varlong) WriteAppend(l *LineWriter)      { primAppend("Varlong", l) }
 func (VarintString) WriteAppend(l *LineWriter) { primAppend("VarintString", l) }
@@ -132,8 +145,19 @@ func (s Struct) WriteAppend(l *LineWriter) {
 			continue
 		}
 		// If the struct field is a struct itself, we avoid copying it
-		// and instead grab a pointer.
-		if _, isStruct := f.Type.(Struct); isStruct {
+		// and instead grab a pointer. Nullable structs are already
+		// pointers and are prefixed with an int8: -1 if null, 1 if not.
+		if inner, isStruct := f.Type.(Struct); isStruct && inner.Nullable {
+			l.Write("v := v.%s", f.FieldName)
+			l.Write("if v == nil {")
+			l.Write("dst = kbin.AppendInt8(dst, -1)")
+			l.Write("} else {")
+			l.Write("dst = kbin.AppendInt8(dst, 1)")
+			f.Type.WriteAppend(l)
+			l.Write("}")
+			l.Write("}")
+			continue
+		} else if isStruct {
 			l.Write("v := &v.%s", f.FieldName)
 		} else {
 			l.Write("v := v.%s", f.FieldName)
@@ -149,7 +173,7 @@ func (s Struct) WriteAppend(l *LineWriter) {
 	l.Write("if isFlexible {")
 	defer l.Write("}")
 
-	l.Write("dst = kbin.AppendUvarint(dst, %d)", len(tags))
+	l.Write("dst = kbin.AppendUvarint(dst, %d + uint32(v.UnknownTags.Len()))", len(tags))
 	for i := 0; i < len(tags); i++ {
 		f, exists := tags[i]
 		if !exists {
@@ -157,25 +181,45 @@ func (s Struct) WriteAppend(l *LineWriter) {
 		}
 
 		l.Write("{")
-		l.Write("v := v.%s", f.FieldName)
+		if _, isStruct := f.Type.(Struct); isStruct {
+			l.Write("v := &v.%s", f.FieldName)
+		} else {
+			l.Write("v := v.%s", f.FieldName)
+		}
 		l.Write("dst = kbin.AppendUvarint(dst, %d)", i) // tag num
 		switch f.Type.(type) {
 		case Bool, Int8:
 			l.Write("dst = kbin.AppendUvarint(dst, 1)") // size
-		case Int16:
+		case Int16, Uint16:
 			l.Write("dst = kbin.AppendUvarint(dst, 2)")
 		case Int32, Uint32:
 			l.Write("dst = kbin.AppendUvarint(dst, 4)")
 		case Int64, Float64:
 			l.Write("dst = kbin.AppendUvarint(dst, 8)")
+		case Uuid:
+			l.Write("dst = kbin.AppendUvarint(dst, 16)")
 		case Varint:
 			l.Write("dst = kbin.AppendUvarint(dst, kbin.VarintLen(v))")
 		default:
-			die("tag type %v unsupported in append! fix this!", f.Type)
+			// For variable sized types, we do not know the size
+			// until we encode, so we encode into a new slice and
+			// then append the size and the encoded value.
+			l.Write("tagDst := dst")
+			l.Write("dst = nil")
+			f.Type.WriteAppend(l)
+			l.Write("tagDst = kbin.AppendUvarint(tagDst, uint32(len(dst)))")
+			l.Write("dst = append(tagDst, dst...)")
+			l.Write("}")
+			continue
 		}
 		f.Type.WriteAppend(l)
 		l.Write("}")
 	}
+
+	// Unknown tags are always numbered after all known tags (any known tag
+	// would have been parsed into its field), so appending them after the
+	// known tags keeps tags in increasing order.
+	l.Write("dst = v.UnknownTags.AppendEach(dst)")
 }
 
 // writeBeginAndTag begins a struct field encode/decode and adds the field to
@@ -230,7 +274,9 @@ func (Int16) WriteDecode(l *LineWriter)        { primDecode("Int16", l) }
 func (Int32) WriteDecode(l *LineWriter)        { primDecode("Int32", l) }
 func (Int64) WriteDecode(l *LineWriter)        { primDecode("Int64", l) }
 func (Float64) WriteDecode(l *LineWriter)      { primDecode("Float64", l) }
+func (Uint16) WriteDecode(l *LineWriter)       { primDecode("Uint16", l) }
 func (Uint32) WriteDecode(l *LineWriter)       { primDecode("Uint32", l) }
+func (Uuid) WriteDecode(l *LineWriter)         { primDecode("Uuid", l) }
 func (Varint) WriteDecode(l *LineWriter)       { primDecode("Varint", l) }
 func (Varlong) WriteDecode(l *LineWriter)      { primDecode("Varlong", l) }
 func (VarintString) WriteDecode(l *LineWriter) { primDecode("VarintString", l) }
@@ -350,6 +396,18 @@ func (a Array) WriteDecode(l *LineWriter) {
 }
 
 func (f StructField) WriteDecode(l *LineWriter) {
+	if inner, isStruct := f.Type.(Struct); isStruct && inner.Nullable {
+		// A nullable struct is prefixed with an int8 that is negative
+		// if the struct is null. We decode into a new struct only if
+		// it is not null.
+		l.Write("var v *%s", inner.Name)
+		l.Write("if b.Int8() >= 0 {")
+		l.Write("v = new(%s)", inner.Name)
+		f.Type.WriteDecode(l)
+		l.Write("}")
+		l.Write("s.%s = v", f.FieldName)
+		return
+	}
 	switch f.Type.(type) {
 	case Struct:
 		// For decoding a nested struct, we copy a pointer out.
@@ -373,7 +431,7 @@ func (f StructField) WriteDecode(l *LineWriter) {
 }
 
 func (s Struct) WriteDecode(l *LineWriter) {
-	if len(s.Fields) == 0 {
+	if len(s.Fields) == 0 && !s.FromFlexible {
 		return
 	}
 	rangeFrom := s.Fields
@@ -406,23 +464,24 @@ func (s Struct) WriteDecode(l *LineWriter) {
 	}
 
 	l.Write("if isFlexible {")
-	if len(tags) == 0 {
-		l.Write("SkipTags(&b)")
-		l.Write("}")
-		return
+	defer l.Write("}")
+
+	// Kafka omits tagged fields that are at their default, so we
+	// default every tagged field before reading whichever are present.
+	for i := 0; i < len(tags); i++ {
+		tags[i].writeDefault(l, "s")
 	}
 
 	l.Write("for i := b.Uvarint(); i > 0; i-- {")
 	defer l.Write("}")
 
 	l.Write("tag, size := b.Uvarint(), int(b.Uvarint())")
-	defer l.Write("}")
 
 	l.Write("switch tag {")
 	defer l.Write("}")
 
 	l.Write("default:")
-	l.Write("b.Span(size)") // unknown tag
+	l.Write("s.UnknownTags.Set(tag, b.Span(size))")
 
 	for i := 0; i < len(tags); i++ {
 		f, exists := tags[i]
@@ -430,12 +489,6 @@ func (s Struct) WriteDecode(l *LineWriter) {
 			die("saw %d tags, but did not see tag %d; expected monotonically increasing", len(tags), i)
 		}
 
-		switch f.Type.(type) {
-		case Bool, Int8, Int16, Int32, Int64, Float64, Uint32, Varint:
-		default:
-			die("type %v unsupported in decode! fix this!", f.Type)
-		}
-
 		l.Write("case %d:", i)
 		l.Write("b := kbin.Reader{Src: b.Span(size)}")
 		f.WriteDecode(l)
@@ -472,11 +525,39 @@ func (s Struct) WriteDefn(l *LineWriter) {
 			}
 			versionTag += strconv.Itoa(f.Tag)
 		}
+		if f.Ignorable {
+			if versionTag == "" {
+				versionTag += " // ignorable"
+			} else {
+				versionTag += ", ignorable"
+			}
+		}
+		if f.Default != "" {
+			if versionTag == "" {
+				versionTag += " // default: "
+			} else {
+				versionTag += ", default: "
+			}
+			versionTag += f.Default
+		}
 		l.Write("%s %s%s", f.FieldName, f.Type.TypeName(), versionTag)
 		if i < len(s.Fields)-1 {
 			l.Write("") // blank between fields
 		}
 	}
+	// Structs with no encoding are inlined into other structs, which may
+	// be flexible, so they always need space for unknown tags.
+	if s.FromFlexible || s.WithNoEncoding {
+		if len(s.Fields) > 0 {
+			l.Write("")
+		}
+		l.Write("\t// UnknownTags are tags Kafka sent that we do not know the purpose of.")
+		if s.FlexibleAt >= 0 {
+			l.Write("UnknownTags Tags // v%d+", s.FlexibleAt)
+		} else {
+			l.Write("UnknownTags Tags")
+		}
+	}
 	l.Write("}")
 }
 
@@ -531,7 +612,18 @@ func (s Struct) WriteAppendFunc(l *LineWriter) {
 }
 
 func (s Struct) WriteDecodeFunc(l *LineWriter) {
-	l.Write("func (v *%s) ReadFrom(src []byte) error {", s.Name)
+	if s.TopLevel {
+		// Top level messages can be decoded from streaming readers;
+		// ReadFrom wraps the slice in a reader.
+		l.Write("func (v *%s) ReadFrom(src []byte) error {", s.Name)
+		l.Write("return v.ReadFromReader(&kbin.Reader{Src: src})")
+		l.Write("}")
+		l.Write("// ReadFromReader decodes %s from b, which can be a streaming reader", s.Name)
+		l.Write("// (see kbin.NewStreamReader).")
+		l.Write("func (v *%s) ReadFromReader(b *kbin.Reader) error {", s.Name)
+	} else {
+		l.Write("func (v *%s) ReadFrom(src []byte) error {", s.Name)
+	}
 	if s.TopLevel {
 		l.Write("version := v.Version")
 		l.Write("_ = version")
@@ -540,8 +632,165 @@ func (s Struct) WriteDecodeFunc(l *LineWriter) {
 		l.Write("isFlexible := version >= %d", s.FlexibleAt)
 		l.Write("_ = isFlexible")
 	}
-	l.Write("b := kbin.Reader{Src: src}")
+	if !s.TopLevel {
+		l.Write("b := kbin.Reader{Src: src}")
+	}
 	s.WriteDecode(l)
 	l.Write("return b.Complete()")
 	l.Write("}")
 }
+
+// WriteDefaultFunc writes a Default function that sets all fields that have
+// defaults to their default, recursing into nested non-array structs.
+func (s Struct) WriteDefaultFunc(l *LineWriter) {
+	l.Write("// Default sets any default fields. Calling this allows for future compatibility")
+	l.Write("// as new fields are added to %s.", s.Name)
+	l.Write("func (v *%s) Default() {", s.Name)
+	for _, f := range s.Fields {
+		f.writeDefault(l, "v")
+	}
+	l.Write("}")
+}
+
+// writeDefault writes setting the field on recv to its default, if it has
+// one.
+func (f StructField) writeDefault(l *LineWriter, recv string) {
+	if inner, isStruct := f.Type.(Struct); isStruct {
+		if !inner.Nullable { // nullable structs default to nil
+			l.Write("%s.%s.Default()", recv, f.FieldName)
+		}
+		return
+	}
+	if f.Default == "" {
+		return
+	}
+	switch f.Type.(type) {
+	case NullableString:
+		if f.Default == "nil" {
+			return
+		}
+		l.Write("{")
+		l.Write("s := %s", f.Default)
+		l.Write("%s.%s = &s", recv, f.FieldName)
+		l.Write("}")
+	default:
+		l.Write("%s.%s = %s", recv, f.FieldName, f.Default)
+	}
+}
+
+// WriteNewPtrFunc writes a NewPtrFoo function that returns a pointer to a
+// defaulted Foo.
+func (s Struct) WriteNewPtrFunc(l *LineWriter) {
+	l.Write("// NewPtr%s returns a pointer to a default %s.", s.Name, s.Name)
+	l.Write("// This is a shortcut for creating a new(struct) and calling Default yourself.")
+	l.Write("func NewPtr%s() *%s {", s.Name, s.Name)
+	l.Write("var v %s", s.Name)
+	l.Write("v.Default()")
+	l.Write("return &v")
+	l.Write("}")
+}
+
+// WriteNewFunc writes a NewFoo function that returns a defaulted Foo.
+func (s Struct) WriteNewFunc(l *LineWriter) {
+	l.Write("// New%s returns a default %s.", s.Name, s.Name)
+	l.Write("// This is a shortcut for creating a struct and calling Default yourself.")
+	l.Write("func New%s() %s {", s.Name, s.Name)
+	l.Write("var v %s", s.Name)
+	l.Write("v.Default()")
+	l.Write("return v")
+	l.Write("}")
+}
+
+// WriteJSONFuncs writes MarshalJSON and UnmarshalJSON functions, which only
+// encode and decode fields that are valid for the struct's version.
+func (s Struct) WriteJSONFuncs(l *LineWriter) {
+	flexible := "false"
+	if s.TopLevel {
+		flexible = "v.IsFlexible()"
+	}
+	l.Write("// MarshalJSON encodes %s as JSON, including only fields that are valid", s.Name)
+	l.Write("// for its version.")
+	l.Write("func (v *%s) MarshalJSON() ([]byte, error) { return marshalJSON(v, v.Version, %s) }", s.Name, flexible)
+	l.Write("// UnmarshalJSON decodes JSON into %s, reading the version first and", s.Name)
+	l.Write("// ignoring fields that are not valid for that version.")
+	l.Write("func (v *%s) UnmarshalJSON(b []byte) error { return unmarshalJSON(b, v) }", s.Name)
+}
+
+// WriteValidateFunc writes a Validate function, which checks that no set
+// field would be dropped when encoding the struct at its version.
+func (s Struct) WriteValidateFunc(l *LineWriter) {
+	flexible := "false"
+	if s.TopLevel {
+		flexible = "v.IsFlexible()"
+	}
+	l.Write("// Validate returns a *FieldVersionError if any field that is not its zero or")
+	l.Write("// default value would not be encoded at the current version.")
+	l.Write("func (v *%s) Validate() error { return validate(v, v.Version, %s) }", s.Name, flexible)
+}
+
+// WriteStructFields writes the field information of every struct, which is
+// used for JSON encoding and for Describe.
+func WriteStructFields(l *LineWriter, structs []Struct) {
+	l.Write("// structFields contains the name, versions, tag, whether the field is")
+	l.Write("// ignorable, and the first sentence of the documentation of every field in")
+	l.Write("// every struct, in order.")
+	l.Write("var structFields = map[string][]fieldInfo{")
+	for _, s := range structs {
+		l.Write("%q: {", s.Name)
+		for _, f := range s.Fields {
+			if s.WithVersionField && f.FieldName == "Version" {
+				continue // encoded specially
+			}
+			l.Write("{%q, %d, %d, %d, %t, %q},", f.FieldName, f.MinVersion, f.MaxVersion, f.Tag, f.Ignorable, firstSentence(f.Comment))
+		}
+		l.Write("},")
+	}
+	l.Write("}")
+}
+
+// firstSentence returns the first sentence of a // comment.
+func firstSentence(comment string) string {
+	var words []string
+	for _, line := range strings.Split(comment, "\n") {
+		words = append(words, strings.Fields(strings.TrimPrefix(line, "//"))...)
+	}
+	joined := strings.Join(words, " ")
+	if idx := strings.Index(joined, ". "); idx >= 0 {
+		return joined[:idx+1]
+	}
+	return joined
+}
+
+// WriteRoundTripTests writes a test for every request and response that
+// round trips random values through AppendTo and ReadFrom at every version.
+func WriteRoundTripTests(l *LineWriter, structs []Struct) {
+	l.Write("package kmsg")
+	l.Write(`import "testing"`)
+	l.Write("// Code generated by kafka-go/generate. DO NOT EDIT.\n")
+	for _, s := range structs {
+		if !s.TopLevel {
+			continue
+		}
+		l.Write("func TestRoundTrip%[1]s(t *testing.T) { testRoundTrip(t, func() message { return new(%[1]s) }) }", s.Name)
+	}
+}
+
+// WriteFuzzTests writes a fuzz test for the ReadFrom of every request and
+// response, as well as for reading record batches and message sets. Native
+// fuzzing requires Go 1.18, so the file is guarded with a build constraint.
+func WriteFuzzTests(l *LineWriter, structs []Struct) {
+	l.Write("//go:build go1.18")
+	l.Write("// +build go1.18\n")
+	l.Write("package kmsg")
+	l.Write(`import "testing"`)
+	l.Write("// Code generated by kafka-go/generate. DO NOT EDIT.\n")
+	for _, s := range structs {
+		if !s.TopLevel {
+			continue
+		}
+		l.Write("func Fuzz%[1]s(f *testing.F) { fuzzReadFrom(f, func() message { return new(%[1]s) }) }", s.Name)
+	}
+	for _, fn := range []string{"ReadRecordBatches", "ReadV1Messages", "ReadV0Messages"} {
+		l.Write("func Fuzz%[1]s(f *testing.F) { fuzzRead(f, func(b []byte) { %[1]s(b) }) }", fn)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -33,7 +34,9 @@ type (
 	Int32        struct{}
 	Int64        struct{}
 	Float64      struct{}
+	Uint16       struct{}
 	Uint32       struct{}
+	Uuid         struct{}
 	Varint       struct{}
 	Varlong      struct{}
 	VarintString struct{}
@@ -73,6 +76,14 @@ type (
 		Tag        int
 		FieldName  string
 		Type       Type
+
+		// Ignorable is whether the field can be dropped at versions
+		// that do not support it (Kafka's "ignorable").
+		Ignorable bool
+
+		// Default is the field's default value, if any, as a Go
+		// literal.
+		Default string
 	}
 
 	Struct struct {
@@ -80,6 +91,7 @@ type (
 		WithVersionField bool // if not top level
 		WithNoEncoding   bool // if not top level
 		Anonymous        bool // if inner struct
+		Nullable         bool // if a nullable inner struct field
 		Comment          string
 		Name             string
 
@@ -87,6 +99,9 @@ type (
 		// (a) top level and has flexible versions, or
 		// (b) nested in a top level struct that has flexible versions
 		FromFlexible bool
+		// FlexibleAt is the version that the top level struct this
+		// struct is in becomes flexible, or -1 if it never does.
+		FlexibleAt int
 
 		Fields []StructField
 
@@ -96,7 +111,6 @@ type (
 		TxnCoordinator   bool
 		Key              int
 		MaxVersion       int
-		FlexibleAt       int
 		ResponseKind     string // for requests
 		RequestKind      string // for responses
 	}
@@ -119,14 +133,32 @@ func (l *LineWriter) Write(line string, args ...interface{}) {
 }
 
 //go:generate sh -c "go run . | gofmt > ../pkg/kmsg/generated.go"
+//go:generate sh -c "go run . -tests | gofmt > ../pkg/kmsg/generated_test.go"
+//go:generate sh -c "go run . -fuzz | gofmt > ../pkg/kmsg/generated_fuzz_test.go"
 func main() {
+	tests := flag.Bool("tests", false, "generate round trip tests rather than messages")
+	fuzz := flag.Bool("fuzz", false, "generate fuzz tests rather than messages")
+	flag.Parse()
+
 	f, err := ioutil.ReadFile("DEFINITIONS")
 	if err != nil {
 		die("unable to read DEFINITIONS file: %v", err)
 	}
 	Parse(f)
+	ParseJSONDir("json")
 
 	l := &LineWriter{bytes.NewBuffer(make([]byte, 0, 300<<10))}
+	switch {
+	case *tests:
+		WriteRoundTripTests(l, newStructs)
+		fmt.Println(l.buf.String())
+		return
+	case *fuzz:
+		WriteFuzzTests(l, newStructs)
+		fmt.Println(l.buf.String())
+		return
+	}
+
 	l.Write("package kmsg")
 	l.Write(`import "github.com/twmb/kafka-go/pkg/kbin"`)
 	l.Write("// Code generated by kafka-go/generate. DO NOT EDIT.\n")
@@ -139,6 +171,9 @@ func main() {
 
 	for _, s := range newStructs {
 		s.WriteDefn(l)
+		s.WriteDefaultFunc(l)
+		s.WriteNewFunc(l)
+		s.WriteNewPtrFunc(l)
 		if s.TopLevel {
 			if s.ResponseKind != "" {
 				name2structs = append(name2structs, s)
@@ -167,12 +202,20 @@ func main() {
 			l.Write("") // newline before append/decode func
 			s.WriteAppendFunc(l)
 			s.WriteDecodeFunc(l)
+			s.WriteJSONFuncs(l)
+			s.WriteValidateFunc(l)
 		} else if !s.Anonymous && !s.WithNoEncoding {
 			s.WriteAppendFunc(l)
 			s.WriteDecodeFunc(l)
+			if s.WithVersionField {
+				s.WriteJSONFuncs(l)
+				s.WriteValidateFunc(l)
+			}
 		}
 	}
 
+	WriteStructFields(l, newStructs)
+
 	l.Write("// RequestForKey returns the request corresponding to the given request key")
 	l.Write("// or nil if the key is unknown.")
 	l.Write("func RequestForKey(key int16) Request {")
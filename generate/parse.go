@@ -1,8 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -20,7 +24,9 @@ var types = map[string]Type{
 	"int32":           Int32{},
 	"int64":           Int64{},
 	"float64":         Float64{},
+	"uint16":          Uint16{},
 	"uint32":          Uint32{},
+	"uuid":            Uuid{},
 	"varint":          Varint{},
 	"varlong":         Varlong{},
 	"string":          String{},
@@ -89,7 +95,7 @@ func (s *Struct) BuildFrom(scanner *LineScanner, level int) (done bool) {
 		}
 
 		// Fields are name on left, type on right.
-		fields := strings.Split(line, ": ")
+		fields := strings.SplitN(line, ": ", 2)
 		if len(fields) != 2 || len(fields[0]) == 0 || len(fields[1]) == 0 {
 			die("improper struct field format on line %q (%d)", line, scanner.lineno)
 		}
@@ -105,7 +111,7 @@ func (s *Struct) BuildFrom(scanner *LineScanner, level int) (done bool) {
 		typ := fields[1]
 
 		if idx := strings.Index(typ, " // "); idx >= 0 {
-			f.MinVersion, f.MaxVersion, f.Tag, err = parseFieldComment(typ[idx:])
+			f.MinVersion, f.MaxVersion, f.Tag, f.Ignorable, f.Default, err = parseFieldComment(typ[idx:])
 			if err != nil {
 				die("unable to parse field comment on line %q: %v", line, err)
 			}
@@ -150,8 +156,20 @@ func (s *Struct) BuildFrom(scanner *LineScanner, level int) (done bool) {
 		}
 
 		switch {
+		case strings.HasPrefix(typ, "nullable=>"): // nullable nested struct; recurse
+			if isArray || f.Tag >= 0 {
+				die("nullable structs are only supported as untagged non-array fields, line %q", line)
+			}
+			newS := Struct{FromFlexible: s.FromFlexible, FlexibleAt: s.FlexibleAt}
+			newS.Name = s.Name + f.FieldName
+			newS.Anonymous = true
+			done = newS.BuildFrom(scanner, level+1)
+			newStructs = append(newStructs, newS)
+			newS.Nullable = true
+			f.Type = newS
+
 		case strings.HasPrefix(typ, "=>"): // nested struct; recurse
-			newS := Struct{FromFlexible: s.FromFlexible}
+			newS := Struct{FromFlexible: s.FromFlexible, FlexibleAt: s.FlexibleAt}
 			newS.Name = s.Name + f.FieldName
 			newS.Anonymous = true
 			if isArray {
@@ -227,31 +245,35 @@ func (s *Struct) BuildFrom(scanner *LineScanner, level int) (done bool) {
 // 2: max version, if versioned, if exists
 // 3: tag, if versioned, if exists
 // 4: tag, if not versioned
-var fieldRe = regexp.MustCompile(`^ // (?:v(\d+)(?:\+|\-v(\d+))(?:, tag (\d+))?|tag (\d+))$`)
+// 5: "ignorable", if exists
+// 6: default, if exists
+var fieldRe = regexp.MustCompile(`^ // (?:(?:v(\d+)(?:\+|\-v(\d+))(?:, tag (\d+))?|tag (\d+))(?:, )?)?(?:(ignorable)(?:, )?)?(?:default: (.+))?$`)
 
-func parseFieldComment(in string) (int, int, int, error) {
+func parseFieldComment(in string) (min, max, tag int, ignorable bool, def string, err error) {
 	match := fieldRe.FindStringSubmatch(in)
-	if len(match) == 0 {
-		return 0, 0, 0, fmt.Errorf("invalid field comment %q", in)
+	if len(match) == 0 || match[0] == " // " {
+		return 0, 0, 0, false, "", fmt.Errorf("invalid field comment %q", in)
 	}
+	ignorable = match[5] != ""
+	def = match[6]
 
 	if match[4] != "" { // not versioned
 		tag, _ := strconv.Atoi(match[4])
-		return -1, -1, tag, nil
+		return -1, -1, tag, ignorable, def, nil
 	}
 
-	min, _ := strconv.Atoi(match[1])
-	max, _ := strconv.Atoi(match[2])
-	tag, _ := strconv.Atoi(match[3])
+	min, _ = strconv.Atoi(match[1])
+	max, _ = strconv.Atoi(match[2])
+	tag, _ = strconv.Atoi(match[3])
 	if match[2] == "" {
 		max = -1
 	} else if max < min {
-		return 0, 0, 0, fmt.Errorf("min %d > max %d on line %q", min, max, in)
+		return 0, 0, 0, false, "", fmt.Errorf("min %d > max %d on line %q", min, max, in)
 	}
 	if match[3] == "" {
 		tag = -1
 	}
-	return min, max, tag, nil
+	return min, max, tag, ignorable, def, nil
 }
 
 func parseFieldLength(in string) (string, int, error) {
@@ -424,3 +446,401 @@ func Parse(raw []byte) {
 		save()
 	}
 }
+
+// jsonMessage is the subset of Kafka's JSON message schema format (from
+// clients/src/main/resources/common/message) that we understand.
+type jsonMessage struct {
+	APIKey           int          `json:"apiKey"`
+	Type             string       `json:"type"`
+	Name             string       `json:"name"`
+	ValidVersions    string       `json:"validVersions"`
+	FlexibleVersions string       `json:"flexibleVersions"`
+	Fields           []jsonField  `json:"fields"`
+	CommonStructs    []jsonStruct `json:"commonStructs"`
+}
+
+type jsonStruct struct {
+	Name   string      `json:"name"`
+	Fields []jsonField `json:"fields"`
+}
+
+type jsonField struct {
+	Name             string          `json:"name"`
+	Type             string          `json:"type"`
+	Versions         string          `json:"versions"`
+	NullableVersions string          `json:"nullableVersions"`
+	TaggedVersions   string          `json:"taggedVersions"`
+	Tag              *int            `json:"tag"`
+	Default          json.RawMessage `json:"default"`
+	Ignorable        bool            `json:"ignorable"`
+	About            string          `json:"about"`
+	Fields           []jsonField     `json:"fields"`
+}
+
+// jsonRouting is where requests parsed from JSON must be issued. Kafka's JSON
+// schemas do not specify this, so we track it here; requests not in this map
+// can be issued to any broker.
+var jsonRouting = map[int]string{
+	50: "admin", // DescribeUserSCRAMCredentials
+	51: "admin", // AlterUserSCRAMCredentials
+	55: "admin", // DescribeQuorum
+	56: "admin", // AlterISR
+	57: "admin", // UpdateFeatures
+	58: "admin", // Envelope
+	62: "admin", // BrokerRegistration
+	63: "admin", // BrokerHeartbeat
+}
+
+// ParseJSONDir parses every request and response pair of Kafka JSON message
+// schemas in dir and adds all newly parsed structs to newStructs.
+//
+// Requests whose key is already defined in DEFINITIONS are skipped: the
+// hand written definitions have better documentation, and we only want JSON
+// for messages that we have not yet written by hand.
+func ParseJSONDir(dir string) {
+	reqs, err := filepath.Glob(filepath.Join(dir, "*Request.json"))
+	if err != nil {
+		die("unable to glob json dir: %v", err)
+	}
+	sort.Strings(reqs)
+
+	defined := make(map[int]bool)
+	for _, s := range newStructs {
+		if s.TopLevel && s.ResponseKind != "" {
+			defined[s.Key] = true
+		}
+	}
+
+	var msgs []jsonMessage
+	for _, req := range reqs {
+		resp := strings.TrimSuffix(req, "Request.json") + "Response.json"
+		reqMsg, respMsg := readJSONMessage(req), readJSONMessage(resp)
+		if reqMsg.Type != "request" || respMsg.Type != "response" {
+			die("json pair %s and %s are not a request and response", req, resp)
+		}
+		if reqMsg.APIKey != respMsg.APIKey {
+			die("json pair %s and %s have different api keys", req, resp)
+		}
+		if defined[reqMsg.APIKey] {
+			continue
+		}
+		msgs = append(msgs, reqMsg, respMsg)
+	}
+
+	// We sort by key so that generated messages are in key order, as they
+	// are in DEFINITIONS.
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].APIKey < msgs[j].APIKey })
+	for i := 0; i < len(msgs); i += 2 {
+		ParseJSON(msgs[i], msgs[i+1])
+	}
+}
+
+// readJSONMessage reads a Kafka JSON message schema, stripping the //
+// comments that Kafka's "JSON" files contain.
+func readJSONMessage(path string) jsonMessage {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		die("unable to read json file: %v", err)
+	}
+	var stripped []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "//") {
+			continue
+		}
+		stripped = append(stripped, line)
+	}
+	var m jsonMessage
+	if err := json.Unmarshal([]byte(strings.Join(stripped, "\n")), &m); err != nil {
+		die("unable to parse json file %s: %v", path, err)
+	}
+	return m
+}
+
+// ParseJSON converts a request and response parsed from Kafka JSON message
+// schemas into our Struct model, adding all newly parsed structs to
+// newStructs.
+func ParseJSON(req, resp jsonMessage) {
+	_, max, ok := parseJSONVersions(req.ValidVersions)
+	if !ok {
+		die("invalid validVersions %q in %s", req.ValidVersions, req.Name)
+	}
+	flexibleAt, _, flexible := parseJSONVersions(req.FlexibleVersions)
+	if !flexible {
+		flexibleAt = -1
+	}
+
+	reqS := Struct{
+		TopLevel:     true,
+		Comment:      fmt.Sprintf("// %s is the request for API key %d.", goName(req.Name), req.APIKey),
+		Name:         goName(req.Name),
+		FromFlexible: flexible,
+		FlexibleAt:   flexibleAt,
+		Key:          req.APIKey,
+		MaxVersion:   max,
+	}
+	switch routing := jsonRouting[req.APIKey]; routing {
+	case "":
+	case "admin":
+		reqS.Admin = true
+	case "group coordinator":
+		reqS.GroupCoordinator = true
+	case "txn coordinator":
+		reqS.TxnCoordinator = true
+	default:
+		die("unknown json routing %q", routing)
+	}
+
+	respS := reqS
+	respS.Admin, respS.GroupCoordinator, respS.TxnCoordinator = false, false, false
+	respS.Comment = fmt.Sprintf("// %s is a response to a %s.", goName(resp.Name), reqS.Name)
+	respS.Name = goName(resp.Name)
+	reqS.ResponseKind = respS.Name
+	respS.RequestKind = reqS.Name
+
+	if reqS.Key > maxKey {
+		maxKey = reqS.Key
+	}
+
+	for _, m := range []struct {
+		msg jsonMessage
+		s   *Struct
+	}{
+		{req, &reqS},
+		{resp, &respS},
+	} {
+		common := make(map[string][]jsonField)
+		for _, c := range m.msg.CommonStructs {
+			common[c.Name] = c.Fields
+		}
+		m.s.Fields = buildJSONFields(m.s, m.msg.Fields, common)
+		types[m.s.Name] = *m.s
+		newStructs = append(newStructs, *m.s)
+	}
+}
+
+// buildJSONFields converts json fields into struct fields for s, adding any
+// nested structs to newStructs.
+func buildJSONFields(s *Struct, fields []jsonField, common map[string][]jsonField) []StructField {
+	var sfs []StructField
+	for _, jf := range fields {
+		f := StructField{
+			Comment:   jsonComment(goName(jf.Name), jf.About),
+			FieldName: goName(jf.Name),
+			Tag:       -1,
+			Ignorable: jf.Ignorable,
+		}
+
+		if jf.Tag != nil {
+			// Tagged fields are only encoded in the tag section.
+			f.MinVersion, f.MaxVersion, f.Tag = -1, -1, *jf.Tag
+		} else {
+			min, max, ok := parseJSONVersions(jf.Versions)
+			if !ok {
+				continue // field is never encoded
+			}
+			if max == s.MaxVersion { // bounded by the message max; not truly bounded
+				max = -1
+			}
+			f.MinVersion, f.MaxVersion = min, max
+		}
+
+		nullableAt, _, nullable := parseJSONVersions(jf.NullableVersions)
+		if nullableAt <= f.MinVersion {
+			nullableAt = 0
+		}
+
+		typ := jf.Type
+		isArray := strings.HasPrefix(typ, "[]")
+		typ = strings.TrimPrefix(typ, "[]")
+
+		innerFields := jf.Fields
+		if innerFields == nil {
+			innerFields = common[typ]
+		}
+
+		if innerFields != nil {
+			inner := Struct{
+				Anonymous:    true,
+				FromFlexible: s.FromFlexible,
+				FlexibleAt:   s.FlexibleAt,
+				MaxVersion:   s.MaxVersion,
+				Name:         s.Name + f.FieldName,
+			}
+			if isArray {
+				inner.Name = strings.TrimSuffix(inner.Name, "s") // make plural singular
+			}
+			inner.Fields = buildJSONFields(&inner, innerFields, common)
+			newStructs = append(newStructs, inner)
+			f.Type = inner
+		} else {
+			f.Type = jsonType(typ, !isArray && nullable, nullableAt, s.FromFlexible)
+		}
+
+		if isArray {
+			f.Type = Array{
+				Inner:           f.Type,
+				IsNullableArray: nullable,
+				NullableVersion: nullableAt,
+				FromFlexible:    s.FromFlexible,
+			}
+		}
+
+		if len(jf.Default) > 0 {
+			f.Default = jsonDefault(jf.Default, f.Type)
+		}
+
+		sfs = append(sfs, f)
+	}
+	return sfs
+}
+
+// jsonType returns our type for a primitive json type.
+func jsonType(typ string, nullable bool, nullableAt int, fromFlexible bool) Type {
+	var t Type
+	switch typ {
+	case "bool", "int8", "int16", "int32", "int64", "float64", "uint16", "uint32", "uuid":
+		t = types[typ]
+	case "string":
+		if nullable {
+			t = NullableString{NullableVersion: nullableAt}
+		} else {
+			t = String{}
+		}
+	case "bytes":
+		if nullable {
+			t = NullableBytes{}
+		} else {
+			t = Bytes{}
+		}
+	case "records":
+		t = NullableBytes{}
+	default:
+		die("unsupported json type %q", typ)
+	}
+	if fromFlexible {
+		if setter, ok := t.(FlexibleSetter); ok {
+			t = setter.AsFromFlexible()
+		}
+	}
+	return t
+}
+
+// parseJSONVersions parses a json versions string ("none", "3", "3+", or
+// "3-5"), returning the min and max version; max is -1 if unbounded. This
+// returns false if the versions are "none" or empty.
+func parseJSONVersions(in string) (min, max int, ok bool) {
+	if in == "" || in == "none" {
+		return 0, 0, false
+	}
+	var err error
+	switch {
+	case strings.HasSuffix(in, "+"):
+		min, err = strconv.Atoi(in[:len(in)-1])
+		max = -1
+	case strings.Contains(in, "-"):
+		lr := strings.SplitN(in, "-", 2)
+		if min, err = strconv.Atoi(lr[0]); err == nil {
+			max, err = strconv.Atoi(lr[1])
+		}
+	default:
+		min, err = strconv.Atoi(in)
+		max = min
+	}
+	if err != nil {
+		die("invalid json versions %q: %v", in, err)
+	}
+	return min, max, true
+}
+
+// jsonDefault converts a json default, which can be a string or a bare json
+// value, into a Go literal for the field's type. Defaults for arrays and
+// structs are not supported and are ignored.
+func jsonDefault(raw json.RawMessage, typ Type) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		s = string(raw) // number or bool
+	}
+	switch typ.(type) {
+	case Array, Struct, Bytes, NullableBytes:
+		return ""
+	case String:
+		return strconv.Quote(s)
+	case NullableString:
+		if s == "null" {
+			return "nil"
+		}
+		return strconv.Quote(s)
+	}
+	if s == "" {
+		return ""
+	}
+	return s
+}
+
+// jsonNameWords are words in Kafka's json names that we spell differently.
+var jsonNameWords = map[string]string{
+	"Id":    "ID",
+	"Ids":   "IDs",
+	"Ms":    "Millis",
+	"Acl":   "ACL",
+	"Acls":  "ACLs",
+	"Isr":   "ISR",
+	"Sasl":  "SASL",
+	"Scram": "SCRAM",
+}
+
+// goName converts a Kafka json name into the naming style of this package:
+// acronyms are capitalized and millisecond times end in Millis.
+func goName(in string) string {
+	var words []string
+	start := 0
+	for i := 1; i < len(in); i++ {
+		if in[i] >= 'A' && in[i] <= 'Z' && !(in[i-1] >= 'A' && in[i-1] <= 'Z') {
+			words = append(words, in[start:i])
+			start = i
+		}
+	}
+	words = append(words, in[start:])
+
+	var out strings.Builder
+	for i, word := range words {
+		if i == 0 {
+			word = strings.ToUpper(word[:1]) + word[1:] // timeoutMs => TimeoutMillis
+		}
+		if word == "Time" && i+1 < len(words) && words[i+1] == "Ms" {
+			continue // ThrottleTimeMs => ThrottleMillis
+		}
+		if replace, ok := jsonNameWords[word]; ok {
+			word = replace
+		}
+		out.WriteString(word)
+	}
+	return out.String()
+}
+
+// jsonComment converts a json about into a field comment, turning "The foo."
+// into "Name is the foo." to match DEFINITIONS.
+func jsonComment(name, about string) string {
+	if about == "" {
+		return ""
+	}
+	if strings.HasPrefix(about, "The ") {
+		about = name + " is the " + about[len("The "):]
+	}
+
+	const width = 74
+	var lines []string
+	var line strings.Builder
+	for _, word := range strings.Fields(about) {
+		if line.Len() > 0 && line.Len()+1+len(word) > width {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(word)
+	}
+	lines = append(lines, line.String())
+	return "// " + strings.Join(lines, "\n// ")
+}
@@ -0,0 +1,253 @@
+// Package compress implements the codecs that Kafka uses to compress record
+// batches and message sets. This is shared by kgo, which compresses and
+// decompresses records while producing and consuming, and kmsg, which builds
+// and reads record batches for tooling.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+)
+
+// Codec is a compression codec, as encoded in the low three bits of batch or
+// message attributes.
+type Codec int8
+
+const (
+	None   Codec = 0
+	Gzip   Codec = 1
+	Snappy Codec = 2
+	Lz4    Codec = 3
+	Zstd   Codec = 4
+)
+
+// ErrUnknownCodec is returned when compressing or decompressing with a codec
+// that Kafka does not define.
+var ErrUnknownCodec = errors.New("unknown compression codec")
+
+// sliceWriter appends to a slice as an io.Writer.
+type sliceWriter struct{ inner []byte }
+
+func (s *sliceWriter) Write(p []byte) (int, error) {
+	s.inner = append(s.inner, p...)
+	return len(p), nil
+}
+
+// Compressor compresses with one codec, pooling encoders so that it can be
+// used concurrently.
+type Compressor struct {
+	codec    Codec
+	gzPool   sync.Pool
+	lz4Pool  sync.Pool
+	zstdPool sync.Pool
+}
+
+type zstdEncoder struct {
+	inner *zstd.Encoder
+}
+
+// NewCompressor returns a compressor for codec at the given level. Invalid
+// levels are replaced with the codec's default level.
+func NewCompressor(codec Codec, level int8) (*Compressor, error) {
+	c := &Compressor{codec: codec}
+	switch codec {
+	case None, Snappy:
+	case Gzip:
+		if _, err := gzip.NewWriterLevel(nil, int(level)); err != nil {
+			level = gzip.DefaultCompression
+		}
+		c.gzPool = sync.Pool{New: func() interface{} { c, _ := gzip.NewWriterLevel(nil, int(level)); return c }}
+	case Lz4:
+		if level < 0 {
+			level = 0
+		}
+		c.lz4Pool = sync.Pool{New: func() interface{} { w := new(lz4.Writer); w.Header.CompressionLevel = int(level); return w }}
+	case Zstd:
+		zlevel := zstd.EncoderLevel(level)
+		c.zstdPool = sync.Pool{
+			New: func() interface{} {
+				zstdEnc, err := zstd.NewWriter(nil,
+					zstd.WithEncoderLevel(zlevel),
+					zstd.WithEncoderConcurrency(1))
+				if err != nil {
+					zstdEnc, _ = zstd.NewWriter(nil,
+						zstd.WithEncoderConcurrency(1))
+				}
+				r := &zstdEncoder{zstdEnc}
+				runtime.SetFinalizer(r, func(r *zstdEncoder) {
+					r.inner.Close()
+				})
+				return r
+			},
+		}
+	default:
+		return nil, ErrUnknownCodec
+	}
+	return c, nil
+}
+
+// Codec returns the codec this compresses with.
+func (c *Compressor) Codec() Codec { return c.codec }
+
+// CompressTo compresses src into dst, which is grown if necessary, and returns
+// the compressed slice. With no compression, this returns src.
+func (c *Compressor) CompressTo(dst, src []byte) ([]byte, error) {
+	switch c.codec {
+	case None:
+		return src, nil
+	case Gzip:
+		gz := c.gzPool.Get().(*gzip.Writer)
+		defer c.gzPool.Put(gz)
+		w := &sliceWriter{dst[:0]}
+		gz.Reset(w)
+		if _, err := gz.Write(src); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		return w.inner, nil
+	case Snappy:
+		return snappy.Encode(dst[:cap(dst)], src), nil
+	case Lz4:
+		lz := c.lz4Pool.Get().(*lz4.Writer)
+		defer c.lz4Pool.Put(lz)
+		w := &sliceWriter{dst[:0]}
+		lz.Reset(w)
+		if _, err := lz.Write(src); err != nil {
+			return nil, err
+		}
+		if err := lz.Close(); err != nil {
+			return nil, err
+		}
+		return w.inner, nil
+	case Zstd:
+		zstdEnc := c.zstdPool.Get().(*zstdEncoder)
+		defer c.zstdPool.Put(zstdEnc)
+		return zstdEnc.inner.EncodeAll(src, dst[:0]), nil
+	default:
+		return nil, ErrUnknownCodec
+	}
+}
+
+// Decompressor decompresses any codec, pooling decoders so that it can be
+// used concurrently.
+type Decompressor struct {
+	ungzPool   sync.Pool
+	unlz4Pool  sync.Pool
+	unzstdPool sync.Pool
+}
+
+type zstdDecoder struct {
+	inner *zstd.Decoder
+}
+
+// NewDecompressor returns a new decompressor.
+func NewDecompressor() *Decompressor {
+	return &Decompressor{
+		ungzPool: sync.Pool{
+			New: func() interface{} { return new(gzip.Reader) },
+		},
+		unlz4Pool: sync.Pool{
+			New: func() interface{} { return new(lz4.Reader) },
+		},
+		unzstdPool: sync.Pool{
+			New: func() interface{} {
+				zstdDec, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+				r := &zstdDecoder{zstdDec}
+				runtime.SetFinalizer(r, func(r *zstdDecoder) {
+					r.inner.Close()
+				})
+				return r
+			},
+		},
+	}
+}
+
+// Decompress returns src decompressed with codec into a newly allocated
+// slice. With no compression, this returns src.
+func (d *Decompressor) Decompress(src []byte, codec Codec) ([]byte, error) {
+	return d.DecompressTo(nil, src, codec)
+}
+
+// DecompressTo decompresses src into dst, which is grown if necessary, and
+// returns the decompressed slice. If dst is nil, a new slice is allocated.
+func (d *Decompressor) DecompressTo(dst, src []byte, codec Codec) ([]byte, error) {
+	switch codec {
+	case None:
+		return src, nil
+	case Gzip:
+		ungz := d.ungzPool.Get().(*gzip.Reader)
+		defer d.ungzPool.Put(ungz)
+		if err := ungz.Reset(bytes.NewReader(src)); err != nil {
+			return nil, err
+		}
+		return readAllTo(dst, ungz)
+	case Snappy:
+		if len(src) > 16 && bytes.HasPrefix(src, xerialPfx) {
+			return xerialDecode(src)
+		}
+		return snappy.Decode(dst[:cap(dst)], src)
+	case Lz4:
+		unlz4 := d.unlz4Pool.Get().(*lz4.Reader)
+		defer d.unlz4Pool.Put(unlz4)
+		unlz4.Reset(bytes.NewReader(src))
+		return readAllTo(dst, unlz4)
+	case Zstd:
+		unzstd := d.unzstdPool.Get().(*zstdDecoder)
+		defer d.unzstdPool.Put(unzstd)
+		return unzstd.inner.DecodeAll(src, dst[:0])
+	default:
+		return nil, ErrUnknownCodec
+	}
+}
+
+// readAllTo reads all of r into dst, returning the grown dst.
+func readAllTo(dst []byte, r io.Reader) ([]byte, error) {
+	if dst == nil {
+		return ioutil.ReadAll(r)
+	}
+	w := &sliceWriter{dst[:0]}
+	_, err := io.Copy(w, r)
+	return w.inner, err
+}
+
+var xerialPfx = []byte{130, 83, 78, 65, 80, 80, 89, 0}
+
+var errMalformedXerial = errors.New("malformed xerial framing")
+
+func xerialDecode(src []byte) ([]byte, error) {
+	// bytes 0-8: xerial header
+	// bytes 8-16: xerial version
+	// everything after: uint32 chunk size, snappy chunk
+	// we come into this function knowing src is at least 16
+	src = src[16:]
+	var dst, chunk []byte
+	var err error
+	for len(src) > 0 {
+		if len(src) < 4 {
+			return nil, errMalformedXerial
+		}
+		size := int32(binary.BigEndian.Uint32(src))
+		src = src[4:]
+		if size < 0 || len(src) < int(size) {
+			return nil, errMalformedXerial
+		}
+		if chunk, err = snappy.Decode(chunk[:cap(chunk)], src[:size]); err != nil {
+			return nil, err
+		}
+		src = src[size:]
+		dst = append(dst, chunk...)
+	}
+	return dst, nil
+}
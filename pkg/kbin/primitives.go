@@ -4,6 +4,8 @@ package kbin
 import (
 	"encoding/binary"
 	"errors"
+	"io"
+	"io/ioutil"
 	"math"
 	"math/bits"
 )
@@ -57,11 +59,21 @@ func appendUint64(dst []byte, u uint64) []byte {
 		byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
 }
 
+// AppendUint16 appends a big endian uint16 to dst.
+func AppendUint16(dst []byte, u uint16) []byte {
+	return append(dst, byte(u>>8), byte(u))
+}
+
 // AppendUint32 appends a big endian uint32 to dst.
 func AppendUint32(dst []byte, u uint32) []byte {
 	return append(dst, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
 }
 
+// AppendUuid appends the 16 uuid bytes to dst.
+func AppendUuid(dst []byte, uuid [16]byte) []byte {
+	return append(dst, uuid[:]...)
+}
+
 // uvarintLens could only be length 65, but using 256 allows bounds check
 // elimination on lookup.
 var uvarintLens = [256]byte{
@@ -318,13 +330,112 @@ func AppendCompactNullableArrayLen(dst []byte, l int, isNil bool) []byte {
 type Reader struct {
 	Src []byte
 	bad bool
+
+	// If stream is non-nil, Src is refilled from stream whenever it does
+	// not contain enough data. streamLeft is the number of bytes of the
+	// message that have not yet been read from stream.
+	stream     io.Reader
+	streamLeft int
+	streamErr  error
+}
+
+// streamChunk is the minimum amount a streaming reader reads at once.
+const streamChunk = 32 << 10
+
+// NewStreamReader returns a Reader that decodes a message of size bytes from
+// r, reading from r only as data is needed. Rather than requiring the entire
+// message in memory, the reader buffers at most what the value currently
+// being decoded needs or 32KiB, whichever is larger.
+//
+// Byte slices returned from a streaming reader are never overwritten; they
+// alias buffers that are not reused once the reader moves past them.
+//
+// If decoding stops before all size bytes are read, such as when a message
+// has fields that are not understood, use Drain to discard the remainder.
+func NewStreamReader(r io.Reader, size int) *Reader {
+	return &Reader{
+		stream:     r,
+		streamLeft: size,
+	}
+}
+
+// left returns how many bytes remain in the message.
+func (b *Reader) left() int {
+	return len(b.Src) + b.streamLeft
+}
+
+// need ensures that Src contains at least n bytes, reading from the stream if
+// necessary, and invalidates the reader if it cannot.
+func (b *Reader) need(n int) bool {
+	if len(b.Src) >= n {
+		return true
+	}
+	if b.fill(n) {
+		return true
+	}
+	b.bad = true
+	b.Src = nil
+	return false
+}
+
+// fillUpTo ensures that Src contains at least n bytes, or everything that is
+// left in the message if fewer remain. This is used for variable length
+// numbers, which can be shorter than their max length.
+func (b *Reader) fillUpTo(n int) {
+	if len(b.Src) >= n || b.stream == nil {
+		return
+	}
+	if left := b.left(); left < n {
+		n = left
+	}
+	b.fill(n)
+}
+
+// fill reads from the stream until Src contains at least n bytes. The data
+// left in Src is copied to a new buffer, since prior spans alias the old one.
+func (b *Reader) fill(n int) bool {
+	if b.stream == nil || b.bad || n > b.left() {
+		return false
+	}
+	size := n
+	if size < streamChunk {
+		size = streamChunk
+	}
+	if left := b.left(); size > left {
+		size = left
+	}
+	buf := make([]byte, size)
+	have := copy(buf, b.Src)
+	read, err := io.ReadFull(b.stream, buf[have:])
+	b.streamLeft -= read
+	if err != nil {
+		b.streamErr = err
+		b.stream = nil
+		return false
+	}
+	b.Src = buf
+	return true
+}
+
+// Drain discards anything left in a streaming reader's message so that the
+// underlying io.Reader is positioned at the end of the message. This returns
+// an error only if reading from the stream failed.
+func (b *Reader) Drain() error {
+	b.Src = nil
+	if b.stream != nil && b.streamLeft > 0 {
+		n, err := io.CopyN(ioutil.Discard, b.stream, int64(b.streamLeft))
+		b.streamLeft -= int(n)
+		if err != nil {
+			b.streamErr = err
+			b.stream = nil
+		}
+	}
+	return b.streamErr
 }
 
 // Bool returns a bool from the reader.
 func (b *Reader) Bool() bool {
-	if len(b.Src) < 1 {
-		b.bad = true
-		b.Src = nil
+	if !b.need(1) {
 		return false
 	}
 	t := b.Src[0] != 0 // if '0', false
@@ -334,9 +445,7 @@ func (b *Reader) Bool() bool {
 
 // Int8 returns an int8 from the reader.
 func (b *Reader) Int8() int8 {
-	if len(b.Src) < 1 {
-		b.bad = true
-		b.Src = nil
+	if !b.need(1) {
 		return 0
 	}
 	r := b.Src[0]
@@ -346,9 +455,7 @@ func (b *Reader) Int8() int8 {
 
 // Int16 returns an int16 from the reader.
 func (b *Reader) Int16() int16 {
-	if len(b.Src) < 2 {
-		b.bad = true
-		b.Src = nil
+	if !b.need(2) {
 		return 0
 	}
 	r := int16(binary.BigEndian.Uint16(b.Src))
@@ -358,9 +465,7 @@ func (b *Reader) Int16() int16 {
 
 // Int32 returns an int32 from the reader.
 func (b *Reader) Int32() int32 {
-	if len(b.Src) < 4 {
-		b.bad = true
-		b.Src = nil
+	if !b.need(4) {
 		return 0
 	}
 	r := int32(binary.BigEndian.Uint32(b.Src))
@@ -379,9 +484,7 @@ func (b *Reader) Float64() float64 {
 }
 
 func (b *Reader) readUint64() uint64 {
-	if len(b.Src) < 8 {
-		b.bad = true
-		b.Src = nil
+	if !b.need(8) {
 		return 0
 	}
 	r := binary.BigEndian.Uint64(b.Src)
@@ -389,11 +492,19 @@ func (b *Reader) readUint64() uint64 {
 	return r
 }
 
+// Uint16 returns a uint16 from the reader.
+func (b *Reader) Uint16() uint16 {
+	if !b.need(2) {
+		return 0
+	}
+	r := binary.BigEndian.Uint16(b.Src)
+	b.Src = b.Src[2:]
+	return r
+}
+
 // Uint32 returns a uint32 from the reader.
 func (b *Reader) Uint32() uint32 {
-	if len(b.Src) < 4 {
-		b.bad = true
-		b.Src = nil
+	if !b.need(4) {
 		return 0
 	}
 	r := binary.BigEndian.Uint32(b.Src)
@@ -401,8 +512,20 @@ func (b *Reader) Uint32() uint32 {
 	return r
 }
 
+// Uuid returns a uuid from the reader.
+func (b *Reader) Uuid() [16]byte {
+	var r [16]byte
+	if !b.need(16) {
+		return r
+	}
+	copy(r[:], b.Src)
+	b.Src = b.Src[16:]
+	return r
+}
+
 // Varint returns a varint int32 from the reader.
 func (b *Reader) Varint() int32 {
+	b.fillUpTo(5)
 	val, n := Varint(b.Src)
 	if n <= 0 {
 		b.bad = true
@@ -415,6 +538,7 @@ func (b *Reader) Varint() int32 {
 
 // Uvarint returns a uvarint encoded uint32 from the reader.
 func (b *Reader) Uvarint() uint32 {
+	b.fillUpTo(5)
 	val, n := Uvarint(b.Src)
 	if n <= 0 {
 		b.bad = true
@@ -427,7 +551,7 @@ func (b *Reader) Uvarint() uint32 {
 
 // Span returns l bytes from the reader.
 func (b *Reader) Span(l int) []byte {
-	if len(b.Src) < l || l < 0 {
+	if l < 0 || !b.need(l) {
 		b.bad = true
 		b.Src = nil
 		return nil
@@ -513,7 +637,7 @@ func (b *Reader) ArrayLen() int32 {
 	r := b.Int32()
 	// The min size of a Kafka type is a byte, so if we do not have
 	// at least the array length of bytes left, it is bad.
-	if len(b.Src) < int(r) {
+	if b.left() < int(r) {
 		b.bad = true
 		b.Src = nil
 		return 0
@@ -526,7 +650,7 @@ func (b *Reader) VarintArrayLen() int32 {
 	r := b.Varint()
 	// The min size of a Kafka type is a byte, so if we do not have
 	// at least the array length of bytes left, it is bad.
-	if len(b.Src) < int(r) {
+	if b.left() < int(r) {
 		b.bad = true
 		b.Src = nil
 		return 0
@@ -539,7 +663,7 @@ func (b *Reader) CompactArrayLen() int32 {
 	r := int32(b.Uvarint()) - 1
 	// The min size of a Kafka type is a byte, so if we do not have
 	// at least the array length of bytes left, it is bad.
-	if len(b.Src) < int(r) {
+	if b.left() < int(r) {
 		b.bad = true
 		b.Src = nil
 		return 0
@@ -562,8 +686,12 @@ func (b *Reader) VarintString() string {
 	return string(b.VarintBytes())
 }
 
-// Complete returns ErrNotEnoughData if the source ran out while decoding.
+// Complete returns ErrNotEnoughData if the source ran out while decoding, or
+// the error from reading a streaming reader's stream if that failed.
 func (b *Reader) Complete() error {
+	if b.streamErr != nil {
+		return b.streamErr
+	}
 	if b.bad {
 		return ErrNotEnoughData
 	}
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"testing"
+	"testing/iotest"
 	"testing/quick"
 )
 
@@ -47,3 +48,74 @@ func BenchmarkUvarint(b *testing.B) {
 		})
 	}
 }
+
+func TestStreamReader(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), 3*streamChunk)
+
+	var in []byte
+	in = AppendInt16(in, 7)
+	in = AppendVarint(in, -300)
+	in = AppendString(in, "foo")
+	in = AppendBytes(in, big)
+	in = AppendArrayLen(in, 1)
+	in = AppendInt64(in, 1<<40)
+	in = AppendUvarint(in, 5) // last, short varint
+	trailing := append(in, "trailing"...)
+
+	r := bytes.NewReader(trailing)
+	b := NewStreamReader(iotest.OneByteReader(r), len(in))
+	if got := b.Int16(); got != 7 {
+		t.Errorf("got int16 %d != exp 7", got)
+	}
+	if got := b.Varint(); got != -300 {
+		t.Errorf("got varint %d != exp -300", got)
+	}
+	if got := b.String(); got != "foo" {
+		t.Errorf("got string %q != exp foo", got)
+	}
+	span := b.Bytes()
+	if got := b.ArrayLen(); got != 1 {
+		t.Errorf("got array len %d != exp 1", got)
+	}
+	if got := b.Int64(); got != 1<<40 {
+		t.Errorf("got int64 %d != exp 1<<40", got)
+	}
+	if got := b.Uvarint(); got != 5 {
+		t.Errorf("got uvarint %d != exp 5", got)
+	}
+	if !bytes.Equal(span, big) {
+		t.Error("span was modified by later reads")
+	}
+	if err := b.Complete(); err != nil {
+		t.Errorf("unexpected complete err: %v", err)
+	}
+	if rest, _ := r.ReadByte(); rest != 't' {
+		t.Errorf("stream read past the end of the message")
+	}
+
+	// A message that declares more than it has is bad, and an array
+	// longer than what is left is bad without reading the stream.
+	b = NewStreamReader(bytes.NewReader(in[:10]), len(in))
+	b.Int16()
+	b.Varint()
+	_ = b.String()
+	b.Bytes()
+	if err := b.Complete(); err == nil {
+		t.Error("expected err reading from a short stream")
+	}
+	b = NewStreamReader(bytes.NewReader(AppendArrayLen(nil, 100)), 4)
+	if b.ArrayLen(); b.Ok() {
+		t.Error("expected array longer than the message to be bad")
+	}
+
+	// Drain positions the stream at the end of the message.
+	r = bytes.NewReader(trailing)
+	b = NewStreamReader(r, len(in))
+	b.Int16()
+	if err := b.Drain(); err != nil {
+		t.Errorf("unexpected drain err: %v", err)
+	}
+	if rest, _ := r.ReadByte(); rest != 't' {
+		t.Errorf("drain did not discard the rest of the message")
+	}
+}
@@ -0,0 +1,47 @@
+package kerr
+
+import "errors"
+
+// class is a bitmask of the categories a Kafka error belongs to. Errors can
+// belong to many categories, or to none (0).
+type class uint8
+
+const (
+	classAuth class = 1 << iota
+	classStaleMetadata
+	classTxnFatal
+	classProducerFenced
+	classCoordinatorMoved
+)
+
+// is returns whether the first Kafka error in err's chain is in class c.
+func is(err error, c class) bool {
+	var kerr *Error
+	return errors.As(err, &kerr) && kerr.class&c != 0
+}
+
+// IsAuthError returns whether err is an authentication or authorization
+// error. These are fatal until permissions or credentials change; retrying
+// the same request will fail the same way.
+func IsAuthError(err error) bool { return is(err, classAuth) }
+
+// IsStaleMetadata returns whether err indicates that the client's metadata
+// is out of date, such as a partition's leader moving. Metadata should be
+// refreshed, after which the request can be retried, possibly against a
+// different broker.
+func IsStaleMetadata(err error) bool { return is(err, classStaleMetadata) }
+
+// IsTxnFatal returns whether err is fatal to a transactional producer. The
+// producer cannot abort and continue; it must be closed and recreated.
+func IsTxnFatal(err error) bool { return is(err, classTxnFatal) }
+
+// IsProducerFenced returns whether err indicates that a newer producer with
+// the same transactional ID has fenced this producer. Kafka returns
+// INVALID_PRODUCER_EPOCH for this before 2.7.0, and PRODUCER_FENCED after.
+func IsProducerFenced(err error) bool { return is(err, classProducerFenced) }
+
+// IsCoordinatorMoved returns whether err indicates that a group or
+// transaction coordinator is no longer on the broker a request was issued
+// to. The coordinator should be looked up again, after which the request can
+// be retried.
+func IsCoordinatorMoved(err error) bool { return is(err, classCoordinatorMoved) }
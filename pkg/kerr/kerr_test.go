@@ -0,0 +1,51 @@
+package kerr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsRetriable(t *testing.T) {
+	for _, test := range []struct {
+		err error
+		exp bool
+	}{
+		{NotLeaderForPartition, true},
+		{fmt.Errorf("produce failed: %w", NotLeaderForPartition), true},
+		{fmt.Errorf("produce failed: %v", NotLeaderForPartition), false},
+		{OffsetOutOfRange, false},
+		{errors.New("foo"), false},
+		{nil, false},
+	} {
+		if got := IsRetriable(test.err); got != test.exp {
+			t.Errorf("%v: got retriable %v != exp %v", test.err, got, test.exp)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		fn   func(error) bool
+		yes  []error
+		no   []error
+	}{
+		{"auth", IsAuthError, []error{TopicAuthorizationFailed, SaslAuthenticationFailed}, []error{NotLeaderForPartition}},
+		{"stale", IsStaleMetadata, []error{NotLeaderForPartition, FencedLeaderEpoch}, []error{NotCoordinator}},
+		{"txn fatal", IsTxnFatal, []error{ProducerFenced, TransactionalIDAuthorizationFailed}, []error{ConcurrentTransactions}},
+		{"fenced", IsProducerFenced, []error{InvalidProducerEpoch, ProducerFenced}, []error{FencedInstanceID}},
+		{"coordinator", IsCoordinatorMoved, []error{NotCoordinator, CoordinatorNotAvailable}, []error{CoordinatorLoadInProgress}},
+	} {
+		for _, err := range test.yes {
+			if !test.fn(err) || !test.fn(fmt.Errorf("wrapped: %w", err)) {
+				t.Errorf("%s: %v not classified", test.name, err)
+			}
+		}
+		for _, err := range append(test.no, nil, errors.New("foo")) {
+			if test.fn(err) {
+				t.Errorf("%s: %v unexpectedly classified", test.name, err)
+			}
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"math"
 	"net"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -57,6 +58,7 @@ type waitingResp struct {
 	resp    kmsg.Response
 	promise func(kmsg.Response, error)
 	err     error
+	ready   bool
 }
 
 // broker manages the concept how a client would interact with a broker.
@@ -67,15 +69,31 @@ type broker struct {
 	id   int32
 	addr string
 
+	// meta is id and addr split for request interceptors.
+	meta BrokerMetadata
+
 	// The cxn fields each manage a single tcp connection to one broker.
 	// Each field is managed serially in handleReqs. This means that only
 	// one write can happen at a time, regardless of which connection the
 	// write goes to, but the write is expected to be fast whereas the wait
 	// for the response is expected to be slow.
 	//
-	// Produce requests go to cxnProduce, fetch to cxnFetch, and all others
-	// to cxnNormal.
-	cxnNormal  *brokerCxn
+	// Produce requests go to cxnProduce, fetch to cxnFetch, join and sync
+	// group to cxnGroup, and all others to one of the cxnNormals.
+	//
+	// Kafka handles requests on a single connection one at a time, so a
+	// slow request blocks every request behind it on the same connection.
+	// Join and sync can take up to the group rebalance timeout, so we
+	// isolate them. The normal connections are a pool sized by the
+	// NormalConnsPerBroker option; we spread requests across the pool by
+	// choosing the connection with the fewest requests in flight.
+	//
+	// Rather than always opening a connection for join and sync, idle
+	// connections move between the normal pool and cxnGroup as needed
+	// (see loadGroupConnection), so that an extra connection is only
+	// opened while a join or sync overlaps with normal requests.
+	cxnNormals []*brokerCxn
+	cxnGroup   *brokerCxn
 	cxnProduce *brokerCxn
 	cxnFetch   *brokerCxn
 
@@ -90,9 +108,11 @@ type broker struct {
 
 	// seqResps, guarded by seqRespsMu, contains responses that must be
 	// handled sequentially. These responses are handled asyncronously,
-	// but sequentially.
-	seqRespsMu sync.Mutex
-	seqResps   []waitingResp
+	// but sequentially. seqHandling is true while a goroutine is handling
+	// ready responses.
+	seqRespsMu  sync.Mutex
+	seqResps    []*waitingResp
+	seqHandling bool
 
 	// dieMu guards sending to reqs in case the broker has been
 	// permanently stopped.
@@ -101,6 +121,16 @@ type broker struct {
 	reqs chan promisedReq
 	// dead is an atomic so a backed up reqs cannot block broker stoppage.
 	dead int32
+
+	// versions, if the broker supports ApiVersions, is the
+	// kversion.Versions the broker reported on the most recent connection
+	// we opened.
+	versions atomic.Value
+
+	// failing is an atomic that is 1 if our most recent attempt to open a
+	// connection failed. If every broker is failing, the client falls
+	// back to its seeds.
+	failing int32
 }
 
 const unknownControllerID = -1
@@ -112,6 +142,16 @@ func unknownSeedID(seedNum int) int32 {
 	return int32(math.MinInt32 + seedNum)
 }
 
+func brokerMetadata(addr string, id int32) BrokerMetadata {
+	host, port, _ := net.SplitHostPort(addr) // addrs are always joined with JoinHostPort
+	port32, _ := strconv.ParseInt(port, 10, 32)
+	return BrokerMetadata{
+		NodeID: id,
+		Host:   host,
+		Port:   int32(port32),
+	}
+}
+
 func (cl *Client) newBroker(addr string, id int32) *broker {
 	br := &broker{
 		cl: cl,
@@ -119,6 +159,10 @@ func (cl *Client) newBroker(addr string, id int32) *broker {
 		id:   id,
 		addr: addr,
 
+		meta: brokerMetadata(addr, id),
+
+		cxnNormals: make([]*brokerCxn, cl.cfg.normalConnsPerBroker),
+
 		reqs: make(chan promisedReq, 10),
 	}
 	br.sink = newSink(cl, br)
@@ -149,14 +193,40 @@ func (b *broker) stopForever() {
 	close(b.reqs)
 }
 
+// BrokerMetadata is metadata for a broker that a request is issued to.
+//
+// Seed brokers, which are used before the client knows the real IDs of any
+// broker, have a negative NodeID.
+type BrokerMetadata struct {
+	NodeID int32
+	Host   string
+	Port   int32
+}
+
 // do issues a request to the broker, eventually calling the response
 // once a the request either fails or is responded to (with failure or not).
 //
+// If the client has request interceptors, the request is issued through them.
+//
 // The promise will block broker processing.
 func (b *broker) do(
 	ctx context.Context,
 	req kmsg.Request,
 	promise func(kmsg.Response, error),
+) {
+	if len(b.cl.cfg.interceptors) > 0 {
+		b.doIntercepted(ctx, req, promise)
+		return
+	}
+	b.enqueue(ctx, req, promise)
+}
+
+// enqueue sends a request to handleReqs, or fails the promise if the broker
+// is dead.
+func (b *broker) enqueue(
+	ctx context.Context,
+	req kmsg.Request,
+	promise func(kmsg.Response, error),
 ) {
 	dead := false
 
@@ -173,40 +243,98 @@ func (b *broker) do(
 	}
 }
 
+// doIntercepted runs the request through the client's interceptor chain in a
+// new goroutine, with the innermost next enqueueing the request.
+//
+// Interceptors are synchronous, but do is not. To keep requests ordered (which
+// matters for produce requests), we do not return until the request is
+// enqueued or until the chain returns without calling next.
+func (b *broker) doIntercepted(
+	ctx context.Context,
+	req kmsg.Request,
+	promise func(kmsg.Response, error),
+) {
+	var (
+		enqueued   = make(chan struct{})
+		signalOnce sync.Once
+		signal     = func() { signalOnce.Do(func() { close(enqueued) }) }
+	)
+
+	next := func() (kmsg.Response, error) {
+		var resp kmsg.Response
+		var err error
+		done := make(chan struct{})
+		b.enqueue(ctx, req, func(kresp kmsg.Response, kerr error) {
+			resp, err = kresp, kerr
+			close(done)
+		})
+		signal()
+		<-done
+		return resp, err
+	}
+
+	interceptors := b.cl.cfg.interceptors
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		fn, inner := interceptors[i], next
+		next = func() (kmsg.Response, error) {
+			return fn(ctx, b.meta, req, inner)
+		}
+	}
+
+	go func() {
+		resp, err := next()
+		signal()
+		promise(resp, err)
+	}()
+	<-enqueued
+}
+
 // doSequencedAsyncPromise is the same as do, but all requests using this
-// function have their responses handled sequentially.
+// function have their responses handled sequentially, in the order the
+// requests were issued.
 //
 // This is important for example for ordering of produce requests.
 //
-// Note that the requests may finish out of order (e.g. dead connection kills
+// Note that the requests may fail out of order (e.g. dead connection kills
 // latter request); this is handled appropriately in producing.
 func (b *broker) doSequencedAsyncPromise(
 	ctx context.Context,
 	req kmsg.Request,
 	promise func(kmsg.Response, error),
 ) {
+	wr := &waitingResp{promise: promise}
+	b.seqRespsMu.Lock()
+	b.seqResps = append(b.seqResps, wr)
+	b.seqRespsMu.Unlock()
+
 	b.do(ctx, req, func(resp kmsg.Response, err error) {
 		b.seqRespsMu.Lock()
-		b.seqResps = append(b.seqResps, waitingResp{resp, promise, err})
-		if len(b.seqResps) == 1 {
-			go b.handleSeqResp(b.seqResps[0])
+		wr.resp, wr.err, wr.ready = resp, err, true
+		start := !b.seqHandling && b.seqResps[0].ready
+		if start {
+			b.seqHandling = true
 		}
 		b.seqRespsMu.Unlock()
+
+		if start {
+			go b.handleSeqResps()
+		}
 	})
 }
 
-// handleSeqResp handles a sequenced response while there is one.
-func (b *broker) handleSeqResp(wr waitingResp) {
-more:
-	wr.promise(wr.resp, wr.err)
-
+// handleSeqResps handles sequenced responses while the oldest is ready.
+func (b *broker) handleSeqResps() {
 	b.seqRespsMu.Lock()
-	b.seqResps = b.seqResps[1:]
-	if len(b.seqResps) > 0 {
-		wr = b.seqResps[0]
+	for len(b.seqResps) > 0 && b.seqResps[0].ready {
+		wr := b.seqResps[0]
+		b.seqResps = b.seqResps[1:]
 		b.seqRespsMu.Unlock()
-		goto more
+
+		wr.promise(wr.resp, wr.err)
+
+		b.seqRespsMu.Lock()
 	}
+	b.seqHandling = false
 	b.seqRespsMu.Unlock()
 }
 
@@ -232,12 +360,34 @@ func (b *broker) waitResp(ctx context.Context, req kmsg.Request) (kmsg.Response,
 // If any of these steps fail, the promise is called with the relevant error.
 func (b *broker) handleReqs() {
 	defer func() {
-		b.cxnNormal.die()
+		for _, cxn := range b.cxnNormals {
+			cxn.die()
+		}
+		b.cxnGroup.die()
 		b.cxnProduce.die()
 		b.cxnFetch.die()
 	}()
 
-	for pr := range b.reqs {
+	var reap <-chan time.Time
+	if idle := b.cl.cfg.connIdleTimeout; idle > 0 {
+		ticker := time.NewTicker(idle / 2)
+		defer ticker.Stop()
+		reap = ticker.C
+	}
+
+	for {
+		var pr promisedReq
+		var ok bool
+		select {
+		case <-reap:
+			b.reapConnections()
+			continue
+		case pr, ok = <-b.reqs:
+		}
+		if !ok {
+			return
+		}
+
 		req := pr.req
 		cxn, err := b.loadConnection(pr.ctx, req.Key())
 		if err != nil {
@@ -254,10 +404,18 @@ func (b *broker) handleReqs() {
 		ourMax := req.MaxVersion()
 		if b.cl.cfg.maxVersions != nil {
 			userMax := b.cl.cfg.maxVersions[req.Key()]
+			if userMax < 0 {
+				pr.promise(nil, ErrUnknownRequestKey)
+				continue
+			}
 			if userMax < ourMax {
 				ourMax = userMax
 			}
 		}
+		if ourMax, ok = b.cl.pinClusterVersion(req.Key(), ourMax); !ok {
+			pr.promise(nil, ErrUnknownRequestKey)
+			continue
+		}
 
 		// If brokerMax is negative, we have no api versions because
 		// the client is pinned pre 0.10.0 and we stick with our max.
@@ -291,6 +449,18 @@ func (b *broker) handleReqs() {
 		default:
 		}
 
+		// With StrictRequestVersions, we validate only requests
+		// that users issue; requests we build ourselves may set
+		// fields that older brokers safely ignore.
+		if b.cl.cfg.strictRequestVersions && isUserRequest(pr.ctx, req) {
+			if v, ok := req.(interface{ Validate() error }); ok {
+				if err := v.Validate(); err != nil {
+					pr.promise(nil, err)
+					continue
+				}
+			}
+		}
+
 		corrID, err := cxn.writeRequest(req)
 		if err != nil {
 			pr.promise(nil, err)
@@ -309,6 +479,33 @@ func (b *broker) handleReqs() {
 	}
 }
 
+// reapConnections, called serially in handleReqs, closes any connection that
+// has no requests in flight and that has not been used within the client's
+// ConnIdleTimeout. Reaped connections are reopened in loadConnection the next
+// time they are needed.
+//
+// Since this runs in handleReqs, nothing can be written to a connection while
+// we are checking it.
+func (b *broker) reapConnections() {
+	idle := b.cl.cfg.connIdleTimeout
+	reap := func(cxn *brokerCxn) {
+		if cxn == nil ||
+			atomic.LoadInt32(&cxn.dead) == 1 ||
+			cxn.numInflight() > 0 ||
+			time.Since(cxn.lastActive()) < idle {
+			return
+		}
+		b.cl.cfg.logger.Log(LogLevelDebug, "reaping idle connection", "broker", b.id, "addr", b.addr)
+		cxn.die()
+	}
+	for _, cxn := range b.cxnNormals {
+		reap(cxn)
+	}
+	reap(b.cxnGroup)
+	reap(b.cxnProduce)
+	reap(b.cxnFetch)
+}
+
 // bufPool is used to reuse issued-request buffers across writes to brokers.
 type bufPool struct{ p *sync.Pool }
 
@@ -324,17 +521,113 @@ func (p bufPool) put(b []byte) { p.p.Put(&b) }
 // loadConection returns the broker's connection, creating it if necessary
 // and returning an error of if that fails.
 func (b *broker) loadConnection(ctx context.Context, reqKey int16) (*brokerCxn, error) {
-	pcxn := &b.cxnNormal
-	if reqKey == 0 {
+	var pcxn **brokerCxn
+	switch reqKey {
+	case 0:
 		pcxn = &b.cxnProduce
-	} else if reqKey == 1 {
+	case 1:
 		pcxn = &b.cxnFetch
+	case 11, 14: // join group, sync group
+		return b.loadGroupConnection(ctx)
+	default:
+		return b.loadNormalConnection(ctx)
 	}
 
 	if *pcxn != nil && atomic.LoadInt32(&(*pcxn).dead) == 0 {
 		return *pcxn, nil
 	}
 
+	cxn, err := b.newCxn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	*pcxn = cxn
+	return cxn, nil
+}
+
+// loadNormalConnection returns the least loaded connection in the normal
+// connection pool.
+//
+// If every open connection has requests in flight and the pool has room, this
+// opens a new connection. If opening fails but we have an existing usable
+// connection, we use the existing connection rather than failing the request.
+func (b *broker) loadNormalConnection(ctx context.Context) (*brokerCxn, error) {
+	var best *brokerCxn
+	open := -1
+	for i, cxn := range b.cxnNormals {
+		if cxn == nil || atomic.LoadInt32(&cxn.dead) == 1 {
+			if open < 0 {
+				open = i
+			}
+			continue
+		}
+		if best == nil || cxn.numInflight() < best.numInflight() {
+			best = cxn
+		}
+	}
+	if best != nil && (open < 0 || best.numInflight() == 0) {
+		return best, nil
+	}
+
+	// Before opening a new connection, we take back the group
+	// connection if no join or sync is using it.
+	if cxn := b.cxnGroup; cxn != nil && atomic.LoadInt32(&cxn.dead) == 0 && cxn.numInflight() == 0 {
+		b.cxnGroup = nil
+		b.cxnNormals[open] = cxn
+		return cxn, nil
+	}
+
+	cxn, err := b.newCxn(ctx)
+	if err != nil {
+		if best != nil {
+			return best, nil
+		}
+		return nil, err
+	}
+	b.cxnNormals[open] = cxn
+	return cxn, nil
+}
+
+// loadGroupConnection returns the connection for join and sync group
+// requests, which can wait on the broker for up to the group's rebalance
+// timeout.
+//
+// If there is no group connection, we take an idle connection from the normal
+// pool rather than opening a new one; normal requests take the connection
+// back once the group requests are done with it. We only open a new
+// connection if every normal connection is busy.
+func (b *broker) loadGroupConnection(ctx context.Context) (*brokerCxn, error) {
+	if cxn := b.cxnGroup; cxn != nil && atomic.LoadInt32(&cxn.dead) == 0 {
+		return cxn, nil
+	}
+	for i, cxn := range b.cxnNormals {
+		if cxn != nil && atomic.LoadInt32(&cxn.dead) == 0 && cxn.numInflight() == 0 {
+			b.cxnNormals[i] = nil
+			b.cxnGroup = cxn
+			return cxn, nil
+		}
+	}
+
+	cxn, err := b.newCxn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.cxnGroup = cxn
+	return cxn, nil
+}
+
+// newCxn dials and initializes a new connection to the broker.
+func (b *broker) newCxn(ctx context.Context) (*brokerCxn, error) {
+	cxn, err := b.initCxn(ctx)
+	if err != nil {
+		atomic.StoreInt32(&b.failing, 1)
+		return nil, err
+	}
+	atomic.StoreInt32(&b.failing, 0)
+	return cxn, nil
+}
+
+func (b *broker) initCxn(ctx context.Context) (*brokerCxn, error) {
 	conn, err := b.connect(ctx)
 	if err != nil {
 		return nil, err
@@ -343,9 +636,11 @@ func (b *broker) loadConnection(ctx context.Context, reqKey int16) (*brokerCxn,
 	cxn := &brokerCxn{
 		bufPool:         b.cl.bufPool,
 		addr:            b.addr,
+		nodeID:          b.id,
 		conn:            conn,
 		timeouts:        b.cl.connTimeoutFn,
 		clientID:        b.cl.cfg.id,
+		recorder:        b.cl.cfg.wireRecorder,
 		softwareName:    b.cl.cfg.softwareName,
 		softwareVersion: b.cl.cfg.softwareVersion,
 		saslCtx:         b.cl.ctx,
@@ -355,8 +650,15 @@ func (b *broker) loadConnection(ctx context.Context, reqKey int16) (*brokerCxn,
 		conn.Close()
 		return nil, err
 	}
-
-	*pcxn = cxn
+	if vs := cxn.brokerVersions(); vs != nil {
+		old, hadOld := b.versions.Load().(kversion.Versions)
+		b.versions.Store(vs)
+		// If a broker was upgraded or downgraded in place, the
+		// cluster's min versions may have changed.
+		if hadOld && b.id >= 0 && len(old.Diff(vs)) > 0 {
+			b.cl.recomputeClusterVersions()
+		}
+	}
 	return cxn, nil
 }
 
@@ -377,6 +679,7 @@ func (b *broker) connect(ctx context.Context) (net.Conn, error) {
 type brokerCxn struct {
 	conn     net.Conn
 	addr     string
+	nodeID   int32
 	versions [kmsg.MaxKey + 1]int16
 
 	timeouts func(kmsg.Request) (time.Duration, time.Duration)
@@ -392,9 +695,21 @@ type brokerCxn struct {
 	corrID   int32
 	clientID *string
 
+	// recorder, if non-nil, records all requests and responses.
+	recorder *wireRecorder
+
 	softwareName    string // for KIP-511
 	softwareVersion string // for KIP-511
 
+	// inflight is the number of requests written to this connection that
+	// are awaiting responses. This is used to choose the least loaded
+	// connection in a broker's normal connection pool.
+	inflight int32 // atomic
+
+	// lastActivity is the unix nano timestamp of the last write to or read
+	// from this connection, used for reaping idle connections.
+	lastActivity int64 // atomic
+
 	// dieMu guards sending to resps in case the connection has died.
 	dieMu sync.RWMutex
 	// resps manages reading kafka responses.
@@ -403,6 +718,13 @@ type brokerCxn struct {
 	dead int32
 }
 
+func (cxn *brokerCxn) numInflight() int32 { return atomic.LoadInt32(&cxn.inflight) }
+
+func (cxn *brokerCxn) touch() { atomic.StoreInt64(&cxn.lastActivity, time.Now().UnixNano()) }
+func (cxn *brokerCxn) lastActive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&cxn.lastActivity))
+}
+
 func (cxn *brokerCxn) init(maxVersions kversion.Versions) error {
 	for i := 0; i < len(cxn.versions[:]); i++ {
 		cxn.versions[i] = -1
@@ -423,24 +745,39 @@ func (cxn *brokerCxn) init(maxVersions kversion.Versions) error {
 	return nil
 }
 
+// brokerVersions returns the versions the broker reported in ApiVersions, or
+// nil if we did not request them.
+func (cxn *brokerCxn) brokerVersions() kversion.Versions {
+	last := -1
+	for k, v := range cxn.versions {
+		if v >= 0 {
+			last = k
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+	return append(kversion.Versions(nil), cxn.versions[:last+1]...)
+}
+
 func (cxn *brokerCxn) requestAPIVersions() error {
 	maxVersion := int16(3)
 start:
-	req := &kmsg.ApiVersionsRequest{
-		Version:               maxVersion,
-		ClientSoftwareName:    cxn.softwareName,
-		ClientSoftwareVersion: cxn.softwareVersion,
-	}
+	req := kmsg.NewPtrApiVersionsRequest()
+	req.Version = maxVersion
+	req.ClientSoftwareName = cxn.softwareName
+	req.ClientSoftwareVersion = cxn.softwareVersion
 	corrID, err := cxn.writeRequest(req)
 	if err != nil {
 		return err
 	}
 
 	rt, _ := cxn.timeouts(req)
-	rawResp, err := readResponse(cxn.conn, corrID, rt, false) // api versions does *not* use flexible response headers; see comment in promisedResp
+	rawResp, err := readResponse(cxn.conn, corrID, rt, false, nil) // api versions does *not* use flexible response headers; see comment in promisedResp
 	if err != nil {
 		return err
 	}
+	cxn.record(true, req.Key(), req.Version, corrID, rawResp)
 	if len(rawResp) < 2 {
 		return ErrConnDead
 	}
@@ -490,20 +827,20 @@ func (cxn *brokerCxn) sasl() error {
 
 start:
 	if mechanism.Name() != "GSSAPI" && cxn.versions[handshakeKey] >= 0 {
-		req := &kmsg.SASLHandshakeRequest{
-			Version:   cxn.versions[handshakeKey],
-			Mechanism: mechanism.Name(),
-		}
+		req := kmsg.NewPtrSASLHandshakeRequest()
+		req.Version = cxn.versions[handshakeKey]
+		req.Mechanism = mechanism.Name()
 		corrID, err := cxn.writeRequest(req)
 		if err != nil {
 			return err
 		}
 
 		rt, _ := cxn.timeouts(req)
-		rawResp, err := readResponse(cxn.conn, corrID, rt, req.IsFlexible())
+		rawResp, err := readResponse(cxn.conn, corrID, rt, req.IsFlexible(), nil)
 		if err != nil {
 			return err
 		}
+		cxn.record(true, req.Key(), req.Version, corrID, rawResp)
 		resp := req.ResponseKind().(*kmsg.SASLHandshakeResponse)
 		if err = resp.ReadFrom(rawResp); err != nil {
 			return err
@@ -572,23 +909,22 @@ func (cxn *brokerCxn) doSasl(authenticate bool) error {
 				return ErrConnDead
 			}
 			if !done {
-				if challenge, err = readConn(cxn.conn, rt); err != nil {
+				if challenge, err = readConn(cxn.conn, rt, nil); err != nil {
 					return err
 				}
 			}
 
 		} else {
-			const authenticateKey = 37
-			req := &kmsg.SASLAuthenticateRequest{
-				Version:       cxn.versions[authenticateKey],
-				SASLAuthBytes: clientWrite,
-			}
+			const authenticateKey = 36
+			req := kmsg.NewPtrSASLAuthenticateRequest()
+			req.Version = cxn.versions[authenticateKey]
+			req.SASLAuthBytes = clientWrite
 			corrID, err := cxn.writeRequest(req)
 			if err != nil {
 				return err
 			}
 			if !done {
-				rawResp, err := readResponse(cxn.conn, corrID, rt, req.IsFlexible())
+				rawResp, err := readResponse(cxn.conn, corrID, rt, req.IsFlexible(), nil)
 				if err != nil {
 					return err
 				}
@@ -596,6 +932,11 @@ func (cxn *brokerCxn) doSasl(authenticate bool) error {
 				if err = resp.ReadFrom(rawResp); err != nil {
 					return err
 				}
+				if cxn.recorder != nil {
+					redacted := *resp
+					redacted.SASLAuthBytes = nil
+					cxn.record(true, req.Key(), req.Version, corrID, redacted.AppendTo(nil))
+				}
 
 				if err = kerr.ErrorForCode(resp.ErrorCode); err != nil {
 					if resp.ErrorMessage != nil {
@@ -649,12 +990,16 @@ func (cxn *brokerCxn) writeRequest(req kmsg.Request) (int32, error) {
 	if _, err := cxn.conn.Write(buf); err != nil {
 		return 0, ErrConnDead
 	}
+	cxn.touch()
 	id := cxn.corrID
 	cxn.corrID++
+	if cxn.recorder != nil {
+		cxn.record(false, req.Key(), req.GetVersion(), id, recordedRequestBody(req))
+	}
 	return id, nil
 }
 
-func readConn(conn net.Conn, timeout time.Duration) ([]byte, error) {
+func readConn(conn net.Conn, timeout time.Duration, alloc func(int) []byte) ([]byte, error) {
 	sizeBuf := make([]byte, 4)
 	if timeout > 0 {
 		conn.SetReadDeadline(time.Now().Add(timeout))
@@ -668,7 +1013,12 @@ func readConn(conn net.Conn, timeout time.Duration) ([]byte, error) {
 		return nil, ErrInvalidRespSize
 	}
 
-	buf := make([]byte, size)
+	var buf []byte
+	if alloc != nil {
+		buf = alloc(int(size))
+	} else {
+		buf = make([]byte, size)
+	}
 	if _, err := io.ReadFull(conn, buf); err != nil {
 		return nil, ErrConnDead
 	}
@@ -676,9 +1026,10 @@ func readConn(conn net.Conn, timeout time.Duration) ([]byte, error) {
 }
 
 // readResponse reads a response from conn, ensures the correlation ID is
-// correct, and returns a newly allocated slice on success.
-func readResponse(conn net.Conn, corrID int32, timeout time.Duration, flexibleHeader bool) ([]byte, error) {
-	buf, err := readConn(conn, timeout)
+// correct, and returns a newly allocated slice on success, or a slice from
+// alloc if alloc is non-nil.
+func readResponse(conn net.Conn, corrID int32, timeout time.Duration, flexibleHeader bool, alloc func(int) []byte) ([]byte, error) {
+	buf, err := readConn(conn, timeout, alloc)
 	if err != nil {
 		return nil, err
 	}
@@ -700,6 +1051,54 @@ func readResponse(conn net.Conn, corrID int32, timeout time.Duration, flexibleHe
 	return buf[4:], nil
 }
 
+// readStreamedResponse reads a response from conn, decoding it into resp as
+// it is read rather than after reading it in full. This returns an error
+// decoding the response separately from an error that leaves the connection
+// unusable.
+func readStreamedResponse(
+	conn net.Conn,
+	corrID int32,
+	timeout time.Duration,
+	flexibleHeader bool,
+	resp kmsg.Response,
+) (decodeErr, connErr error) {
+	streamer, ok := resp.(interface{ ReadFromReader(*kbin.Reader) error })
+	if !ok {
+		return nil, ErrInvalidResp
+	}
+	if timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		defer conn.SetReadDeadline(time.Time{})
+	}
+	sizeBuf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, sizeBuf); err != nil {
+		return nil, ErrConnDead
+	}
+	size := int32(binary.BigEndian.Uint32(sizeBuf))
+	if size < 4 {
+		return nil, ErrInvalidRespSize
+	}
+
+	b := kbin.NewStreamReader(conn, int(size))
+	if gotID := b.Int32(); !b.Ok() {
+		return nil, ErrConnDead
+	} else if gotID != corrID {
+		conn.Close()
+		return nil, ErrCorrelationIDMismatch
+	}
+	if flexibleHeader {
+		kmsg.SkipTags(b)
+	}
+	decodeErr = streamer.ReadFromReader(b)
+
+	// Whether or not decoding succeeded, we must read the rest of the
+	// response so that the next response starts at the right spot.
+	if err := b.Drain(); err != nil {
+		return nil, ErrConnDead
+	}
+	return decodeErr, nil
+}
+
 // die kills a broker connection (which could be dead already) and replies to
 // all requests awaiting responses appropriately.
 func (cxn *brokerCxn) die() {
@@ -733,6 +1132,7 @@ func (cxn *brokerCxn) waitResp(pr promisedResp) {
 	if atomic.LoadInt32(&cxn.dead) == 1 {
 		dead = true
 	} else {
+		atomic.AddInt32(&cxn.inflight, 1)
 		cxn.resps <- pr
 	}
 	cxn.dieMu.RUnlock()
@@ -747,11 +1147,34 @@ func (cxn *brokerCxn) handleResps() {
 	defer cxn.die() // always track our death
 
 	for pr := range cxn.resps {
-		raw, err := readResponse(cxn.conn, pr.corrID, pr.readTimeout, pr.flexibleHeader)
+		// Streamed responses are never fully in memory, so we do not
+		// stream if we are recording.
+		if _, ok := pr.resp.(interface{ readStreamed() }); ok && cxn.recorder == nil {
+			decodeErr, err := readStreamedResponse(cxn.conn, pr.corrID, pr.readTimeout, pr.flexibleHeader, pr.resp)
+			cxn.touch()
+			atomic.AddInt32(&cxn.inflight, -1)
+			if err != nil {
+				pr.promise(nil, err)
+				return
+			}
+			pr.promise(pr.resp, decodeErr)
+			continue
+		}
+
+		// Responses that alias pooled buffers (zero copy fetches)
+		// provide the buffer to read into.
+		var alloc func(int) []byte
+		if a, ok := pr.resp.(interface{ allocRead(int) []byte }); ok {
+			alloc = a.allocRead
+		}
+		raw, err := readResponse(cxn.conn, pr.corrID, pr.readTimeout, pr.flexibleHeader, alloc)
+		cxn.touch()
+		atomic.AddInt32(&cxn.inflight, -1)
 		if err != nil {
 			pr.promise(nil, err)
 			return
 		}
+		cxn.record(true, pr.resp.Key(), pr.resp.GetVersion(), pr.corrID, raw)
 		pr.promise(pr.resp, pr.resp.ReadFrom(raw))
 	}
 }
@@ -0,0 +1,343 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/twmb/kafka-go/pkg/kmsg"
+	"github.com/twmb/kafka-go/pkg/kversion"
+)
+
+func TestReapConnections(t *testing.T) {
+	cfg := defaultCfg()
+	cfg.connIdleTimeout = time.Minute
+	b := &broker{
+		cl:         &Client{cfg: cfg},
+		cxnNormals: make([]*brokerCxn, 2),
+	}
+
+	newCxn := func(lastActive time.Time, inflight int32) *brokerCxn {
+		conn, _ := net.Pipe()
+		return &brokerCxn{
+			conn:         conn,
+			resps:        make(chan promisedResp, 1),
+			inflight:     inflight,
+			lastActivity: lastActive.UnixNano(),
+		}
+	}
+
+	old := time.Now().Add(-time.Hour)
+	idle := newCxn(old, 0)
+	busy := newCxn(old, 1)
+	recent := newCxn(time.Now(), 0)
+
+	b.cxnNormals[0] = idle
+	b.cxnNormals[1] = busy
+	b.cxnFetch = recent
+
+	b.reapConnections()
+
+	for _, test := range []struct {
+		name string
+		cxn  *brokerCxn
+		dead bool
+	}{
+		{"idle", idle, true},
+		{"busy", busy, false},
+		{"recent", recent, false},
+	} {
+		if dead := atomic.LoadInt32(&test.cxn.dead) == 1; dead != test.dead {
+			t.Errorf("%s: got dead? %v, exp %v", test.name, dead, test.dead)
+		}
+	}
+}
+
+func TestLoadConnection(t *testing.T) {
+	const addr = "127.0.0.1:9092"
+	apiVersions := (&kmsg.ApiVersionsResponse{
+		Version: 3,
+		ApiKeys: []kmsg.ApiVersionsResponseApiKey{{ApiKey: 18, MaxVersion: 3}},
+	}).AppendTo(nil)
+	var entries []WireEntry
+	for i := 0; i < 10; i++ {
+		entries = append(entries, WireEntry{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: apiVersions})
+	}
+	r, err := NewWireReplayer(entries)
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+
+	var dials int
+	failDials := false
+	cl, err := NewClient(
+		SeedBrokers(addr),
+		NormalConnsPerBroker(2),
+		Dialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			dials++
+			if failDials {
+				return nil, errors.New("dial failure")
+			}
+			return r.Dial(ctx, addr)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	// We drive loadConnection directly; handleReqs is idle because we
+	// issue no requests.
+	b := cl.newBroker(addr, 1)
+	defer b.stopForever()
+
+	fakeCxn := func(inflight int32) *brokerCxn {
+		conn, _ := net.Pipe()
+		return &brokerCxn{
+			conn:     conn,
+			resps:    make(chan promisedResp, 1),
+			inflight: inflight,
+		}
+	}
+	load := func(key int16) *brokerCxn {
+		t.Helper()
+		cxn, err := b.loadConnection(context.Background(), key)
+		if err != nil {
+			t.Fatalf("unexpected load err: %v", err)
+		}
+		return cxn
+	}
+	const (
+		metadata  = 3
+		joinGroup = 11
+		syncGroup = 14
+	)
+
+	// An idle normal connection is used as is.
+	idle := fakeCxn(0)
+	b.cxnNormals[0] = idle
+	if cxn := load(metadata); cxn != idle || dials != 0 {
+		t.Errorf("idle: got %p with %d dials, exp %p with 0", cxn, dials, idle)
+	}
+
+	// If every open connection is busy and the pool has room, we open a
+	// new connection...
+	idle.inflight = 1
+	opened := load(metadata)
+	if opened == idle || b.cxnNormals[1] != opened || dials != 1 {
+		t.Errorf("busy: did not open a new pooled connection (%d dials)", dials)
+	}
+
+	// ...but if opening fails, we use the least loaded connection.
+	b.cxnNormals[1] = nil
+	failDials = true
+	if cxn := load(metadata); cxn != idle || dials != 2 {
+		t.Errorf("failed dial: got %p with %d dials, exp %p with 2", cxn, dials, idle)
+	}
+	failDials = false
+
+	// With a full pool, we choose the least loaded connection.
+	busy := fakeCxn(3)
+	b.cxnNormals[0], b.cxnNormals[1] = busy, idle
+	if cxn := load(metadata); cxn != idle || dials != 2 {
+		t.Errorf("full: got %p with %d dials, exp least loaded %p with 2", cxn, dials, idle)
+	}
+
+	// Join borrows an idle normal connection for the group connection
+	// rather than opening a new one, and sync uses it as well.
+	idle.inflight = 0
+	if cxn := load(joinGroup); cxn != idle || b.cxnGroup != idle || b.cxnNormals[1] != nil || dials != 2 {
+		t.Errorf("join: did not borrow the idle normal connection (%d dials)", dials)
+	}
+	idle.inflight = 1 // join in flight
+	if cxn := load(syncGroup); cxn != idle {
+		t.Errorf("sync: got %p, exp group connection %p", cxn, idle)
+	}
+
+	// While a join or sync is in flight, normal requests do not queue
+	// behind it.
+	if cxn := load(metadata); cxn == idle || cxn == busy || dials != 3 {
+		t.Errorf("group busy: normal request did not open its own connection (%d dials)", dials)
+	}
+
+	// Once the group connection is idle, normal requests take it back
+	// rather than opening more connections.
+	b.cxnNormals[1] = nil
+	idle.inflight = 0
+	if cxn := load(metadata); cxn != idle || b.cxnNormals[1] != idle || b.cxnGroup != nil || dials != 3 {
+		t.Errorf("group idle: normal request did not take back the group connection (%d dials)", dials)
+	}
+
+	// If every normal connection is busy, join and sync get a new
+	// connection of their own.
+	idle.inflight = 1
+	join := load(joinGroup)
+	if join == idle || join == busy || b.cxnGroup != join || dials != 4 {
+		t.Errorf("normals busy: join did not open its own connection (%d dials)", dials)
+	}
+}
+
+func TestSeedFallback(t *testing.T) {
+	cl, err := NewClient(SeedBrokers("127.0.0.1:1", "127.0.0.2:1"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+
+	cl.updateBrokers([]kmsg.MetadataResponseBroker{
+		{NodeID: 1, Host: "127.0.0.3", Port: 1},
+		{NodeID: 2, Host: "127.0.0.4", Port: 1},
+	})
+
+	cl.brokersMu.Lock()
+	for _, b := range cl.anyBroker {
+		atomic.StoreInt32(&b.failing, 1)
+	}
+	if !cl.allBrokersFailingLocked() {
+		t.Error("not all brokers failing after marking all as failing")
+	}
+	cl.brokersMu.Unlock()
+
+	for i := 0; i < 20; i++ {
+		if b := cl.broker(); b.id >= -1 {
+			t.Fatalf("got discovered broker %d while all brokers are failing, exp seed broker", b.id)
+		}
+	}
+}
+
+func TestRequestInterceptor(t *testing.T) {
+	var order []string
+	errForbidden := errors.New("forbidden")
+	cl, err := NewClient(
+		SeedBrokers("127.0.0.1:1"),
+		RequestInterceptor(func(ctx context.Context, broker BrokerMetadata, req kmsg.Request, next func() (kmsg.Response, error)) (kmsg.Response, error) {
+			order = append(order, "outer")
+			if broker.Host != "127.0.0.1" || broker.Port != 1 || broker.NodeID >= 0 {
+				t.Errorf("unexpected broker metadata %+v", broker)
+			}
+			return next()
+		}),
+		RequestInterceptor(func(ctx context.Context, _ BrokerMetadata, req kmsg.Request, next func() (kmsg.Response, error)) (kmsg.Response, error) {
+			order = append(order, "inner")
+			if req.Key() == 20 { // delete topics
+				return nil, errForbidden
+			}
+			return next()
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+
+	b := cl.broker()
+	if _, err := b.waitResp(context.Background(), new(kmsg.DeleteTopicsRequest)); err != errForbidden {
+		t.Errorf("got err %v != exp errForbidden", err)
+	}
+	if _, err := b.waitResp(context.Background(), new(kmsg.ListGroupsRequest)); err != ErrNoDial {
+		t.Errorf("got err %v != exp ErrNoDial", err)
+	}
+	if exp := []string{"outer", "inner", "outer", "inner"}; !reflect.DeepEqual(order, exp) {
+		t.Errorf("got interceptor order %v != exp %v", order, exp)
+	}
+}
+
+func TestStrictRequestVersions(t *testing.T) {
+	const addr = "127.0.0.1:9092"
+	meta := kmsg.NewPtrMetadataResponse()
+	meta.Version = 3
+	entries := []WireEntry{
+		{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: (&kmsg.ApiVersionsResponse{
+			Version: 3,
+			ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+				{ApiKey: 3, MaxVersion: 3}, // metadata v3: AllowAutoTopicCreation is v4+
+				{ApiKey: 18, MaxVersion: 3},
+			},
+		}).AppendTo(nil)},
+	}
+	for i := 0; i < 10; i++ {
+		entries = append(entries, WireEntry{Addr: addr, IsResponse: true, Key: 3, Version: 3, Body: meta.AppendTo(nil)})
+	}
+	r, err := NewWireReplayer(entries)
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+
+	cl, err := NewClient(SeedBrokers(addr), Dialer(r.Dial), StrictRequestVersions())
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	// Metadata requests are built by the client, which disables auto
+	// topic creation even though v3 cannot encode that.
+	if _, err := cl.Request(context.Background(), kmsg.NewPtrMetadataRequest()); err != nil {
+		t.Errorf("unexpected err for client built request at an old version: %v", err)
+	}
+
+	// Requests that users issue are validated.
+	req := kmsg.NewPtrMetadataRequest()
+	req.IncludeClusterAuthorizedOperations = true // v8+
+	_, err = cl.SeedBrokers()[0].Request(context.Background(), req)
+	if fe, ok := err.(*kmsg.FieldVersionError); !ok || fe.Version != 3 {
+		t.Errorf("got err %v, exp *kmsg.FieldVersionError at v3", err)
+	}
+
+	// Requests split from a user request are validated as well, while
+	// requests the client builds using a user request's context are not.
+	ctx := cl.userRequestCtx(context.Background(), req)
+	split := kmsg.NewPtrMetadataRequest()
+	if !isUserRequest(splitUserRequestCtx(ctx, req, split), split) {
+		t.Error("split request is not a user request")
+	}
+	if isUserRequest(ctx, split) {
+		t.Error("client built request is a user request")
+	}
+}
+
+func TestBrokerVersions(t *testing.T) {
+	const addr = "127.0.0.1:9092"
+	r, err := NewWireReplayer([]WireEntry{
+		{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: (&kmsg.ApiVersionsResponse{
+			Version: 3,
+			ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+				{ApiKey: 3, MaxVersion: 9},
+				{ApiKey: 18, MaxVersion: 3},
+			},
+		}).AppendTo(nil)},
+	})
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+
+	cl, err := NewClient(SeedBrokers(addr), Dialer(r.Dial))
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	if vs := cl.BrokerVersions(); len(vs) != 0 {
+		t.Errorf("got versions %v before connecting, exp none", vs)
+	}
+
+	// Our request has no recorded response, but we only need the
+	// connection to be initialized.
+	seed := cl.SeedBrokers()[0]
+	seed.Request(context.Background(), new(kmsg.ListGroupsRequest))
+
+	vs, ok := cl.BrokerVersions()[seed.id]
+	if !ok {
+		t.Fatal("no versions for seed broker after connecting")
+	}
+	exp := kversion.Versions{-1, -1, -1, 9, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, 3}
+	if diffs := vs.Diff(exp); len(diffs) != 0 {
+		t.Errorf("got version diffs %v", diffs)
+	}
+}
@@ -30,6 +30,7 @@ import (
 
 	"github.com/twmb/kafka-go/pkg/kerr"
 	"github.com/twmb/kafka-go/pkg/kmsg"
+	"github.com/twmb/kafka-go/pkg/kversion"
 )
 
 // Client issues requests and handles responses to a Kafka cluster.
@@ -53,6 +54,8 @@ type Client struct {
 
 	controllerID int32 // atomic
 
+	clusterVersions clusterVersions
+
 	producer producer
 	consumer consumer
 
@@ -95,6 +98,9 @@ func NewClient(opts ...Opt) (*Client, error) {
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
+	if cfg.wireRecorder != nil {
+		cfg.wireRecorder.logger = cfg.logger
+	}
 
 	seedAddrs := make([]string, 0, len(cfg.seedBrokers))
 	for _, seedBroker := range cfg.seedBrokers {
@@ -232,15 +238,27 @@ func connTimeoutBuilder(defaultTimeout time.Duration) func(kmsg.Request) (time.D
 			return def + millis(t.TimeoutMillis), def
 		case *kmsg.ListPartitionReassignmentsRequest:
 			return def + millis(t.TimeoutMillis), def
+		case *kmsg.UpdateFeaturesRequest:
+			return def + millis(t.TimeoutMillis), def
 		}
 	}
 }
 
 // broker returns a random broker from all brokers ever known.
+//
+// If every broker is failing to connect, we fall back to only our seed
+// brokers. All discovered brokers may be gone if a cluster behind a DNS name
+// was fully replaced, but seeds are re-resolved whenever they are dialed.
 func (cl *Client) broker() *broker {
 	cl.brokersMu.Lock()
 	defer cl.brokersMu.Unlock()
 
+	if cl.allBrokersFailingLocked() {
+		if b := cl.seedBrokerLocked(); b != nil {
+			return b
+		}
+	}
+
 	if cl.anyBrokerIdx >= len(cl.anyBroker) { // metadata update lost us brokers
 		cl.anyBrokerIdx = 0
 	}
@@ -254,6 +272,32 @@ func (cl *Client) broker() *broker {
 	return b
 }
 
+// seedBrokerLocked returns a random seed broker, or nil if there are none.
+// This must be called with brokersMu held.
+func (cl *Client) seedBrokerLocked() *broker {
+	var seeds []*broker
+	for _, b := range cl.anyBroker {
+		if b.id < -1 {
+			seeds = append(seeds, b)
+		}
+	}
+	if len(seeds) == 0 {
+		return nil
+	}
+	return seeds[cl.rng.Intn(len(seeds))]
+}
+
+// allBrokersFailingLocked returns whether every broker we know of failed its
+// most recent connection attempt. This must be called with brokersMu held.
+func (cl *Client) allBrokersFailingLocked() bool {
+	for _, b := range cl.anyBroker {
+		if atomic.LoadInt32(&b.failing) == 0 {
+			return false
+		}
+	}
+	return len(cl.anyBroker) > 0
+}
+
 func (cl *Client) waitTries(ctx context.Context, tries int) bool {
 	after := time.NewTimer(cl.cfg.retryBackoff(tries))
 	defer after.Stop()
@@ -286,14 +330,15 @@ func (cl *Client) fetchMetadata(ctx context.Context, all bool, topics []string)
 start:
 	tries++
 	broker := cl.broker()
-	req := &kmsg.MetadataRequest{
-		AllowAutoTopicCreation: cl.cfg.allowAutoTopicCreation,
-		// DO NOT preallocate topics, since nil is significant
-	}
+	req := kmsg.NewMetadataRequest()
+	req.AllowAutoTopicCreation = cl.cfg.allowAutoTopicCreation
+	// DO NOT preallocate topics, since nil is significant
 	for _, topic := range topics {
-		req.Topics = append(req.Topics, kmsg.MetadataRequestTopic{Topic: topic})
+		t := kmsg.NewMetadataRequestTopic()
+		t.Topic = topic
+		req.Topics = append(req.Topics, t)
 	}
-	kresp, err := broker.waitResp(ctx, req)
+	kresp, err := broker.waitResp(ctx, &req)
 	if err != nil {
 		if retryTimeout > 0 && time.Since(tryStart) > retryTimeout {
 			return nil, err
@@ -328,6 +373,7 @@ func (cl *Client) updateBrokers(brokers []kmsg.MetadataResponseBroker) {
 		return
 	}
 
+	var changed bool
 	for _, broker := range brokers {
 		addr := net.JoinHostPort(broker.Host, strconv.Itoa(int(broker.Port)))
 
@@ -337,9 +383,11 @@ func (cl *Client) updateBrokers(brokers []kmsg.MetadataResponseBroker) {
 			if b.addr != addr {
 				b.stopForever()
 				b = cl.newBroker(addr, b.id)
+				changed = true
 			}
 		} else {
 			b = cl.newBroker(addr, broker.NodeID)
+			changed = true
 		}
 
 		newBrokers[b.id] = b
@@ -352,11 +400,16 @@ func (cl *Client) updateBrokers(brokers []kmsg.MetadataResponseBroker) {
 			newAnyBroker = append(newAnyBroker, goneBroker)
 		} else {
 			goneBroker.stopForever()
+			changed = true
 		}
 	}
 
 	cl.brokers = newBrokers
 	cl.anyBroker = newAnyBroker
+
+	if changed {
+		cl.recomputeClusterVersions()
+	}
 }
 
 // Close leaves any group and closes all connections and goroutines.
@@ -413,23 +466,24 @@ func (cl *Client) Close() {
 // issue the request to the appropriate group or transaction coordinator.
 //
 // For group coordinator requests, if the request contains multiple groups
-// (delete groups, describe groups), the request is split into one request per
-// broker containing the groups that broker can respond to. Thus, you do not
-// have to worry about maxing groups that different brokers are coordinators
-// for. All responses are merged. Only if all requests error is an error
-// returned.
+// (delete groups, describe groups, offset fetch v8+), the request is split
+// into one request per broker containing the groups that broker can respond
+// to. Thus, you do not have to worry about maxing groups that different
+// brokers are coordinators for. All responses are merged. Only if all
+// requests error is an error returned.
 //
 // For transaction requests, the request is issued to the transaction
 // coordinator. However, if the request is an init producer ID request and the
 // request has no transactional ID, the request goes to any broker.
 //
-// If the request is a ListOffsets request or OffsetForLeaderEpoch request,
-// this will properly split the request to send partitions to the appropriate
-// broker.
+// If the request is a ListOffsets, OffsetForLeaderEpoch, or DescribeProducers
+// request, this will properly split the request to send partitions to the
+// appropriate broker.
 //
-// If the request is a ListGroups request, this will send ListGroups to every
-// known broker after a broker metadata lookup. The first error code of any
-// response is kept, and all responded groups are merged.
+// If the request is a ListGroups or ListTransactions request, this will send
+// the request to every known broker after a broker metadata lookup. The first
+// error code of any response is kept, and all responded groups or
+// transactions are merged.
 //
 // In short, this method tries to do the correct thing depending on what type
 // of request is being issued.
@@ -439,6 +493,41 @@ func (cl *Client) Close() {
 // just end up canceling and not receiving the response to what Kafka
 // inevitably does.
 func (cl *Client) Request(ctx context.Context, req kmsg.Request) (kmsg.Response, error) {
+	return cl.internalRequest(cl.userRequestCtx(ctx, req), req)
+}
+
+// userRequestKey is a context key whose value is a request that a user issued
+// with Client.Request or Broker.Request. With StrictRequestVersions, only user
+// requests (and requests split from them) are validated; requests that the
+// client builds for itself set fields that older brokers safely ignore.
+type userRequestKey struct{}
+
+// userRequestCtx marks req as a user request in the returned context if the
+// client validates requests.
+func (cl *Client) userRequestCtx(ctx context.Context, req kmsg.Request) context.Context {
+	if !cl.cfg.strictRequestVersions {
+		return ctx
+	}
+	return context.WithValue(ctx, userRequestKey{}, req)
+}
+
+// splitUserRequestCtx returns a context marking split as a user request if
+// split was built from the user request req.
+func splitUserRequestCtx(ctx context.Context, req, split kmsg.Request) context.Context {
+	if !isUserRequest(ctx, req) {
+		return ctx
+	}
+	return context.WithValue(ctx, userRequestKey{}, split)
+}
+
+func isUserRequest(ctx context.Context, req kmsg.Request) bool {
+	user, _ := ctx.Value(userRequestKey{}).(kmsg.Request)
+	return user == req
+}
+
+// internalRequest is Request for requests that the client builds itself,
+// which are never validated with StrictRequestVersions.
+func (cl *Client) internalRequest(ctx context.Context, req kmsg.Request) (kmsg.Response, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	var resp kmsg.Response
@@ -491,8 +580,12 @@ start:
 		resp, err = cl.handleListOrEpochReq(ctx, listReq)
 	} else if offsetEpochReq, ok := req.(*kmsg.OffsetForLeaderEpochRequest); ok {
 		resp, err = cl.handleListOrEpochReq(ctx, offsetEpochReq)
+	} else if describeProducersReq, ok := req.(*kmsg.DescribeProducersRequest); ok {
+		resp, err = cl.handleListOrEpochReq(ctx, describeProducersReq)
 	} else if listGroupsReq, ok := req.(*kmsg.ListGroupsRequest); ok {
-		resp, err = cl.handleListGroupsReq(ctx, listGroupsReq)
+		resp, err = cl.handleListAllReq(ctx, listGroupsReq)
+	} else if listTxnsReq, ok := req.(*kmsg.ListTransactionsRequest); ok {
+		resp, err = cl.handleListAllReq(ctx, listTxnsReq)
 	} else {
 		resp, err = cl.broker().waitResp(ctx, req)
 	}
@@ -575,15 +668,25 @@ start:
 	}
 
 	tries++
-	kresp, err := cl.broker().waitResp(ctx, &kmsg.FindCoordinatorRequest{
-		CoordinatorKey:  key.name,
-		CoordinatorType: key.typ,
-	})
+	req := kmsg.NewPtrFindCoordinatorRequest()
+	req.CoordinatorKey = key.name
+	req.CoordinatorType = key.typ
+	req.CoordinatorKeys = []string{key.name} // v4+
+	kresp, err := cl.broker().waitResp(ctx, req)
 
-	var resp *kmsg.FindCoordinatorResponse
+	var nodeID int32
 	if err == nil {
-		resp = kresp.(*kmsg.FindCoordinatorResponse)
-		err = kerr.ErrorForCode(resp.ErrorCode)
+		resp := kresp.(*kmsg.FindCoordinatorResponse)
+		errCode := resp.ErrorCode
+		nodeID = resp.NodeID
+		if resp.Version >= 4 { // KIP-699: one coordinator per requested key
+			if len(resp.Coordinators) != 1 {
+				return nil, ErrInvalidResp
+			}
+			errCode = resp.Coordinators[0].ErrorCode
+			nodeID = resp.Coordinators[0].NodeID
+		}
+		err = kerr.ErrorForCode(errCode)
 	}
 
 	if err != nil {
@@ -599,7 +702,7 @@ start:
 		return nil, err
 	}
 
-	coordinator = resp.NodeID
+	coordinator = nodeID
 	cl.coordinatorsMu.Lock()
 	cl.coordinators[key] = coordinator
 	cl.coordinatorsMu.Unlock()
@@ -651,7 +754,8 @@ func (cl *Client) loadCoordinators(typ int8, names ...string) (map[string]*broke
 // contain multiple groups which could go to multiple brokers due to the group
 // coordinators being different.
 //
-// All transaction requests are simple.
+// Transaction requests are simple, other than DescribeTransactions, which is
+// split by transactional ID the same as DescribeGroups is split by group.
 //
 // Most requests go to one coordinator; those are simple and we issue those
 // simply.
@@ -675,7 +779,7 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request, ty
 		return nil, ErrClientTooOld
 
 	/////////
-	// TXN // -- all txn reqs are simple
+	// TXN // -- most txn reqs are simple
 	/////////
 
 	case *kmsg.InitProducerIDRequest:
@@ -695,6 +799,32 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request, ty
 	case *kmsg.EndTxnRequest:
 		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeTxn, t.TransactionalID, req)
 
+	case *kmsg.DescribeTransactionsRequest:
+		names = append(names, t.TransactionalIDs...)
+		coordinators, err := cl.loadCoordinators(coordinatorTypeTxn, names...)
+		if err != nil {
+			return nil, err
+		}
+		broker2req = make(map[*broker]kmsg.Request)
+
+		for _, id := range t.TransactionalIDs {
+			broker := coordinators[id]
+			if broker2req[broker] == nil {
+				broker2req[broker] = kmsg.NewPtrDescribeTransactionsRequest()
+			}
+			req := broker2req[broker].(*kmsg.DescribeTransactionsRequest)
+			req.TransactionalIDs = append(req.TransactionalIDs, id)
+		}
+
+		resp := kmsg.NewPtrDescribeTransactionsResponse()
+		kresp = resp
+		merge = func(newKResp kmsg.Response) {
+			newResp := newKResp.(*kmsg.DescribeTransactionsResponse)
+			resp.Version = newResp.Version
+			resp.ThrottleMillis = newResp.ThrottleMillis
+			resp.TransactionStates = append(resp.TransactionStates, newResp.TransactionStates...)
+		}
+
 	///////////
 	// GROUP // -- most group reqs are simple
 	///////////
@@ -704,7 +834,44 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request, ty
 	case *kmsg.TxnOffsetCommitRequest:
 		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeGroup, t.Group, req)
 	case *kmsg.OffsetFetchRequest:
-		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeGroup, t.Group, req)
+		if len(t.Groups) <= 1 {
+			group := t.Group
+			if len(t.Groups) == 1 { // v8+
+				group = t.Groups[0].Group
+			}
+			return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeGroup, group, req)
+		}
+
+		// v8+ can fetch offsets for many groups at once, which we split
+		// the same as DescribeGroups.
+		for _, group := range t.Groups {
+			names = append(names, group.Group)
+		}
+		coordinators, err := cl.loadCoordinators(coordinatorTypeGroup, names...)
+		if err != nil {
+			return nil, err
+		}
+		broker2req = make(map[*broker]kmsg.Request)
+
+		for _, group := range t.Groups {
+			broker := coordinators[group.Group]
+			if broker2req[broker] == nil {
+				req := kmsg.NewPtrOffsetFetchRequest()
+				req.RequireStable = t.RequireStable
+				broker2req[broker] = req
+			}
+			req := broker2req[broker].(*kmsg.OffsetFetchRequest)
+			req.Groups = append(req.Groups, group)
+		}
+
+		resp := kmsg.NewPtrOffsetFetchResponse()
+		kresp = resp
+		merge = func(newKResp kmsg.Response) {
+			newResp := newKResp.(*kmsg.OffsetFetchResponse)
+			resp.Version = newResp.Version
+			resp.ThrottleMillis = newResp.ThrottleMillis
+			resp.Groups = append(resp.Groups, newResp.Groups...)
+		}
 	case *kmsg.JoinGroupRequest:
 		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeGroup, t.Group, req)
 	case *kmsg.HeartbeatRequest:
@@ -713,6 +880,8 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request, ty
 		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeGroup, t.Group, req)
 	case *kmsg.SyncGroupRequest:
 		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeGroup, t.Group, req)
+	case *kmsg.ConsumerGroupHeartbeatRequest:
+		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeGroup, t.Group, req)
 
 	case *kmsg.DescribeGroupsRequest:
 		names = append(names, t.Groups...)
@@ -725,15 +894,15 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request, ty
 		for _, group := range t.Groups {
 			broker := coordinators[group]
 			if broker2req[broker] == nil {
-				broker2req[broker] = &kmsg.DescribeGroupsRequest{
-					IncludeAuthorizedOperations: t.IncludeAuthorizedOperations,
-				}
+				req := kmsg.NewPtrDescribeGroupsRequest()
+				req.IncludeAuthorizedOperations = t.IncludeAuthorizedOperations
+				broker2req[broker] = req
 			}
 			req := broker2req[broker].(*kmsg.DescribeGroupsRequest)
 			req.Groups = append(req.Groups, group)
 		}
 
-		resp := new(kmsg.DescribeGroupsResponse)
+		resp := kmsg.NewPtrDescribeGroupsResponse()
 		kresp = resp
 		merge = func(newKResp kmsg.Response) {
 			newResp := newKResp.(*kmsg.DescribeGroupsResponse)
@@ -753,13 +922,13 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request, ty
 		for _, group := range t.Groups {
 			broker := coordinators[group]
 			if broker2req[broker] == nil {
-				broker2req[broker] = new(kmsg.DeleteGroupsRequest)
+				broker2req[broker] = kmsg.NewPtrDeleteGroupsRequest()
 			}
 			req := broker2req[broker].(*kmsg.DeleteGroupsRequest)
 			req.Groups = append(req.Groups, group)
 		}
 
-		resp := new(kmsg.DeleteGroupsResponse)
+		resp := kmsg.NewPtrDeleteGroupsResponse()
 		kresp = resp
 		merge = func(newKResp kmsg.Response) {
 			newResp := newKResp.(*kmsg.DeleteGroupsResponse)
@@ -775,12 +944,12 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request, ty
 		firstErr error
 		errs     int
 	)
-	for broker, req := range broker2req {
+	for broker, brokerReq := range broker2req {
 		wg.Add(1)
-		myBroker, myReq := broker, req
+		myBroker, myReq := broker, brokerReq
 		go func() {
 			defer wg.Done()
-			resp, err := cl.handleReqWithCoordinator(ctx, myBroker, typ, names, myReq)
+			resp, err := cl.handleReqWithCoordinator(splitUserRequestCtx(ctx, req, myReq), myBroker, typ, names, myReq)
 
 			mergeMu.Lock()
 			defer mergeMu.Unlock()
@@ -855,6 +1024,10 @@ func (cl *Client) handleReqWithCoordinator(
 		errCode = t.ErrorCode
 	case *kmsg.EndTxnResponse:
 		errCode = t.ErrorCode
+	case *kmsg.DescribeTransactionsResponse:
+		if len(t.TransactionStates) > 0 {
+			errCode = t.TransactionStates[0].ErrorCode
+		}
 
 	///////////
 	// GROUP //
@@ -871,7 +1044,11 @@ func (cl *Client) handleReqWithCoordinator(
 			}
 		}
 	case *kmsg.OffsetFetchResponse:
-		if t.Version >= 2 {
+		if t.Version >= 8 {
+			if len(t.Groups) > 0 {
+				errCode = t.Groups[0].ErrorCode
+			}
+		} else if t.Version >= 2 {
 			errCode = t.ErrorCode
 		} else if len(t.Topics) > 0 && len(t.Topics[0].Partitions) > 0 {
 			errCode = t.Topics[0].Partitions[0].ErrorCode
@@ -884,6 +1061,8 @@ func (cl *Client) handleReqWithCoordinator(
 		errCode = t.ErrorCode
 	case *kmsg.SyncGroupResponse:
 		errCode = t.ErrorCode
+	case *kmsg.ConsumerGroupHeartbeatResponse:
+		errCode = t.ErrorCode
 	case *kmsg.DescribeGroupsResponse:
 		if len(t.Groups) > 0 {
 			errCode = t.Groups[0].ErrorCode
@@ -894,10 +1073,9 @@ func (cl *Client) handleReqWithCoordinator(
 		}
 	}
 
-	switch retriableErr := kerr.ErrorForCode(errCode); retriableErr {
-	case kerr.CoordinatorNotAvailable,
-		kerr.CoordinatorLoadInProgress,
-		kerr.NotCoordinator:
+	// A loading coordinator has not moved, but we look it up again
+	// anyway along with coordinators that have.
+	if retriableErr := kerr.ErrorForCode(errCode); kerr.IsCoordinatorMoved(retriableErr) || retriableErr == kerr.CoordinatorLoadInProgress {
 		err = retriableErr
 
 		cl.coordinatorsMu.Lock()
@@ -943,6 +1121,30 @@ func (cl *Client) DiscoveredBrokers() []*Broker {
 	return bs
 }
 
+// BrokerVersions returns the max versions that each broker the client has
+// connected to reported in its ApiVersions response, keyed by broker ID. Seed
+// brokers are keyed by special negative internal IDs. Keys a broker does not
+// support are -1.
+//
+// Brokers are only present once the client has connected to them, and the
+// versions are from the most recent connection. Brokers are not present if
+// the client is pinned to versions before 0.10.0 with MaxVersions, because
+// the client does not issue ApiVersions requests. Use
+// kversion.Versions.VersionGuess to guess the Kafka release each broker is
+// running.
+func (cl *Client) BrokerVersions() map[int32]kversion.Versions {
+	cl.brokersMu.RLock()
+	defer cl.brokersMu.RUnlock()
+
+	versions := make(map[int32]kversion.Versions)
+	for id, broker := range cl.brokers {
+		if vs, ok := broker.versions.Load().(kversion.Versions); ok {
+			versions[id] = vs
+		}
+	}
+	return versions
+}
+
 // SeedBrokers returns the all seed brokers.
 func (cl *Client) SeedBrokers() []*Broker {
 	cl.brokersMu.Lock()
@@ -958,10 +1160,11 @@ func (cl *Client) SeedBrokers() []*Broker {
 	}
 }
 
-// handleListGroupsReq issues a list group request to every broker following a
-// metadata update. We do no retries unless everything fails, at which point
-// the calling function will retry.
-func (cl *Client) handleListGroupsReq(ctx context.Context, req *kmsg.ListGroupsRequest) (kmsg.Response, error) {
+// handleListAllReq issues a list groups or list transactions request to every
+// broker following a metadata update and merges the responses. We do no
+// retries unless everything fails, at which point the calling function will
+// retry.
+func (cl *Client) handleListAllReq(ctx context.Context, req kmsg.Request) (kmsg.Response, error) {
 	if err := cl.fetchBrokerMetadata(ctx); err != nil {
 		return nil, err
 	}
@@ -978,11 +1181,22 @@ func (cl *Client) handleListGroupsReq(ctx context.Context, req *kmsg.ListGroupsR
 		if br.id < 0 {
 			continue // we skip seed brokers
 		}
+		// Every broker sets its own version on the request it
+		// issues, so each needs its own copy.
+		var brokerReq kmsg.Request
+		switch t := req.(type) {
+		case *kmsg.ListGroupsRequest:
+			dup := *t
+			brokerReq = &dup
+		case *kmsg.ListTransactionsRequest:
+			dup := *t
+			brokerReq = &dup
+		}
 		wg.Add(1)
 		numReqs++
 		go func(br *broker) {
 			defer wg.Done()
-			resp, err := br.waitResp(ctx, req)
+			resp, err := br.waitResp(splitUserRequestCtx(ctx, req, brokerReq), brokerReq)
 			respErrs <- respErr{resp, err}
 		}(br)
 	}
@@ -990,7 +1204,43 @@ func (cl *Client) handleListGroupsReq(ctx context.Context, req *kmsg.ListGroupsR
 	wg.Wait()
 	close(respErrs)
 
-	var mergeResp kmsg.ListGroupsResponse
+	var kresp kmsg.Response
+	var merge func(kmsg.Response)
+	switch req.(type) {
+	case *kmsg.ListGroupsRequest:
+		mergeResp := kmsg.NewPtrListGroupsResponse()
+		kresp = mergeResp
+		merge = func(newKResp kmsg.Response) {
+			resp := newKResp.(*kmsg.ListGroupsResponse)
+			mergeResp.Version = resp.Version
+			if mergeResp.ErrorCode == 0 {
+				mergeResp.ErrorCode = resp.ErrorCode
+			}
+			mergeResp.Groups = append(mergeResp.Groups, resp.Groups...)
+		}
+
+	case *kmsg.ListTransactionsRequest:
+		mergeResp := kmsg.NewPtrListTransactionsResponse()
+		kresp = mergeResp
+		unknownStates := make(map[string]bool)
+		merge = func(newKResp kmsg.Response) {
+			resp := newKResp.(*kmsg.ListTransactionsResponse)
+			mergeResp.Version = resp.Version
+			if mergeResp.ErrorCode == 0 {
+				mergeResp.ErrorCode = resp.ErrorCode
+			}
+			// Every broker reports the same unknown state filters,
+			// which we only want once.
+			for _, state := range resp.UnknownStateFilters {
+				if !unknownStates[state] {
+					unknownStates[state] = true
+					mergeResp.UnknownStateFilters = append(mergeResp.UnknownStateFilters, state)
+				}
+			}
+			mergeResp.TransactionStates = append(mergeResp.TransactionStates, resp.TransactionStates...)
+		}
+	}
+
 	var firstErr error
 	var errs int
 	for re := range respErrs {
@@ -1001,23 +1251,19 @@ func (cl *Client) handleListGroupsReq(ctx context.Context, req *kmsg.ListGroupsR
 			}
 			continue
 		}
-		resp := re.resp.(*kmsg.ListGroupsResponse)
-		if mergeResp.ErrorCode == 0 {
-			mergeResp.ErrorCode = resp.ErrorCode
-		}
-		mergeResp.Groups = append(mergeResp.Groups, resp.Groups...)
+		merge(re.resp)
 	}
 
 	if errs == numReqs {
 		return nil, firstErr
 	}
-	return &mergeResp, nil
+	return kresp, nil
 }
 
 // handleListOrEpochReq is simple-in-theory function that is long due to types.
-// This simply sends all partitions of a list offset request or offset for
-// leader epoch request to the appropriate brokers and then merges the
-// response.
+// This simply sends all partitions of a list offset request, offset for
+// leader epoch request, or describe producers request to the appropriate
+// brokers and then merges the response.
 func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (kmsg.Response, error) {
 	// First, pull out the topics from either request and set them as
 	// topics we need to load metadata for.
@@ -1031,6 +1277,10 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 		for _, topic := range t.Topics {
 			needTopics = append(needTopics, topic.Topic)
 		}
+	case *kmsg.DescribeProducersRequest:
+		for _, topic := range t.Topics {
+			needTopics = append(needTopics, topic.Topic)
+		}
 	}
 	cl.topicsMu.Lock()
 	topics := cl.cloneTopics()
@@ -1071,7 +1321,7 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 
 	switch t := req.(type) {
 	case *kmsg.ListOffsetsRequest:
-		resp := new(kmsg.ListOffsetsResponse)
+		resp := kmsg.NewPtrListOffsetsResponse()
 		kresp = resp
 
 		reqParts := make(map[*broker]map[string][]kmsg.ListOffsetsRequestTopicPartition)
@@ -1082,10 +1332,10 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 			for _, partition := range topic.Partitions {
 				topicPartition, exists := topicPartitions.all[partition.Partition]
 				if !exists {
-					respParts[topic.Topic] = append(respParts[topic.Topic], kmsg.ListOffsetsResponseTopicPartition{
-						Partition: partition.Partition,
-						ErrorCode: kerr.UnknownTopicOrPartition.Code,
-					})
+					respPart := kmsg.NewListOffsetsResponseTopicPartition()
+					respPart.Partition = partition.Partition
+					respPart.ErrorCode = kerr.UnknownTopicOrPartition.Code
+					respParts[topic.Topic] = append(respParts[topic.Topic], respPart)
 					continue
 				}
 
@@ -1097,10 +1347,10 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 							errCode = ke.Code
 						}
 					}
-					respParts[topic.Topic] = append(respParts[topic.Topic], kmsg.ListOffsetsResponseTopicPartition{
-						Partition: partition.Partition,
-						ErrorCode: errCode,
-					})
+					respPart := kmsg.NewListOffsetsResponseTopicPartition()
+					respPart.Partition = partition.Partition
+					respPart.ErrorCode = errCode
+					respParts[topic.Topic] = append(respParts[topic.Topic], respPart)
 					continue
 				}
 
@@ -1114,15 +1364,14 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 		}
 
 		for broker, brokerReqParts := range reqParts {
-			req := &kmsg.ListOffsetsRequest{
-				ReplicaID:      t.ReplicaID,
-				IsolationLevel: t.IsolationLevel,
-			}
+			req := kmsg.NewPtrListOffsetsRequest()
+			req.ReplicaID = t.ReplicaID
+			req.IsolationLevel = t.IsolationLevel
 			for topic, parts := range brokerReqParts {
-				req.Topics = append(req.Topics, kmsg.ListOffsetsRequestTopic{
-					Topic:      topic,
-					Partitions: parts,
-				})
+				reqTopic := kmsg.NewListOffsetsRequestTopic()
+				reqTopic.Topic = topic
+				reqTopic.Partitions = parts
+				req.Topics = append(req.Topics, reqTopic)
 			}
 			broker2req[broker] = req
 		}
@@ -1138,16 +1387,16 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 
 		finalize = func() {
 			for topic, parts := range respParts {
-				resp.Topics = append(resp.Topics, kmsg.ListOffsetsResponseTopic{
-					Topic:      topic,
-					Partitions: parts,
-				})
+				respTopic := kmsg.NewListOffsetsResponseTopic()
+				respTopic.Topic = topic
+				respTopic.Partitions = parts
+				resp.Topics = append(resp.Topics, respTopic)
 			}
 		}
 
 	// Outside of type swapping, this case is the same as the last
 	case *kmsg.OffsetForLeaderEpochRequest:
-		resp := new(kmsg.OffsetForLeaderEpochResponse)
+		resp := kmsg.NewPtrOffsetForLeaderEpochResponse()
 		kresp = resp
 
 		reqParts := make(map[*broker]map[string][]kmsg.OffsetForLeaderEpochRequestTopicPartition)
@@ -1158,10 +1407,10 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 			for _, partition := range topic.Partitions {
 				topicPartition, exists := topicPartitions.all[partition.Partition]
 				if !exists {
-					respParts[topic.Topic] = append(respParts[topic.Topic], kmsg.OffsetForLeaderEpochResponseTopicPartition{
-						Partition: partition.Partition,
-						ErrorCode: kerr.UnknownTopicOrPartition.Code,
-					})
+					respPart := kmsg.NewOffsetForLeaderEpochResponseTopicPartition()
+					respPart.Partition = partition.Partition
+					respPart.ErrorCode = kerr.UnknownTopicOrPartition.Code
+					respParts[topic.Topic] = append(respParts[topic.Topic], respPart)
 					continue
 				}
 
@@ -1173,10 +1422,10 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 							errCode = ke.Code
 						}
 					}
-					respParts[topic.Topic] = append(respParts[topic.Topic], kmsg.OffsetForLeaderEpochResponseTopicPartition{
-						Partition: partition.Partition,
-						ErrorCode: errCode,
-					})
+					respPart := kmsg.NewOffsetForLeaderEpochResponseTopicPartition()
+					respPart.Partition = partition.Partition
+					respPart.ErrorCode = errCode
+					respParts[topic.Topic] = append(respParts[topic.Topic], respPart)
 					continue
 				}
 
@@ -1190,14 +1439,13 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 		}
 
 		for broker, brokerReqParts := range reqParts {
-			req := &kmsg.OffsetForLeaderEpochRequest{
-				ReplicaID: t.ReplicaID,
-			}
+			req := kmsg.NewPtrOffsetForLeaderEpochRequest()
+			req.ReplicaID = t.ReplicaID
 			for topic, parts := range brokerReqParts {
-				req.Topics = append(req.Topics, kmsg.OffsetForLeaderEpochRequestTopic{
-					Topic:      topic,
-					Partitions: parts,
-				})
+				reqTopic := kmsg.NewOffsetForLeaderEpochRequestTopic()
+				reqTopic.Topic = topic
+				reqTopic.Partitions = parts
+				req.Topics = append(req.Topics, reqTopic)
 			}
 			broker2req[broker] = req
 		}
@@ -1213,10 +1461,83 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 
 		finalize = func() {
 			for topic, parts := range respParts {
-				resp.Topics = append(resp.Topics, kmsg.OffsetForLeaderEpochResponseTopic{
-					Topic:      topic,
-					Partitions: parts,
-				})
+				respTopic := kmsg.NewOffsetForLeaderEpochResponseTopic()
+				respTopic.Topic = topic
+				respTopic.Partitions = parts
+				resp.Topics = append(resp.Topics, respTopic)
+			}
+		}
+
+	// Again the same, but request partitions are only partition numbers.
+	case *kmsg.DescribeProducersRequest:
+		resp := kmsg.NewPtrDescribeProducersResponse()
+		kresp = resp
+
+		reqParts := make(map[*broker]map[string][]int32)
+		respParts := make(map[string][]kmsg.DescribeProducersResponseTopicPartition)
+
+		for _, topic := range t.Topics {
+			topicPartitions := topics[topic.Topic].load()
+			for _, partition := range topic.Partitions {
+				topicPartition, exists := topicPartitions.all[partition]
+				if !exists {
+					respPart := kmsg.NewDescribeProducersResponseTopicPartition()
+					respPart.Partition = partition
+					respPart.ErrorCode = kerr.UnknownTopicOrPartition.Code
+					respParts[topic.Topic] = append(respParts[topic.Topic], respPart)
+					continue
+				}
+
+				broker := brokers[topicPartition.leader]
+				if topicPartition.loadErr != nil || broker == nil {
+					errCode := kerr.UnknownServerError.Code
+					if topicPartition.loadErr != nil {
+						if ke, ok := topicPartition.loadErr.(*kerr.Error); ok {
+							errCode = ke.Code
+						}
+					}
+					respPart := kmsg.NewDescribeProducersResponseTopicPartition()
+					respPart.Partition = partition
+					respPart.ErrorCode = errCode
+					respParts[topic.Topic] = append(respParts[topic.Topic], respPart)
+					continue
+				}
+
+				brokerReqParts := reqParts[broker]
+				if brokerReqParts == nil {
+					brokerReqParts = make(map[string][]int32)
+					reqParts[broker] = brokerReqParts
+				}
+				brokerReqParts[topic.Topic] = append(brokerReqParts[topic.Topic], partition)
+			}
+		}
+
+		for broker, brokerReqParts := range reqParts {
+			req := kmsg.NewPtrDescribeProducersRequest()
+			for topic, parts := range brokerReqParts {
+				reqTopic := kmsg.NewDescribeProducersRequestTopic()
+				reqTopic.Topic = topic
+				reqTopic.Partitions = parts
+				req.Topics = append(req.Topics, reqTopic)
+			}
+			broker2req[broker] = req
+		}
+		merge = func(newKResp kmsg.Response) {
+			newResp := newKResp.(*kmsg.DescribeProducersResponse)
+			resp.Version = newResp.Version
+			resp.ThrottleMillis = newResp.ThrottleMillis
+
+			for _, topic := range newResp.Topics {
+				respParts[topic.Topic] = append(respParts[topic.Topic], topic.Partitions...)
+			}
+		}
+
+		finalize = func() {
+			for topic, parts := range respParts {
+				respTopic := kmsg.NewDescribeProducersResponseTopic()
+				respTopic.Topic = topic
+				respTopic.Partitions = parts
+				resp.Topics = append(resp.Topics, respTopic)
 			}
 		}
 	}
@@ -1229,13 +1550,13 @@ func (cl *Client) handleListOrEpochReq(ctx context.Context, req kmsg.Request) (k
 		firstErr error
 		errs     int
 	)
-	for broker, req := range broker2req {
+	for broker, brokerReq := range broker2req {
 		wg.Add(1)
-		myBroker, myReq := broker, req
+		myBroker, myReq := broker, brokerReq
 		go func() {
 			defer wg.Done()
 
-			resp, err := myBroker.waitResp(ctx, myReq)
+			resp, err := myBroker.waitResp(splitUserRequestCtx(ctx, req, myReq), myReq)
 
 			mergeMu.Lock()
 			defer mergeMu.Unlock()
@@ -1276,6 +1597,7 @@ type Broker struct {
 // you may just end up canceling and not receiving the response to what Kafka
 // inevitably does.
 func (b *Broker) Request(ctx context.Context, req kmsg.Request) (kmsg.Response, error) {
+	ctx = b.cl.userRequestCtx(ctx, req)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	var resp kmsg.Response
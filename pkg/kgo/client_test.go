@@ -0,0 +1,128 @@
+package kgo
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/twmb/kafka-go/pkg/kerr"
+	"github.com/twmb/kafka-go/pkg/kmsg"
+)
+
+func TestRequestSplitsAndMerges(t *testing.T) {
+	const (
+		addr1 = "127.0.0.1:1"
+		addr2 = "127.0.0.1:2"
+	)
+	var entries []WireEntry
+	for _, addr := range []string{addr1, addr2} {
+		body := (&kmsg.ApiVersionsResponse{
+			Version: 3,
+			ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+				{ApiKey: 3, MaxVersion: 1},
+				{ApiKey: 18, MaxVersion: 3},
+				{ApiKey: 61, MaxVersion: 0},
+				{ApiKey: 66, MaxVersion: 0},
+			},
+		}).AppendTo(nil)
+		for i := 0; i < 3; i++ { // seed and broker connections
+			entries = append(entries, WireEntry{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: body})
+		}
+	}
+	meta := (&kmsg.MetadataResponse{
+		Version: 1,
+		Brokers: []kmsg.MetadataResponseBroker{
+			{NodeID: 1, Host: "127.0.0.1", Port: 1},
+			{NodeID: 2, Host: "127.0.0.1", Port: 2},
+		},
+		ControllerID: 1,
+		Topics: []kmsg.MetadataResponseTopic{{
+			Topic: "foo",
+			Partitions: []kmsg.MetadataResponseTopicPartition{
+				{Partition: 0, Leader: 1},
+				{Partition: 1, Leader: 2},
+			},
+		}},
+	}).AppendTo(nil)
+	for i := 0; i < 10; i++ {
+		entries = append(entries, WireEntry{Addr: addr1, IsResponse: true, Key: 3, Version: 1, Body: meta})
+	}
+
+	// Each broker only has a response for the partition it leads and
+	// the transactions it coordinates.
+	for i, addr := range []string{addr1, addr2} {
+		entries = append(entries,
+			WireEntry{Addr: addr, IsResponse: true, Key: 61, Version: 0, Body: (&kmsg.DescribeProducersResponse{
+				Topics: []kmsg.DescribeProducersResponseTopic{{
+					Topic:      "foo",
+					Partitions: []kmsg.DescribeProducersResponseTopicPartition{{Partition: int32(i)}},
+				}},
+			}).AppendTo(nil)},
+			WireEntry{Addr: addr, IsResponse: true, Key: 66, Version: 0, Body: (&kmsg.ListTransactionsResponse{
+				UnknownStateFilters: []string{"Bogus"},
+				TransactionStates: []kmsg.ListTransactionsResponseTransactionState{
+					{TransactionalID: addr, State: "Ongoing"},
+				},
+			}).AppendTo(nil)},
+		)
+	}
+
+	r, err := NewWireReplayer(entries)
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+
+	cl, err := NewClient(
+		SeedBrokers(addr1),
+		Dialer(r.Dial),
+		MetadataMinAge(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	// DescribeProducers is split by partition leader.
+	describeReq := kmsg.NewPtrDescribeProducersRequest()
+	describeTopic := kmsg.NewDescribeProducersRequestTopic()
+	describeTopic.Topic = "foo"
+	describeTopic.Partitions = []int32{0, 1, 2}
+	describeReq.Topics = append(describeReq.Topics, describeTopic)
+	kresp, err := cl.Request(context.Background(), describeReq)
+	if err != nil {
+		t.Fatalf("unexpected describe producers err: %v", err)
+	}
+	describeResp := kresp.(*kmsg.DescribeProducersResponse)
+	if len(describeResp.Topics) != 1 {
+		t.Fatalf("got %d describe producers topics, exp 1", len(describeResp.Topics))
+	}
+	partErrs := make(map[int32]int16)
+	for _, p := range describeResp.Topics[0].Partitions {
+		partErrs[p.Partition] = p.ErrorCode
+	}
+	expPartErrs := map[int32]int16{0: 0, 1: 0, 2: kerr.UnknownTopicOrPartition.Code}
+	if !reflect.DeepEqual(partErrs, expPartErrs) {
+		t.Errorf("got describe producers partition errors %v, exp %v", partErrs, expPartErrs)
+	}
+
+	// ListTransactions goes to every broker.
+	kresp, err = cl.Request(context.Background(), kmsg.NewPtrListTransactionsRequest())
+	if err != nil {
+		t.Fatalf("unexpected list transactions err: %v", err)
+	}
+	listResp := kresp.(*kmsg.ListTransactionsResponse)
+	var txnIDs []string
+	for _, state := range listResp.TransactionStates {
+		txnIDs = append(txnIDs, state.TransactionalID)
+	}
+	sort.Strings(txnIDs)
+	if exp := []string{addr1, addr2}; !reflect.DeepEqual(txnIDs, exp) {
+		t.Errorf("got listed transactions %v, exp %v", txnIDs, exp)
+	}
+	if exp := []string{"Bogus"}; !reflect.DeepEqual(listResp.UnknownStateFilters, exp) {
+		t.Errorf("got unknown state filters %v, exp %v", listResp.UnknownStateFilters, exp)
+	}
+}
@@ -0,0 +1,208 @@
+package kgo
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/kafka-go/pkg/kerr"
+	"github.com/twmb/kafka-go/pkg/kmsg"
+	"github.com/twmb/kafka-go/pkg/kversion"
+)
+
+// clusterVersions tracks the min versions supported across all brokers for
+// PinMinClusterVersions.
+type clusterVersions struct {
+	// mu guards gen, which is bumped every time we begin recomputing.
+	// Only the latest recompute stores its result.
+	mu  sync.Mutex
+	gen uint64
+
+	versions atomic.Value // kversion.Versions
+}
+
+// stale returns whether a recompute newer than gen has begun.
+func (cv *clusterVersions) stale(gen uint64) bool {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	return gen != cv.gen
+}
+
+// load returns the pinned cluster versions, if any have been computed.
+func (cv *clusterVersions) load() (kversion.Versions, bool) {
+	vs, ok := cv.versions.Load().(kversion.Versions)
+	return vs, ok
+}
+
+// pinClusterVersion caps ourMax for key to the cluster min version, returning
+// false if some broker in the cluster does not support the key at all. If
+// cluster versions have not been computed yet, this returns ourMax.
+//
+// ApiVersions is never pinned: it is how we learn versions in the first place.
+func (cl *Client) pinClusterVersion(key, ourMax int16) (int16, bool) {
+	if !cl.cfg.pinClusterVersions || key == 18 {
+		return ourMax, true
+	}
+	vs, ok := cl.clusterVersions.load()
+	if !ok {
+		return ourMax, true
+	}
+	pinned := int16(-1)
+	if int(key) < len(vs) {
+		pinned = vs[key]
+	}
+	if pinned < 0 {
+		return 0, false
+	}
+	if pinned < ourMax {
+		return pinned, true
+	}
+	return ourMax, true
+}
+
+// recomputeClusterVersions, if pinning cluster versions, concurrently issues
+// ApiVersions to every broker in the latest metadata and pins requests to the
+// min version across all of them. Requests to individual brokers are retried
+// with backoff; if any broker still fails, the old pin is kept.
+//
+// This is called whenever brokers join or leave the cluster, and whenever a
+// reconnect to a broker sees the broker's versions change (i.e., the broker
+// was upgraded in place).
+func (cl *Client) recomputeClusterVersions() {
+	if !cl.cfg.pinClusterVersions {
+		return
+	}
+	cv := &cl.clusterVersions
+	cv.mu.Lock()
+	cv.gen++
+	gen := cv.gen
+	cv.mu.Unlock()
+
+	go func() {
+		cl.brokersMu.RLock()
+		var brokers []*broker
+		for id, b := range cl.brokers {
+			if id >= 0 { // seeds are also in the metadata under their real IDs
+				brokers = append(brokers, b)
+			}
+		}
+		cl.brokersMu.RUnlock()
+		if len(brokers) == 0 {
+			return
+		}
+
+		var (
+			wg    sync.WaitGroup
+			resps = make([]*kmsg.ApiVersionsResponse, len(brokers))
+			errs  = make([]error, len(brokers))
+		)
+		for i, b := range brokers {
+			wg.Add(1)
+			go func(i int, b *broker) {
+				defer wg.Done()
+				resps[i], errs[i] = cl.brokerApiVersions(b, gen)
+			}(i, b)
+		}
+		wg.Wait()
+
+		var (
+			min kversion.Versions
+
+			// epochs tracks the KIP-584 finalized features epoch of
+			// every broker that reports one.
+			epochs       = make(map[int64]struct{})
+			missingEpoch bool
+		)
+		for i, resp := range resps {
+			if err := errs[i]; err != nil {
+				cl.cfg.logger.Log(LogLevelWarn, "unable to load broker api versions to pin cluster versions, keeping old pin",
+					"broker", brokers[i].id,
+					"err", err,
+				)
+				return
+			}
+			min = minVersions(min, kversion.FromApiVersionsResponse(resp))
+			if resp.Version >= 3 && resp.FinalizedFeaturesEpoch >= 0 {
+				epochs[resp.FinalizedFeaturesEpoch] = struct{}{}
+			} else {
+				missingEpoch = true
+			}
+		}
+
+		cv.mu.Lock()
+		defer cv.mu.Unlock()
+		if gen != cv.gen {
+			return // a newer recompute is in progress
+		}
+
+		// If any broker reports finalized features, every broker must
+		// report the same epoch before we allow the pin to rise. If
+		// brokers disagree, some broker has a stale view of the cluster
+		// and may be mid upgrade; we only allow the pin to drop.
+		old, hadOld := cv.load()
+		if cl.cfg.checkFinalizedFeatures && len(epochs) > 0 && (len(epochs) > 1 || missingEpoch) && hadOld {
+			cl.cfg.logger.Log(LogLevelInfo, "brokers disagree on finalized features epoch, not raising pinned cluster versions")
+			min = minVersions(min, old)
+		}
+		cv.versions.Store(min)
+		cl.cfg.logger.Log(LogLevelDebug, "pinned cluster versions", "versions", min.VersionGuess())
+	}()
+}
+
+// brokerApiVersions issues ApiVersions to b for recomputing cluster versions,
+// retrying retriable errors with backoff until the request succeeds, we hit
+// our retry limits, or a newer recompute begins.
+func (cl *Client) brokerApiVersions(b *broker, gen uint64) (*kmsg.ApiVersionsResponse, error) {
+	tries := 0
+	const key = 18 // api versions request key
+	tryStart := time.Now()
+	retryTimeout := cl.cfg.retryTimeout(key)
+start:
+	tries++
+	req := kmsg.NewPtrApiVersionsRequest()
+	req.ClientSoftwareName = cl.cfg.softwareName
+	req.ClientSoftwareVersion = cl.cfg.softwareVersion
+	kresp, err := b.waitResp(cl.ctx, req)
+	if err == nil {
+		err = kerr.ErrorForCode(kresp.(*kmsg.ApiVersionsResponse).ErrorCode)
+	}
+	if err != nil {
+		if retryTimeout > 0 && time.Since(tryStart) > retryTimeout || cl.clusterVersions.stale(gen) {
+			return nil, err
+		}
+		if err == ErrConnDead && tries < cl.cfg.brokerConnDeadRetries || (kerr.IsRetriable(err) || isRetriableBrokerErr(err)) && tries < cl.cfg.retries {
+			if ok := cl.waitTries(cl.ctx, tries); ok {
+				goto start
+			}
+		}
+		return nil, err
+	}
+	return kresp.(*kmsg.ApiVersionsResponse), nil
+}
+
+// minVersions returns the min version per key across l and r. Keys missing
+// from either are not supported (-1). If l is nil, this returns r.
+func minVersions(l, r kversion.Versions) kversion.Versions {
+	if l == nil {
+		return r
+	}
+	n := len(l)
+	if len(r) > n {
+		n = len(r)
+	}
+	min := make(kversion.Versions, n)
+	for k := range min {
+		lv, rv := int16(-1), int16(-1)
+		if k < len(l) {
+			lv = l[k]
+		}
+		if k < len(r) {
+			rv = r[k]
+		}
+		if rv < lv {
+			lv = rv
+		}
+		min[k] = lv
+	}
+	return min
+}
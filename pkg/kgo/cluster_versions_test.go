@@ -0,0 +1,151 @@
+package kgo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/twmb/kafka-go/pkg/kerr"
+	"github.com/twmb/kafka-go/pkg/kmsg"
+)
+
+func TestPinMinClusterVersions(t *testing.T) {
+	const (
+		addr1 = "127.0.0.1:1"
+		addr2 = "127.0.0.1:2"
+	)
+	apiVersions := func(addr string, describeGroupsMax int16, extra ...kmsg.ApiVersionsResponseApiKey) []WireEntry {
+		body := (&kmsg.ApiVersionsResponse{
+			Version: 3,
+			ApiKeys: append([]kmsg.ApiVersionsResponseApiKey{
+				{ApiKey: 3, MaxVersion: 1},
+				{ApiKey: 15, MaxVersion: describeGroupsMax},
+				{ApiKey: 18, MaxVersion: 3},
+			}, extra...),
+		}).AppendTo(nil)
+		var es []WireEntry
+		for i := 0; i < 3; i++ { // connection init and recomputes
+			es = append(es, WireEntry{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: body})
+		}
+		return es
+	}
+	entries := append(apiVersions(addr1, 3, kmsg.ApiVersionsResponseApiKey{ApiKey: 16, MaxVersion: 3}), apiVersions(addr2, 1)...)
+	entries = append(entries, WireEntry{Addr: addr1, IsResponse: true, Key: 3, Version: 1, Body: (&kmsg.MetadataResponse{
+		Version: 1,
+		Brokers: []kmsg.MetadataResponseBroker{
+			{NodeID: 1, Host: "127.0.0.1", Port: 1},
+			{NodeID: 2, Host: "127.0.0.1", Port: 2},
+		},
+		ControllerID: 1,
+	}).AppendTo(nil)})
+
+	r, err := NewWireReplayer(entries)
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+
+	cl, err := NewClient(SeedBrokers(addr1), Dialer(r.Dial), PinMinClusterVersions())
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	if max, ok := cl.pinClusterVersion(15, 5); !ok || max != 5 {
+		t.Errorf("got pinned %d, %v before metadata, exp 5, true", max, ok)
+	}
+	if err := cl.fetchBrokerMetadata(context.Background()); err != nil {
+		t.Fatalf("unable to fetch metadata: %v", err)
+	}
+
+	waitPinnedClusterVersions(t, cl)
+
+	if max, ok := cl.pinClusterVersion(15, 5); !ok || max != 1 {
+		t.Errorf("got pinned describe groups %d, %v, exp 1, true", max, ok)
+	}
+	if _, ok := cl.pinClusterVersion(16, 3); ok {
+		t.Error("list groups is not supported by every broker, but was not rejected")
+	}
+	if max, ok := cl.pinClusterVersion(18, 3); !ok || max != 3 {
+		t.Errorf("got pinned api versions %d, %v, exp unpinned 3, true", max, ok)
+	}
+}
+
+func TestPinMinClusterVersionsRetriesFailingBroker(t *testing.T) {
+	const (
+		addr1 = "127.0.0.1:1"
+		addr2 = "127.0.0.1:2"
+	)
+	apiVersions := func(addr string, errCode, describeGroupsMax int16) WireEntry {
+		return WireEntry{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: (&kmsg.ApiVersionsResponse{
+			Version:   3,
+			ErrorCode: errCode,
+			ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+				{ApiKey: 3, MaxVersion: 1},
+				{ApiKey: 15, MaxVersion: describeGroupsMax},
+				{ApiKey: 18, MaxVersion: 3},
+			},
+		}).AppendTo(nil)}
+	}
+	entries := []WireEntry{
+		// The seed and broker 1 share addr1: two connection inits
+		// and the recompute.
+		apiVersions(addr1, 0, 3),
+		apiVersions(addr1, 0, 3),
+		apiVersions(addr1, 0, 3),
+
+		// Broker 2 fails its first recompute request.
+		apiVersions(addr2, 0, 1),
+		apiVersions(addr2, kerr.RequestTimedOut.Code, 1),
+		apiVersions(addr2, 0, 1),
+
+		{Addr: addr1, IsResponse: true, Key: 3, Version: 1, Body: (&kmsg.MetadataResponse{
+			Version: 1,
+			Brokers: []kmsg.MetadataResponseBroker{
+				{NodeID: 1, Host: "127.0.0.1", Port: 1},
+				{NodeID: 2, Host: "127.0.0.1", Port: 2},
+			},
+			ControllerID: 1,
+		}).AppendTo(nil)},
+	}
+
+	r, err := NewWireReplayer(entries)
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+
+	cl, err := NewClient(
+		SeedBrokers(addr1),
+		Dialer(r.Dial),
+		PinMinClusterVersions(),
+		RetryBackoff(func(int) time.Duration { return time.Millisecond }),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	if err := cl.fetchBrokerMetadata(context.Background()); err != nil {
+		t.Fatalf("unable to fetch metadata: %v", err)
+	}
+	waitPinnedClusterVersions(t, cl)
+
+	if max, ok := cl.pinClusterVersion(15, 5); !ok || max != 1 {
+		t.Errorf("got pinned describe groups %d, %v, exp 1, true", max, ok)
+	}
+}
+
+func waitPinnedClusterVersions(t *testing.T, cl *Client) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := cl.clusterVersions.load(); ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cluster versions were never pinned")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
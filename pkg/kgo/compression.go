@@ -1,17 +1,11 @@
 package kgo
 
 import (
-	"bytes"
 	"compress/gzip"
-	"encoding/binary"
 	"errors"
-	"io/ioutil"
-	"runtime"
 	"sync"
 
-	"github.com/golang/snappy"
-	"github.com/klauspost/compress/zstd"
-	"github.com/pierrec/lz4"
+	"github.com/twmb/kafka-go/pkg/internal/compress"
 )
 
 // NOTE: level configuration was removed at some point due to it likely being
@@ -70,11 +64,10 @@ func (c CompressionCodec) WithLevel(level int) CompressionCodec {
 	return c
 }
 
+// compressor compresses with the first of its codecs that a produce request
+// version supports.
 type compressor struct {
-	options  []int8
-	gzPool   sync.Pool
-	lz4Pool  sync.Pool
-	zstdPool sync.Pool
+	options []*compress.Compressor // in order of preference
 }
 
 func newCompressor(codecs ...CompressionCodec) (*compressor, error) {
@@ -101,199 +94,45 @@ func newCompressor(codecs ...CompressionCodec) (*compressor, error) {
 	}
 
 	c := new(compressor)
-
-out:
 	for _, codec := range codecs {
-		c.options = append(c.options, codec.codec)
-		switch codec.codec {
-		case 0:
-			break out
-		case 1:
-			level := codec.level
-			if _, err := gzip.NewWriterLevel(nil, int(level)); err != nil {
-				level = gzip.DefaultCompression
-			}
-			c.gzPool = sync.Pool{New: func() interface{} { c, _ := gzip.NewWriterLevel(nil, int(level)); return c }}
-		case 3:
-			level := codec.level
-			if level < 0 {
-				level = 0
-			}
-			c.lz4Pool = sync.Pool{New: func() interface{} { w := new(lz4.Writer); w.Header.CompressionLevel = int(level); return w }}
-		case 4:
-			level := zstd.EncoderLevel(codec.level)
-			c.zstdPool = sync.Pool{
-				New: func() interface{} {
-					zstdEnc, err := zstd.NewWriter(nil,
-						zstd.WithEncoderLevel(level),
-						zstd.WithEncoderConcurrency(1))
-					if err != nil {
-						zstdEnc, _ = zstd.NewWriter(nil,
-							zstd.WithEncoderConcurrency(1))
-					}
-					r := &zstdEncoder{zstdEnc}
-					runtime.SetFinalizer(r, func(r *zstdEncoder) {
-						r.inner.Close()
-					})
-					return r
-				},
-			}
+		if codec.codec == 0 {
+			break // passthrough; later codecs are never used
+		}
+		option, err := compress.NewCompressor(compress.Codec(codec.codec), codec.level)
+		if err != nil {
+			return nil, err
 		}
+		c.options = append(c.options, option)
 	}
 
-	if c.options[0] == 0 {
+	if len(c.options) == 0 {
 		return nil, nil // first codec was passthrough
 	}
 
 	return c, nil
 }
 
-type zstdEncoder struct {
-	inner *zstd.Encoder
-}
-
 // Compress compresses src to buf, returning buf's inner slice once done or nil
 // if an error is encountered.
 //
 // The writer should be put back to its pool after the returned slice is done
 // being used.
 func (c *compressor) compress(dst *sliceWriter, src []byte, produceRequestVersion int16) ([]byte, int8) {
-	dst.inner = dst.inner[:0]
-
-	var use int8
 	for _, option := range c.options {
-		if option == 4 && produceRequestVersion < 7 {
+		if option.Codec() == compress.Zstd && produceRequestVersion < 7 {
 			continue
 		}
-		use = option
-		break
-	}
-
-	switch use {
-	case 0:
-		return src, 0
-	case 1:
-		gz := c.gzPool.Get().(*gzip.Writer)
-		defer c.gzPool.Put(gz)
-		gz.Reset(dst)
-		if _, err := gz.Write(src); err != nil {
-			return nil, -1
-		}
-		if err := gz.Close(); err != nil {
+		compressed, err := option.CompressTo(dst.inner, src)
+		if err != nil {
 			return nil, -1
 		}
-
-	case 2:
-		dst.inner = snappy.Encode(dst.inner[:cap(dst.inner)], src)
-
-	case 3:
-		lz := c.lz4Pool.Get().(*lz4.Writer)
-		defer c.lz4Pool.Put(lz)
-		lz.Reset(dst)
-		if _, err := lz.Write(src); err != nil {
-			return nil, -1
-		}
-		if err := lz.Close(); err != nil {
-			return nil, -1
-		}
-	case 4:
-		zstdEnc := c.zstdPool.Get().(*zstdEncoder)
-		defer c.zstdPool.Put(zstdEnc)
-		dst.inner = zstdEnc.inner.EncodeAll(src, dst.inner)
+		dst.inner = compressed
+		return dst.inner, int8(option.Codec())
 	}
-
-	return dst.inner, int8(use)
+	return src, 0
 }
 
-type decompressor struct {
-	ungzPool   sync.Pool
-	unlz4Pool  sync.Pool
-	unzstdPool sync.Pool
-}
+// decompressor decompresses fetched record batches and message sets.
+type decompressor = compress.Decompressor
 
-func newDecompressor() *decompressor {
-	d := &decompressor{
-		ungzPool: sync.Pool{
-			New: func() interface{} { return new(gzip.Reader) },
-		},
-		unlz4Pool: sync.Pool{
-			New: func() interface{} { return new(lz4.Reader) },
-		},
-		unzstdPool: sync.Pool{
-			New: func() interface{} {
-				zstdDec, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
-				r := &zstdDecoder{zstdDec}
-				runtime.SetFinalizer(r, func(r *zstdDecoder) {
-					r.inner.Close()
-				})
-				return r
-			},
-		},
-	}
-	return d
-}
-
-type zstdDecoder struct {
-	inner *zstd.Decoder
-}
-
-func (d *decompressor) decompress(src []byte, codec byte) ([]byte, error) {
-	switch codec {
-	case 0:
-		return src, nil
-	case 1:
-		ungz := d.ungzPool.Get().(*gzip.Reader)
-		defer d.ungzPool.Put(ungz)
-		if err := ungz.Reset(bytes.NewReader(src)); err != nil {
-			return nil, err
-		}
-		return ioutil.ReadAll(ungz)
-	case 2:
-		if len(src) > 16 && bytes.HasPrefix(src, xerialPfx) {
-			return xerialDecode(src)
-		}
-		return snappy.Decode(nil, src)
-	case 3:
-		unlz4 := d.unlz4Pool.Get().(*lz4.Reader)
-		defer d.unlz4Pool.Put(unlz4)
-		unlz4.Reset(bytes.NewReader(src))
-		return ioutil.ReadAll(unlz4)
-	case 4:
-		unzstd := d.unzstdPool.Get().(*zstdDecoder)
-		defer d.unzstdPool.Put(unzstd)
-		unzstd.inner.Reset(bytes.NewReader(src))
-		return unzstd.inner.DecodeAll(src, nil)
-	default:
-		return nil, errors.New("unknown compression codec")
-	}
-}
-
-var xerialPfx = []byte{130, 83, 78, 65, 80, 80, 89, 0}
-
-var errMalformedXerial = errors.New("malformed xerial framing")
-
-func xerialDecode(src []byte) ([]byte, error) {
-	// bytes 0-8: xerial header
-	// bytes 8-16: xerial version
-	// everything after: uint32 chunk size, snappy chunk
-	// we come into this function knowing src is at least 16
-	src = src[16:]
-	var dst, chunk []byte
-	var err error
-	for len(src) > 0 {
-		if len(src) < 4 {
-			return nil, errMalformedXerial
-		}
-		size := int32(binary.BigEndian.Uint32(src))
-		src = src[4:]
-		if size < 0 || len(src) < int(size) {
-			return nil, errMalformedXerial
-		}
-		if chunk, err = snappy.Decode(chunk[:cap(chunk)], src[:size]); err != nil {
-			return nil, err
-		}
-		src = src[size:]
-		dst = append(dst, chunk...)
-	}
-	return dst, nil
-}
+func newDecompressor() *decompressor { return compress.NewDecompressor() }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"sync"
 	"testing"
+
+	"github.com/twmb/kafka-go/pkg/internal/compress"
 )
 
 func TestNewCompressor(t *testing.T) {
@@ -70,7 +72,7 @@ func TestCompressDecompress(t *testing.T) {
 					defer sliceWriters.Put(w)
 					got, used := c.compress(w, in, produceVersion)
 
-					got, err := d.decompress(got, byte(used))
+					got, err := d.Decompress(got, compress.Codec(used))
 					if err != nil {
 						t.Errorf("unexpected decompress err: %v", err)
 						return
@@ -95,3 +97,24 @@ func BenchmarkCompress(b *testing.B) {
 		sliceWriters.Put(w)
 	}
 }
+
+func TestDecompressTo(t *testing.T) {
+	d := newDecompressor()
+	in := bytes.Repeat([]byte("foo"), 1000)
+	for _, codec := range []CompressionCodec{{codec: 1}, {codec: 2}, {codec: 3}, {codec: 4}} {
+		c, _ := newCompressor(codec)
+		w := sliceWriters.Get().(*sliceWriter)
+		compressed, used := c.compress(w, in, 7)
+
+		dst := make([]byte, 0, 10) // too small: must grow
+		got, err := d.DecompressTo(dst, compressed, compress.Codec(used))
+		sliceWriters.Put(w)
+		if err != nil {
+			t.Errorf("codec %d: unexpected decompress err: %v", used, err)
+			continue
+		}
+		if !bytes.Equal(got, in) {
+			t.Errorf("codec %d: got decompress %s != exp %s", used, got, in)
+		}
+	}
+}
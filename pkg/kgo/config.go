@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"net"
 	"sync"
 	"time"
 
+	"github.com/twmb/kafka-go/pkg/kmsg"
 	"github.com/twmb/kafka-go/pkg/kversion"
 	"github.com/twmb/kafka-go/pkg/sasl"
 )
@@ -57,12 +59,23 @@ type cfg struct {
 	seedBrokers []string
 	maxVersions kversion.Versions
 
+	strictRequestVersions bool
+
+	wireRecorder *wireRecorder
+
+	pinClusterVersions     bool
+	checkFinalizedFeatures bool
+
 	retryBackoff          func(int) time.Duration
 	retries               int
 	retryTimeout          func(int16) time.Duration
 	brokerConnDeadRetries int
 
-	maxBrokerWriteBytes int32
+	maxBrokerWriteBytes  int32
+	normalConnsPerBroker int
+	connIdleTimeout      time.Duration
+
+	interceptors []func(context.Context, BrokerMetadata, kmsg.Request, func() (kmsg.Response, error)) (kmsg.Response, error)
 
 	allowAutoTopicCreation bool
 
@@ -94,9 +107,12 @@ type cfg struct {
 	maxBytes       int32
 	maxPartBytes   int32
 	resetOffset    Offset
+	onOutOfRange   func(string, int32, int64) Offset
 	isolationLevel int8
 	keepControl    bool
 	rack           string
+	zeroCopy       bool
+	streamFetches  bool
 }
 
 // TODO strengthen?
@@ -117,6 +133,10 @@ func (cfg *cfg) validate() error {
 		return fmt.Errorf("max record batch bytes %d is less than min acceptable %d", cfg.maxRecordBatchBytes, 1<<10)
 	}
 
+	if cfg.normalConnsPerBroker < 1 {
+		return fmt.Errorf("normal conns per broker %d is less than min acceptable 1", cfg.normalConnsPerBroker)
+	}
+
 	if cfg.maxBrokerWriteBytes < cfg.maxRecordBatchBytes {
 		return fmt.Errorf("max broker write bytes %d is erroneously less than max record batch bytes %d",
 			cfg.maxBrokerWriteBytes, cfg.maxRecordBatchBytes)
@@ -176,7 +196,8 @@ func defaultCfg() cfg {
 		},
 		brokerConnDeadRetries: 20,
 
-		maxBrokerWriteBytes: 100 << 20, // Kafka socket.request.max.bytes default is 100<<20
+		maxBrokerWriteBytes:  100 << 20, // Kafka socket.request.max.bytes default is 100<<20
+		normalConnsPerBroker: 1,
 
 		metadataMaxAge: 5 * time.Minute,
 		metadataMinAge: 10 * time.Second,
@@ -264,10 +285,37 @@ func Dialer(fn func(context.Context, string) (net.Conn, error)) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.dialFn = fn }}
 }
 
+// RecordWire records every request the client writes and every response
+// the client reads to w, which can be read back with ReadWireEntries and
+// replayed with a WireReplayer. Each entry is framed with its size and
+// includes the broker, request key, version, correlation ID, and timestamp.
+//
+// Writes to w are serialized. If a write fails, recording stops and the
+// error is logged. Fetch responses are not streamed while recording (see
+// StreamFetchResponses).
+//
+// SASL credentials are never recorded: the auth bytes of SASLAuthenticate
+// requests and responses are cleared before recording, and unframed v0 SASL
+// exchanges are not recorded at all. Everything else is recorded as is, so
+// treat a recording as being as sensitive as the data the client reads and
+// writes.
+//
+// This is meant for capturing a session to debug or to replay in tests;
+// recording every request and response is expensive.
+func RecordWire(w io.Writer) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.wireRecorder = &wireRecorder{w: w} }}
+}
+
 // SeedBrokers sets the seed brokers for the client to use, overriding the
 // default 127.0.0.1:9092.
 //
 // Any seeds that are missing a port use the default Kafka port 9092.
+//
+// Seeds are dialed by hostname. The default dialer, like net.Dialer, resolves
+// the hostname on every dial and tries each of its A and AAAA records; a
+// custom Dialer receives the hostname as is. If every broker the client knows of fails to
+// connect, the client falls back to only the seeds, which allows the client
+// to recover if a cluster behind a DNS name is fully replaced.
 func SeedBrokers(seeds ...string) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.seedBrokers = append(cfg.seedBrokers[:0], seeds...) }}
 }
@@ -285,6 +333,58 @@ func MaxVersions(versions kversion.Versions) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.maxVersions = versions }}
 }
 
+// PinMinClusterVersions caps every request to the min version that every
+// broker in the cluster supports, rather than the max version that the
+// broker a request is issued to supports.
+//
+// During a rolling upgrade, some brokers support newer request versions than
+// others. By default, the client uses new features with upgraded brokers and
+// not with others, which can be surprising. With this option, the client
+// issues ApiVersions to every broker in the latest metadata and uses the min
+// version per key across all of them, failing requests for keys that some
+// broker does not support with ErrUnknownRequestKey. The min is recomputed
+// whenever brokers join or leave the cluster and whenever a reconnect to a
+// broker sees that the broker's versions changed. Brokers are queried
+// concurrently, and failed queries are retried per RequestRetries and
+// RetryBackoff; if a broker still cannot be queried, the old min is kept.
+//
+// Until the first metadata load completes, requests are capped only by the
+// broker they are issued to. This is an alternative to manually pinning
+// MaxVersions, and both can be used together.
+func PinMinClusterVersions() Opt {
+	return clientOpt{func(cfg *cfg) { cfg.pinClusterVersions = true }}
+}
+
+// CheckFinalizedFeatures, when used with PinMinClusterVersions, only allows
+// the pinned versions to rise once every broker reports the same KIP-584
+// finalized features epoch in its ApiVersions response. Brokers that disagree
+// on the epoch have an inconsistent view of the cluster's finalized features
+// and are likely mid upgrade. The pinned versions can always drop.
+//
+// Brokers that do not expose finalized features (Kafka before 2.7.0) do not
+// affect the pin unless some other broker does expose them.
+func CheckFinalizedFeatures() Opt {
+	return clientOpt{func(cfg *cfg) { cfg.checkFinalizedFeatures = true }}
+}
+
+// StrictRequestVersions validates every request issued with Client.Request or
+// Broker.Request after its version has been negotiated with a broker and fails
+// the request, rather than issuing it, if any field that is set would not be
+// encoded at that version. Fields that Kafka marks as ignorable are allowed to
+// be dropped.
+//
+// Requests that the client builds for itself (metadata, group management,
+// producing, and so on) are not validated; these are built to work against
+// all broker versions.
+//
+// By default, fields that a broker's version does not support are silently
+// not written. This option is useful to detect when pinning MaxVersions or
+// talking to an older broker drops fields that you set. Validation failures
+// are returned as *kmsg.FieldVersionError.
+func StrictRequestVersions() Opt {
+	return clientOpt{func(cfg *cfg) { cfg.strictRequestVersions = true }}
+}
+
 // RetryBackoff sets the backoff strategy for how long to backoff for a given
 // amount of retries, overriding the default exponential backoff that ranges
 // from 100ms min to 1s max.
@@ -360,6 +460,65 @@ func BrokerMaxWriteBytes(v int32) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.maxBrokerWriteBytes = v }}
 }
 
+// NormalConnsPerBroker sets the maximum number of connections the client
+// opens to each broker for "normal" requests, overriding the default 1.
+//
+// The client always uses dedicated connections for produce requests and fetch
+// requests. Join and sync group requests, which can wait on the broker for up
+// to the group's rebalance timeout, are isolated on a connection of their own,
+// but that connection is only opened if a join or sync is issued while every
+// normal connection is busy; otherwise, an idle normal connection is borrowed.
+// All other requests, including anything issued through Client.Request or
+// Broker.Request, go through a pool of normal connections. Kafka processes
+// requests on a single connection one at a time, meaning a slow request blocks
+// everything behind it. Raising this allows many concurrent requests to a
+// single broker to not queue behind each other.
+//
+// Additional connections are only opened once every existing connection has
+// requests in flight.
+func NormalConnsPerBroker(n int) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.normalConnsPerBroker = n }}
+}
+
+// ConnIdleTimeout sets how long a broker connection can be idle before the
+// client closes it, overriding the default of never closing idle connections.
+//
+// A connection is idle if it has no requests in flight and has not been
+// written to or read from within the timeout. Closed connections are lazily
+// reopened the next time a request needs them.
+//
+// Brokers close connections that are idle longer than their
+// connections.max.idle.ms, which defaults to 10m. Setting this a bit lower
+// than the broker's setting avoids requests failing with ErrConnDead on
+// connections the broker has already closed. The Java client uses 9m.
+func ConnIdleTimeout(timeout time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.connIdleTimeout = timeout }}
+}
+
+// RequestInterceptor adds a function that wraps every request the client
+// issues to a broker, including the client's own produce and fetch requests.
+// This option can be used multiple times; the first interceptor is the
+// outermost.
+//
+// The interceptor is given the broker the request is being issued to and the
+// request, and must call next to actually issue the request. An interceptor
+// can return early without calling next to fail a request (for example, to
+// forbid DeleteTopics), can inspect or modify the response, and can call
+// other code around next for logging or tracing. Next must be called at most
+// once, and it should be called promptly: the client waits for next (or for
+// the interceptor to return) before issuing further requests to the broker,
+// which keeps requests ordered.
+//
+// Requests are intercepted per broker, after the client has chosen which
+// broker to issue the request to. A request that the client retries or splits
+// across brokers is intercepted once per attempt and per broker. The client's
+// internal produce and fetch requests are not kmsg.ProduceRequest or
+// kmsg.FetchRequest, but they do have the same Key. Requests issued while
+// initializing a connection (ApiVersions and SASL) are not intercepted.
+func RequestInterceptor(fn func(ctx context.Context, broker BrokerMetadata, req kmsg.Request, next func() (kmsg.Response, error)) (kmsg.Response, error)) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.interceptors = append(cfg.interceptors, fn) }}
+}
+
 // MetadataMaxAge sets the maximum age for the client's cached metadata,
 // overriding the default 5m, to allow detection of new topics, partitions,
 // etc.
@@ -614,10 +773,54 @@ func FetchMaxPartitionBytes(b int32) ConsumerOpt {
 // ConsumeResetOffset sets the offset to restart consuming from when a
 // partition has no commits (for groups) or when a fetch sees an
 // OffsetOutOfRange error, overriding the default ConsumeStartOffset.
+// OnOffsetOutOfRange, if used, takes precedence for OffsetOutOfRange errors.
 func ConsumeResetOffset(offset Offset) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.resetOffset = offset }}
 }
 
+// OnOffsetOutOfRange sets a policy for which offset to reset to when a fetch
+// sees an OffsetOutOfRange error, overriding the default of using the
+// ConsumeResetOffset. The function is called with the topic, partition, and
+// the offset that was out of range, and is called in the goroutine that
+// processes fetch responses; it must not block.
+//
+// Use FailOffsetOutOfRange as the policy (or return its result from your own
+// policy) to stop consuming the partition instead of resetting.
+//
+// Regardless of the policy, the OffsetOutOfRange error is returned in the
+// fetch for the partition.
+func OnOffsetOutOfRange(fn func(topic string, partition int32, offset int64) Offset) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.onOutOfRange = fn }}
+}
+
+// FailOffsetOutOfRange is an OnOffsetOutOfRange policy that does not reset
+// the offset. Instead, the partition stops being consumed, leaving the
+// OffsetOutOfRange error in FetchPartition.Err as the last thing returned for
+// the partition.
+//
+// To resume a stopped partition, direct consumers reassign it with
+// AssignPartitions, and group consumers set its offset with SetOffsets. Group
+// consumers cannot rely on a rebalance to resume the partition: cooperative
+// balancers never reassign partitions that a member keeps.
+//
+// Silently resetting can hide data loss, such as when records are deleted by
+// retention before they are consumed. This policy ensures that the loss is
+// seen.
+func FailOffsetOutOfRange(string, int32, int64) Offset {
+	o := NewOffset()
+	o.noReset = true
+	return o
+}
+
+// outOfRangeOffset returns the offset to reset to for an out of range offset
+// in a partition, per the configured policy.
+func (cfg *cfg) outOfRangeOffset(topic string, partition int32, offset int64) Offset {
+	if cfg.onOutOfRange != nil {
+		return cfg.onOutOfRange(topic, partition, offset)
+	}
+	return cfg.resetOffset
+}
+
 // Rack specifies where the client is physically located and changes fetch
 // requests to consume from the closest replica as opposed to the leader
 // replica.
@@ -654,3 +857,40 @@ func FetchIsolationLevel(level IsolationLevel) ConsumerOpt {
 func KeepControlRecords() ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.keepControl = true }}
 }
+
+// ZeroCopyFetches reads fetch responses and decompresses record batches into
+// pooled buffers, and returns records whose keys, values, and header values
+// alias those buffers rather than newly allocated memory.
+//
+// The buffers backing a Fetch are valid until Fetches.Release is called, at
+// which point they are reused for future fetches. Records (or their keys,
+// values, and header values) must not be used after releasing the fetches
+// they came from; copy anything that must outlive the release. If fetches are
+// never released, their buffers are simply garbage collected.
+//
+// Records from a record batch are also allocated together, so any record from
+// a batch that is still referenced keeps every record in that batch alive.
+//
+// Header keys are always copied. This option takes precedence over
+// StreamFetchResponses.
+func ZeroCopyFetches() ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.zeroCopy = true }}
+}
+
+// StreamFetchResponses decodes fetch responses incrementally as they are read
+// from the connection, rather than reading each entire response into memory
+// before decoding it.
+//
+// Large fetch responses (see FetchMaxBytes) otherwise briefly exist in memory
+// twice: once as the raw response, and again as decompressed records. With
+// this option, each partition's record batches are decompressed as soon as
+// the partition is read and the compressed batches are then dropped, so at
+// most one partition's compressed batches (see FetchMaxPartitionBytes) are
+// held alongside the decompressed records. Uncompressed records alias what
+// was read and are never held twice.
+//
+// Fetch responses from brokers before Kafka 0.11.0 contain message sets,
+// which are still held in full until the response is processed.
+func StreamFetchResponses() ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.streamFetches = true }}
+}
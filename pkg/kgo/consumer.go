@@ -2,7 +2,10 @@ package kgo
 
 import (
 	"context"
+	"fmt"
+	"regexp"
 	"sync"
+	"sync/atomic"
 
 	"github.com/twmb/kafka-go/pkg/kerr"
 	"github.com/twmb/kafka-go/pkg/kmsg"
@@ -14,6 +17,9 @@ type Offset struct {
 	relative     int64
 	epoch        int32
 	currentEpoch int32 // set by us
+
+	afterMilli bool // if true, request is a millisecond timestamp
+	noReset    bool // set by FailOffsetOutOfRange
 }
 
 // NewOffsetcreates and returns an offset to use in AssignPartitions.
@@ -30,6 +36,7 @@ func NewOffset() Offset {
 // to begin at the beginning of a partition.
 func (o Offset) AtStart() Offset {
 	o.request = -2
+	o.afterMilli = false
 	return o
 }
 
@@ -37,6 +44,7 @@ func (o Offset) AtStart() Offset {
 // begin at the end of a partition.
 func (o Offset) AtEnd() Offset {
 	o.request = -1
+	o.afterMilli = false
 	return o
 }
 
@@ -66,6 +74,22 @@ func (o Offset) At(at int64) Offset {
 		at = -2
 	}
 	o.request = at
+	o.afterMilli = false
+	return o
+}
+
+// AfterMilli returns a copy of the calling offset, changing the returned
+// offset to begin at the first record whose timestamp is at or after the
+// given millisecond timestamp. If no record is at or after the timestamp, the
+// offset begins at the end of the partition.
+//
+// This requires Kafka 0.10.1.0+.
+func (o Offset) AfterMilli(millisec int64) Offset {
+	if millisec < 0 {
+		millisec = 0
+	}
+	o.request = millisec
+	o.afterMilli = true
 	return o
 }
 
@@ -111,6 +135,12 @@ type consumer struct {
 	// dead is set when the client closes; this being true means that any
 	// Assign does nothing (aside from unassigning everything prior).
 	dead bool
+
+	// bounds, if consuming with ConsumeUntilEnd or ConsumeUntilOffsets,
+	// tracks where to stop consuming partitions. complete, under the
+	// sourcesReadyMu, is set once all bounded partitions are consumed.
+	bounds   atomic.Value // *consumeBounds
+	complete bool
 }
 
 // fetchSeq is used for fake fetches that have no corresponding cursor.
@@ -119,10 +149,25 @@ type fetchSeq struct {
 	seq uint64
 }
 
+// compileTopicRegexes compiles topic regular expressions for ConsumeTopicsRegex
+// or GroupTopicsRegex, returning an error for the first invalid expression.
+func compileTopicRegexes(exprs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic regular expression %q: %v", expr, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
 // unassignPrior invalidates old assignments, ensures that nothing is assigned,
 // and leaves any group.
 func (c *consumer) unassignPrior() {
 	c.assignPartitions(nil, assignInvalidateAll) // invalidate old assignments
+	c.bounds.Store((*consumeBounds)(nil))
 	if c.typ == consumerTypeGroup {
 		c.typ = consumerTypeUnset
 		c.group.leave()
@@ -172,12 +217,26 @@ func (c *consumer) addFakeReadyForDraining(topic string, partition int32, err er
 	c.sourcesReadyCond.Broadcast()
 }
 
+// completeFetch is the fake fetch returned once bounded consuming completes.
+var completeFetch = Fetch{
+	Topics: []FetchTopic{{
+		Partitions: []FetchPartition{{
+			Partition: -1,
+			Err:       ErrConsumeComplete,
+		}},
+	}},
+}
+
 // PollFetches waits for fetches to be available, returning as soon as any
 // broker returns a fetch. If the ctx quits, this function quits.
 //
 // It is important to check all partition errors in the returned fetches. If
 // any partition has a fatal error and actually had no records, fake fetch will
 // be injected with the error.
+//
+// If consuming is bounded with ConsumeUntilEnd, ConsumeUntilOffsets, or
+// ConsumeBetweenTimes, this returns a fake fetch with ErrConsumeComplete once
+// all bounded partitions are consumed to their end.
 func (cl *Client) PollFetches(ctx context.Context) Fetches {
 	c := &cl.consumer
 	c.fetchMu.Lock()
@@ -205,6 +264,9 @@ func (cl *Client) PollFetches(ctx context.Context) Fetches {
 			fetches = append(fetches, fetch)
 		}
 		c.sourcesReadyForDraining = nil
+		if c.complete {
+			fetches = append(fetches, completeFetch)
+		}
 
 		// Before releasing the sourcesReadyMu, we want to update our
 		// uncommitted. If we updated after, then we could end up with
@@ -234,7 +296,7 @@ func (cl *Client) PollFetches(ctx context.Context) Fetches {
 		defer c.sourcesReadyMu.Unlock()
 		defer close(done)
 
-		for !quit && len(c.sourcesReadyForDraining) == 0 {
+		for !quit && len(c.sourcesReadyForDraining) == 0 && !c.complete {
 			c.sourcesReadyCond.Wait()
 		}
 	}()
@@ -363,6 +425,7 @@ func (c *consumer) assignPartitions(assignments map[string]map[int32]Offset, how
 		}
 		c.fakeReadyForDraining = nil
 		c.sourcesReadyForDraining = nil
+		c.complete = false
 		c.sourcesReadyMu.Unlock()
 
 		c.usingPartitions = keep
@@ -410,7 +473,10 @@ func (c *consumer) assignPartitions(assignments map[string]map[int32]Offset, how
 			// First, if the request is exact, get rid of the relative
 			// portion. We are modifying a copy of the offset, i.e. we
 			// are appropriately not modfying 'assignments' itself.
-			if offset.request >= 0 {
+			//
+			// Timestamp requests are always listed.
+			exact := offset.request >= 0 && !offset.afterMilli
+			if exact {
 				offset.request = offset.request + offset.relative
 				if offset.request < 0 {
 					offset.request = 0
@@ -424,7 +490,7 @@ func (c *consumer) assignPartitions(assignments map[string]map[int32]Offset, how
 			// Otherwise, an epoch is specified without an exact
 			// request which is useless for us, or a request is
 			// specified without a known epoch.
-			if offset.request >= 0 && offset.epoch >= 0 {
+			if exact && offset.epoch >= 0 {
 				c.offsetsWaitingLoad.epoch.setLoadOffset(topic, partition, offset, -1, seq)
 				continue
 			}
@@ -437,7 +503,7 @@ func (c *consumer) assignPartitions(assignments map[string]map[int32]Offset, how
 			// the partition, we list offsets to find out what to
 			// use.
 			part := topicParts.all[partition]
-			if offset.request >= 0 && part != nil {
+			if exact && part != nil {
 				part.cursor.setOffset(part.leaderEpoch, true, offset.request, -1, seq)
 				c.usingPartitions = append(c.usingPartitions, part)
 				continue
@@ -611,7 +677,7 @@ func (c *consumer) doOnMetadataUpdate() {
 	case consumerTypeUnset:
 		return
 	case consumerTypeDirect:
-		c.assignPartitions(c.direct.findNewAssignments(c.cl.loadTopics()), assignWithoutInvalidating)
+		c.assignPartitions(c.withEnds(c.direct.findNewAssignments(c.cl.loadTopics())), assignWithoutInvalidating)
 	case consumerTypeGroup:
 		c.group.findNewAssignments(c.cl.loadTopics())
 	}
@@ -829,7 +895,7 @@ func (c *consumer) tryBrokerOffsetLoadList(broker *broker, load offsetLoadMap) {
 			err := kerr.ErrorForCode(rPartition.ErrorCode)
 			if err != nil {
 				if !kerr.IsRetriable(err) {
-					c.addFakeReadyForDraining(topic, partition, err, waitingPart.seq)
+					c.addFakeReadyForDraining(topic, partition, wrapResponseErr(err, resp.Key(), broker.id, topic, partition), waitingPart.seq)
 					delete(waitingParts, partition)
 				}
 				continue
@@ -841,13 +907,22 @@ func (c *consumer) tryBrokerOffsetLoadList(broker *broker, load offsetLoadMap) {
 				continue // we have not yet loaded the partition
 			}
 
+			// If no record is at or after the requested timestamp,
+			// Kafka replies with offset -1. We keep the partition
+			// loading, but now at the end.
+			if waitingPart.afterMilli && rPartition.Offset == -1 {
+				waitingPart.Offset = waitingPart.Offset.AtEnd()
+				waitingParts[partition] = waitingPart
+				continue
+			}
+
 			delete(waitingParts, partition)
 			if len(waitingParts) == 0 {
 				delete(load, topic)
 			}
 
 			offset := rPartition.Offset + waitingPart.relative
-			if waitingPart.request >= 0 {
+			if waitingPart.request >= 0 && !waitingPart.afterMilli {
 				offset = waitingPart.request + waitingPart.relative
 			}
 			if offset < 0 {
@@ -890,11 +965,9 @@ func (c *consumer) tryBrokerOffsetLoadList(broker *broker, load offsetLoadMap) {
 }
 
 func (o offsetLoadMap) buildListReq(isolationLevel int8) *kmsg.ListOffsetsRequest {
-	req := &kmsg.ListOffsetsRequest{
-		ReplicaID:      -1,
-		IsolationLevel: isolationLevel,
-		Topics:         make([]kmsg.ListOffsetsRequestTopic, 0, len(o)),
-	}
+	req := kmsg.NewPtrListOffsetsRequest()
+	req.IsolationLevel = isolationLevel
+	req.Topics = make([]kmsg.ListOffsetsRequestTopic, 0, len(o))
 	for topic, partitions := range o {
 		parts := make([]kmsg.ListOffsetsRequestTopicPartition, 0, len(partitions))
 		for partition, offset := range partitions {
@@ -902,20 +975,22 @@ func (o offsetLoadMap) buildListReq(isolationLevel int8) *kmsg.ListOffsetsReques
 			// then Assign was called with the partition not
 			// existing. We just use -1 to ensure the partition
 			// is loaded.
+			//
+			// If using a timestamp, we request the timestamp.
 			timestamp := offset.request
-			if timestamp >= 0 {
+			if timestamp >= 0 && !offset.afterMilli {
 				timestamp = -1
 			}
-			parts = append(parts, kmsg.ListOffsetsRequestTopicPartition{
-				Partition:          partition,
-				CurrentLeaderEpoch: offset.currentEpoch, // KIP-320
-				Timestamp:          offset.request,
-			})
+			part := kmsg.NewListOffsetsRequestTopicPartition()
+			part.Partition = partition
+			part.CurrentLeaderEpoch = offset.currentEpoch // KIP-320
+			part.Timestamp = timestamp
+			parts = append(parts, part)
 		}
-		req.Topics = append(req.Topics, kmsg.ListOffsetsRequestTopic{
-			Topic:      topic,
-			Partitions: parts,
-		})
+		reqTopic := kmsg.NewListOffsetsRequestTopic()
+		reqTopic.Topic = topic
+		reqTopic.Partitions = parts
+		req.Topics = append(req.Topics, reqTopic)
 	}
 	return req
 }
@@ -969,7 +1044,7 @@ func (c *consumer) tryBrokerOffsetLoadEpoch(broker *broker, load offsetLoadMap)
 			err := kerr.ErrorForCode(rPartition.ErrorCode)
 			if err != nil {
 				if !kerr.IsRetriable(err) {
-					c.addFakeReadyForDraining(topic, partition, err, waitingPart.seq)
+					c.addFakeReadyForDraining(topic, partition, wrapResponseErr(err, resp.Key(), broker.id, topic, partition), waitingPart.seq)
 					delete(waitingParts, partition)
 				}
 				continue
@@ -1028,26 +1103,25 @@ func (c *consumer) tryBrokerOffsetLoadEpoch(broker *broker, load offsetLoadMap)
 }
 
 func (o offsetLoadMap) buildEpochReq() *kmsg.OffsetForLeaderEpochRequest {
-	req := &kmsg.OffsetForLeaderEpochRequest{
-		ReplicaID: -1,
-		Topics:    make([]kmsg.OffsetForLeaderEpochRequestTopic, 0, len(o)),
-	}
+	req := kmsg.NewPtrOffsetForLeaderEpochRequest()
+	req.ReplicaID = -1 // we are a consumer, not the debugging default of -2
+	req.Topics = make([]kmsg.OffsetForLeaderEpochRequestTopic, 0, len(o))
 	for topic, partitions := range o {
 		parts := make([]kmsg.OffsetForLeaderEpochRequestTopicPartition, 0, len(partitions))
 		for partition, offset := range partitions {
 			if offset.epoch < 0 {
 				panic("we should not be here with negative epochs")
 			}
-			parts = append(parts, kmsg.OffsetForLeaderEpochRequestTopicPartition{
-				Partition:          partition,
-				CurrentLeaderEpoch: offset.currentEpoch,
-				LeaderEpoch:        offset.epoch,
-			})
+			part := kmsg.NewOffsetForLeaderEpochRequestTopicPartition()
+			part.Partition = partition
+			part.CurrentLeaderEpoch = offset.currentEpoch
+			part.LeaderEpoch = offset.epoch
+			parts = append(parts, part)
 		}
-		req.Topics = append(req.Topics, kmsg.OffsetForLeaderEpochRequestTopic{
-			Topic:      topic,
-			Partitions: parts,
-		})
+		reqTopic := kmsg.NewOffsetForLeaderEpochRequestTopic()
+		reqTopic.Topic = topic
+		reqTopic.Partitions = parts
+		req.Topics = append(req.Topics, reqTopic)
 	}
 	return req
 }
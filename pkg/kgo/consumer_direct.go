@@ -1,6 +1,14 @@
 package kgo
 
-import "regexp"
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/twmb/kafka-go/pkg/kerr"
+	"github.com/twmb/kafka-go/pkg/kmsg"
+)
 
 // DirectConsumeOpt is an option to configure direct topic / partition consuming.
 type DirectConsumeOpt interface {
@@ -39,16 +47,99 @@ func ConsumePartitions(partitions map[string]map[int32]Offset) DirectConsumeOpt
 }
 
 // ConsumeTopicsRegex sets all topics in ConsumeTopics to be parsed as regular
-// expressions.
+// expressions. If any expression is invalid, the error is logged and nothing
+// is consumed.
 func ConsumeTopicsRegex() DirectConsumeOpt {
 	return directConsumeOpt{func(cfg *directConsumer) { cfg.regexTopics = true }}
 }
 
+// ConsumeTopicsRegexExclude sets regular expressions for topics to exclude
+// from consuming when using ConsumeTopicsRegex. A topic is consumed if it
+// matches any topic expression and does not match any exclude expression.
+//
+// This option does nothing if ConsumeTopicsRegex is not used. If any
+// expression is invalid, the error is logged and nothing is consumed.
+func ConsumeTopicsRegexExclude(excludes ...string) DirectConsumeOpt {
+	return directConsumeOpt{func(cfg *directConsumer) {
+		cfg.reExclude, cfg.reErr = compileTopicRegexes(excludes)
+	}}
+}
+
+// ConsumeUntilEnd bounds consuming to the end offsets of partitions at the
+// time the partitions are assigned; records produced after assignment are not
+// consumed. With a read committed isolation level, the end offset is the last
+// stable offset rather than the high watermark.
+//
+// Each partition stops being fetched once it is consumed up to its end offset.
+// Once every partition has been consumed to its end, PollFetches returns a
+// fetch containing ErrConsumeComplete (see that error for more details).
+//
+// Partitions added to a topic after assignment are also bounded to their end
+// offsets at the time they are discovered.
+func ConsumeUntilEnd() DirectConsumeOpt {
+	return directConsumeOpt{func(cfg *directConsumer) { cfg.untilEnd = true }}
+}
+
+// ConsumeUntilOffsets bounds consuming partitions to the given end offsets.
+// Records at or past a partition's end offset are not consumed; each
+// partition stops being fetched once it is consumed up to its end offset.
+//
+// Once every partition in the map has been consumed to its end, PollFetches
+// returns a fetch containing ErrConsumeComplete. Every partition in the map
+// must be consumed (through ConsumeTopics or ConsumePartitions) for
+// consuming to complete. Partitions consumed that are not in the map are not
+// bounded, unless ConsumeUntilEnd is also used, in which case they are bounded
+// to their end offsets at assignment.
+func ConsumeUntilOffsets(offsets map[string]map[int32]int64) DirectConsumeOpt {
+	return directConsumeOpt{func(cfg *directConsumer) { cfg.untilOffsets = offsets }}
+}
+
+// ConsumeBetweenTimes consumes only records whose timestamps are in
+// [start, end). This overrides the offsets given to ConsumeTopics and
+// ConsumePartitions: each partition begins at the first record at or after
+// start and is bounded to the first record at or after end (or to the end of
+// the partition, if no record is at or after end).
+//
+// Record timestamps are not necessarily increasing with offsets (producers
+// set CreateTime timestamps), so records within the offset range whose
+// timestamps are outside [start, end) are dropped.
+//
+// If start is not before end, an error is logged and nothing is consumed.
+//
+// Like ConsumeUntilEnd, each partition stops being fetched once it reaches
+// its end, and PollFetches returns a fetch containing ErrConsumeComplete once
+// every partition is consumed. Partitions with no records in range finish
+// without returning any records.
+//
+// This requires Kafka 0.10.1.0+.
+func ConsumeBetweenTimes(start, end time.Time) DirectConsumeOpt {
+	return directConsumeOpt{func(cfg *directConsumer) {
+		cfg.untilEnd = true
+		cfg.betweenTimes = true
+		cfg.startMilli = start.UnixNano() / 1e6
+		cfg.endMilli = end.UnixNano() / 1e6
+		if !start.Before(end) {
+			cfg.timesErr = fmt.Errorf("invalid consume time range: start %v is not before end %v", start, end)
+		}
+	}}
+}
+
 type directConsumer struct {
 	topics     map[string]Offset
 	partitions map[string]map[int32]Offset
 
+	untilEnd     bool
+	untilOffsets map[string]map[int32]int64
+
+	betweenTimes bool
+	startMilli   int64
+	endMilli     int64
+	timesErr     error // set if start is not before end
+
 	regexTopics bool
+	reErr       error                     // set if any expression is invalid
+	reCompiled  map[string]*regexp.Regexp // compiled topics, if regexTopics
+	reExclude   []*regexp.Regexp
 	reTopics    map[string]Offset
 	reIgnore    map[string]struct{}
 
@@ -77,12 +168,31 @@ func (cl *Client) AssignPartitions(opts ...DirectConsumeOpt) {
 	for _, opt := range opts {
 		opt.apply(d)
 	}
+	if err := d.compileRegexes(); err != nil {
+		cl.cfg.logger.Log(LogLevelError, "unable to consume topics", "err", err)
+		c.typ = consumerTypeUnset
+		return
+	}
+	if d.timesErr != nil {
+		cl.cfg.logger.Log(LogLevelError, "unable to consume between times", "err", d.timesErr)
+		c.typ = consumerTypeUnset
+		return
+	}
 	if len(d.topics) == 0 && len(d.partitions) == 0 || c.dead {
 		c.typ = consumerTypeUnset
 		return
 	}
 	c.typ = consumerTypeDirect
 	c.direct = d
+	if d.untilEnd || d.untilOffsets != nil {
+		b := newConsumeBounds(c.seq, d.untilOffsets)
+		if d.betweenTimes {
+			b.betweenTimes = true
+			b.startMilli = d.startMilli
+			b.endMilli = d.endMilli
+		}
+		c.bounds.Store(b)
+	}
 
 	defer cl.triggerUpdateMetadata()
 
@@ -107,6 +217,26 @@ func (cl *Client) AssignPartitions(opts ...DirectConsumeOpt) {
 	cl.topics.Store(clientTopics)
 }
 
+// compileRegexes compiles our topics if we are consuming with regex, returning
+// any error from compiling the topics or from ConsumeTopicsRegexExclude.
+func (d *directConsumer) compileRegexes() error {
+	if !d.regexTopics {
+		return nil
+	}
+	if d.reErr != nil {
+		return d.reErr
+	}
+	d.reCompiled = make(map[string]*regexp.Regexp, len(d.topics))
+	for topic := range d.topics {
+		res, err := compileTopicRegexes([]string{topic})
+		if err != nil {
+			return err
+		}
+		d.reCompiled[topic] = res[0]
+	}
+	return nil
+}
+
 // findNewAssignments returns new partitions to consume at given offsets
 // based off the current topics.
 func (d *directConsumer) findNewAssignments(
@@ -130,14 +260,21 @@ func (d *directConsumer) findNewAssignments(
 				// skip
 			} else {
 				for reTopic, offset := range d.topics {
-					if match, _ := regexp.MatchString(reTopic, topic); match {
+					if d.reCompiled[reTopic].MatchString(topic) {
 						useTopic = true
 						useOffset = offset
-						d.reTopics[topic] = offset
 						break
 					}
 				}
-				if !useTopic {
+				for _, reExclude := range d.reExclude {
+					if reExclude.MatchString(topic) {
+						useTopic = false
+						break
+					}
+				}
+				if useTopic {
+					d.reTopics[topic] = useOffset
+				} else {
 					d.reIgnore[topic] = struct{}{}
 				}
 			}
@@ -220,3 +357,289 @@ func (d *directConsumer) deleteUsing(topic string, partition int32) {
 	}
 	delete(partitions, partition)
 }
+
+// consumeBounds tracks the end offsets of partitions when consuming with
+// ConsumeUntilEnd or ConsumeUntilOffsets, and the time range of records when
+// consuming with ConsumeBetweenTimes.
+type consumeBounds struct {
+	seq uint64 // the consumer seq these bounds were created for
+
+	betweenTimes bool // if true, records must be in [startMilli, endMilli)
+	startMilli   int64
+	endMilli     int64
+
+	mu          sync.Mutex
+	ends        map[string]map[int32]int64
+	finished    map[string]map[int32]struct{}
+	numEnds     int
+	numFinished int
+	numLoading  int // partitions whose end offsets are being listed
+}
+
+func newConsumeBounds(seq uint64, untilOffsets map[string]map[int32]int64) *consumeBounds {
+	b := &consumeBounds{
+		seq:      seq,
+		ends:     make(map[string]map[int32]int64),
+		finished: make(map[string]map[int32]struct{}),
+	}
+	for topic, partitions := range untilOffsets {
+		for partition, end := range partitions {
+			b.setEndLocked(topic, partition, end)
+		}
+	}
+	return b
+}
+
+// end returns the end offset for a partition, if the partition is bounded and
+// seq is the seq these bounds are for.
+func (b *consumeBounds) end(topic string, partition int32, seq uint64) (int64, bool) {
+	if b == nil || seq != b.seq {
+		return 0, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	end, ok := b.ends[topic][partition]
+	return end, ok
+}
+
+// inTimes returns whether r's timestamp is within our time range, if we are
+// consuming between times.
+func (b *consumeBounds) inTimes(r *Record) bool {
+	if !b.betweenTimes {
+		return true
+	}
+	millis := r.Timestamp.UnixNano() / 1e6
+	return millis >= b.startMilli && millis < b.endMilli
+}
+
+func (b *consumeBounds) hasEnd(topic string, partition int32) bool {
+	_, ok := b.end(topic, partition, b.seq)
+	return ok
+}
+
+func (b *consumeBounds) setEndLocked(topic string, partition int32, end int64) {
+	ends := b.ends[topic]
+	if ends == nil {
+		ends = make(map[int32]int64)
+		b.ends[topic] = ends
+	}
+	if _, exists := ends[partition]; !exists {
+		b.numEnds++
+	}
+	ends[partition] = end
+}
+
+// finish marks a partition as consumed to its end, returning whether this
+// completed consuming every bounded partition.
+func (b *consumeBounds) finish(topic string, partition int32) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	finished := b.finished[topic]
+	if finished == nil {
+		finished = make(map[int32]struct{})
+		b.finished[topic] = finished
+	}
+	if _, exists := finished[partition]; exists {
+		return false
+	}
+	finished[partition] = struct{}{}
+	b.numFinished++
+	return b.completeLocked()
+}
+
+func (b *consumeBounds) completeLocked() bool {
+	return b.numEnds > 0 && b.numLoading == 0 && b.numFinished == b.numEnds
+}
+
+// loadBounds returns the current consume bounds, or nil if consuming is not
+// bounded.
+func (c *consumer) loadBounds() *consumeBounds {
+	b, _ := c.bounds.Load().(*consumeBounds)
+	return b
+}
+
+// finishBounded, called outside of any cursor lock, marks a bounded partition
+// as consumed to its end and, if every bounded partition is finished, wakes
+// PollFetches to return ErrConsumeComplete.
+func (c *consumer) finishBounded(b *consumeBounds, topic string, partition int32) {
+	if !b.finish(topic, partition) {
+		return
+	}
+	c.sourcesReadyMu.Lock()
+	if b.seq == c.seq {
+		c.complete = true
+	}
+	c.sourcesReadyMu.Unlock()
+	c.sourcesReadyCond.Broadcast()
+	c.cl.cfg.logger.Log(LogLevelInfo, "bounded consuming complete")
+}
+
+// withEnds, called under the consumer mu on metadata update, returns the new
+// assignments whose end offsets are known. For ConsumeUntilEnd, the end
+// offsets of all other partitions are listed, after which those partitions
+// are assigned. For ConsumeBetweenTimes, this also sets every partition to
+// begin at the start time.
+func (c *consumer) withEnds(assignments map[string]map[int32]Offset) map[string]map[int32]Offset {
+	b := c.loadBounds()
+	if b == nil || !c.direct.untilEnd || len(assignments) == 0 {
+		return assignments
+	}
+
+	timestamp := int64(-1) // latest
+	if c.direct.betweenTimes {
+		timestamp = c.direct.endMilli
+	}
+
+	var pending map[string]map[int32]Offset
+	var numPending int
+	for topic, partitions := range assignments {
+		for partition, offset := range partitions {
+			if c.direct.betweenTimes {
+				offset = NewOffset().AfterMilli(c.direct.startMilli)
+				partitions[partition] = offset
+			}
+			if b.hasEnd(topic, partition) {
+				continue
+			}
+			if pending == nil {
+				pending = make(map[string]map[int32]Offset)
+			}
+			if pending[topic] == nil {
+				pending[topic] = make(map[int32]Offset)
+			}
+			pending[topic][partition] = offset
+			delete(partitions, partition)
+			numPending++
+		}
+		if len(partitions) == 0 {
+			delete(assignments, topic)
+		}
+	}
+	if numPending > 0 {
+		b.mu.Lock()
+		b.numLoading += numPending
+		b.mu.Unlock()
+		go c.listEnds(b, pending, numPending, timestamp)
+	}
+	return assignments
+}
+
+// listEnds lists the end offsets for pending partitions at the given
+// timestamp and then assigns the partitions that we listed successfully.
+// Partitions that fail are removed from the direct consumer so that they are
+// retried on the next metadata update.
+func (c *consumer) listEnds(b *consumeBounds, pending map[string]map[int32]Offset, numPending int, timestamp int64) {
+	ends, err := c.listOffsetsAt(pending, timestamp)
+
+	// If listing by timestamp, partitions with no record at or after the
+	// timestamp are bounded to their current end.
+	if timestamp >= 0 && err == nil {
+		var latest map[string]map[int32]Offset
+		for topic, partitions := range ends {
+			for partition, end := range partitions {
+				if end != -1 {
+					continue
+				}
+				delete(partitions, partition)
+				if latest == nil {
+					latest = make(map[string]map[int32]Offset)
+				}
+				if latest[topic] == nil {
+					latest[topic] = make(map[int32]Offset)
+				}
+				latest[topic][partition] = pending[topic][partition]
+			}
+		}
+		if len(latest) > 0 {
+			var latestEnds map[string]map[int32]int64
+			latestEnds, err = c.listOffsetsAt(latest, -1)
+			for topic, partitions := range latestEnds {
+				for partition, end := range partitions {
+					ends[topic][partition] = end
+				}
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ready := make(map[string]map[int32]Offset)
+	b.mu.Lock()
+	b.numLoading -= numPending
+	for topic, partitions := range ends {
+		for partition, end := range partitions {
+			b.setEndLocked(topic, partition, end)
+			if ready[topic] == nil {
+				ready[topic] = make(map[int32]Offset)
+			}
+			ready[topic][partition] = pending[topic][partition]
+			delete(pending[topic], partition)
+		}
+	}
+	b.mu.Unlock()
+
+	if b != c.loadBounds() {
+		return // we were reassigned while listing
+	}
+
+	var numFailed int
+	for topic, partitions := range pending {
+		for partition := range partitions {
+			c.direct.deleteUsing(topic, partition)
+			numFailed++
+		}
+	}
+	if numFailed > 0 {
+		c.cl.cfg.logger.Log(LogLevelWarn, "unable to list end offsets for bounded consuming, retrying on next metadata update",
+			"num_failed", numFailed,
+			"err", err,
+		)
+		c.cl.triggerUpdateMetadata()
+	}
+	if len(ready) > 0 {
+		c.assignPartitions(ready, assignWithoutInvalidating)
+	}
+}
+
+// listOffsetsAt lists offsets for partitions at the given timestamp,
+// returning the offsets for all partitions that did not error.
+func (c *consumer) listOffsetsAt(partitions map[string]map[int32]Offset, timestamp int64) (map[string]map[int32]int64, error) {
+	req := kmsg.NewPtrListOffsetsRequest()
+	req.IsolationLevel = c.cl.cfg.isolationLevel
+	for topic, topicPartitions := range partitions {
+		parts := make([]kmsg.ListOffsetsRequestTopicPartition, 0, len(topicPartitions))
+		for partition := range topicPartitions {
+			part := kmsg.NewListOffsetsRequestTopicPartition()
+			part.Partition = partition
+			part.Timestamp = timestamp
+			parts = append(parts, part)
+		}
+		reqTopic := kmsg.NewListOffsetsRequestTopic()
+		reqTopic.Topic = topic
+		reqTopic.Partitions = parts
+		req.Topics = append(req.Topics, reqTopic)
+	}
+	kresp, err := c.cl.internalRequest(c.cl.ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[string]map[int32]int64)
+	for _, rTopic := range kresp.(*kmsg.ListOffsetsResponse).Topics {
+		for _, rPartition := range rTopic.Partitions {
+			if _, ok := partitions[rTopic.Topic][rPartition.Partition]; !ok {
+				continue
+			}
+			if err := kerr.ErrorForCode(rPartition.ErrorCode); err != nil {
+				continue
+			}
+			if offsets[rTopic.Topic] == nil {
+				offsets[rTopic.Topic] = make(map[int32]int64)
+			}
+			offsets[rTopic.Topic][rPartition.Partition] = rPartition.Offset
+		}
+	}
+	return offsets, nil
+}
@@ -38,11 +38,25 @@ func GroupTopics(topics ...string) GroupOpt {
 }
 
 // GroupTopicsRegex sets all topics in GroupTopics to be parsed as regular
-// expressions.
+// expressions. If any expression is invalid, the error is logged and the group
+// is not joined.
 func GroupTopicsRegex() GroupOpt {
 	return groupOpt{func(cfg *groupConsumer) { cfg.regexTopics = true }}
 }
 
+// GroupTopicsRegexExclude sets regular expressions for topics to exclude from
+// consuming when using GroupTopicsRegex. A topic is consumed if it matches any
+// topic expression and does not match any exclude expression; for example,
+// consuming `^events\..*` excluding `.*\.dlq$`.
+//
+// This option does nothing if GroupTopicsRegex is not used. If any expression
+// is invalid, the error is logged and the group is not joined.
+func GroupTopicsRegexExclude(excludes ...string) GroupOpt {
+	return groupOpt{func(cfg *groupConsumer) {
+		cfg.reExclude, cfg.reErr = compileTopicRegexes(excludes)
+	}}
+}
+
 // Balancers sets the group balancers to use for dividing topic partitions
 // among group members, overriding the defaults.
 //
@@ -212,6 +226,7 @@ type groupConsumer struct {
 	ctx        context.Context
 	cancel     func()
 	manageDone chan struct{}
+	managing   bool // set under mu once manage is started; never unset
 	dying      bool
 
 	id          string
@@ -233,6 +248,9 @@ type groupConsumer struct {
 	rejoinCh chan struct{} // cap 1; sent to if subscription changes (regex)
 
 	regexTopics bool
+	reErr       error                     // set if any expression is invalid
+	reCompiled  map[string]*regexp.Regexp // compiled topics, if regexTopics
+	reExclude   []*regexp.Regexp
 	reSeen      map[string]struct{}
 
 	instanceID   *string
@@ -302,6 +320,11 @@ func (cl *Client) AssignGroup(group string, opts ...GroupOpt) {
 	for _, opt := range opts {
 		opt.apply(g)
 	}
+	if err := g.compileRegexes(g.topics); err != nil {
+		cl.cfg.logger.Log(LogLevelError, "unable to join group", "group", group, "err", err)
+		c.typ = consumerTypeUnset
+		return
+	}
 	if len(group) == 0 || len(g.topics) == 0 || c.dead {
 		c.typ = consumerTypeUnset
 		return
@@ -400,11 +423,11 @@ loop:
 func (g *groupConsumer) leave() {
 	g.cancel()
 
-	// If g.using is nonzero before this check, then a manage goroutine has
+	// If managing is set before this check, then a manage goroutine has
 	// started. If not, it will never start because we set dying.
 	g.mu.Lock()
 	g.dying = true
-	wasManaging := len(g.using) > 0
+	wasManaging := g.managing
 	g.mu.Unlock()
 	if wasManaging {
 		g.c.mu.Unlock()
@@ -418,14 +441,14 @@ func (g *groupConsumer) leave() {
 			"group", g.id,
 			"memberID", g.memberID, // lock not needed now since nothing can change it (manageDone)
 		)
-		g.cl.Request(g.cl.ctx, &kmsg.LeaveGroupRequest{
-			Group:    g.id,
-			MemberID: g.memberID,
-			Members: []kmsg.LeaveGroupRequestMember{{
-				MemberID: g.memberID,
-				// no instance ID
-			}},
-		})
+		member := kmsg.NewLeaveGroupRequestMember()
+		member.MemberID = g.memberID
+		// no instance ID
+		req := kmsg.NewPtrLeaveGroupRequest()
+		req.Group = g.id
+		req.MemberID = g.memberID
+		req.Members = append(req.Members, member)
+		g.cl.internalRequest(g.cl.ctx, req)
 	}
 }
 
@@ -497,8 +520,8 @@ const (
 //     (1) if revoking lost partitions from a prior session (i.e., after sync),
 //         this revokes the passed in lost
 //     (2) if revoking at the end of a session, this revokes topics that the
-//         consumer is no longer interested in consuming (TODO, actually, only
-//         once we allow subscriptions to change without leaving the group).
+//         consumer is no longer interested in consuming (i.e., topics that
+//         were removed with RemoveConsumeTopics).
 //
 // Lastly, for cooperative consumers, this must selectively delete what was
 // lost from the uncommitted map.
@@ -528,9 +551,20 @@ func (g *groupConsumer) revoke(stage revokeStage, lost map[string][]int32) {
 		// lost is nil for cooperative assigning. Instead, we determine
 		// lost by finding subscriptions we are no longer interested in.
 		//
-		// TODO only relevant when we allow AssignGroup with the same
-		// group to change subscriptions (also we must delete the
-		// unused partitions from nowAssigned).
+		// We delete what we lose from nowAssigned so that we do not
+		// claim to own it when we rejoin. This is safe; the heartbeat
+		// loop waits for this revoke before the session ends.
+		g.mu.Lock()
+		for topic, partitions := range g.nowAssigned {
+			if _, using := g.using[topic]; !using {
+				if lost == nil {
+					lost = make(map[string][]int32)
+				}
+				lost[topic] = partitions
+				delete(g.nowAssigned, topic)
+			}
+		}
+		g.mu.Unlock()
 	}
 
 	if len(lost) > 0 {
@@ -574,7 +608,11 @@ func (g *groupConsumer) revoke(stage revokeStage, lost map[string][]int32) {
 		return
 	}
 
-	defer g.rejoin()
+	// If we are revoking at the end of this session, we are already
+	// rejoining.
+	if stage == revokeLastSession {
+		defer g.rejoin()
+	}
 
 	// If committing, users should be waiting for the commit to finish in
 	// onRevoke, which would complete updating the uncommitted map. But,
@@ -645,7 +683,7 @@ func (s *assignRevokeSession) revoke(g *groupConsumer) <-chan struct{} {
 	go func() {
 		defer close(s.revokeDone)
 		<-s.assignDone
-		if g.onRevoked != nil {
+		if g.onRevoked != nil || g.cooperative { // cooperative may need to stop fetching removed topics
 			g.revoke(revokeThisSession, nil)
 		}
 	}()
@@ -739,14 +777,13 @@ func (g *groupConsumer) heartbeat(fetchErrCh <-chan error, s *assignRevokeSessio
 
 		if heartbeat {
 			g.cl.cfg.logger.Log(LogLevelDebug, "heartbeating")
-			req := &kmsg.HeartbeatRequest{
-				Group:      g.id,
-				Generation: g.generation,
-				MemberID:   g.memberID,
-				InstanceID: g.instanceID,
-			}
+			req := kmsg.NewPtrHeartbeatRequest()
+			req.Group = g.id
+			req.Generation = g.generation
+			req.MemberID = g.memberID
+			req.InstanceID = g.instanceID
 			var kresp kmsg.Response
-			kresp, err = g.cl.Request(g.ctx, req)
+			kresp, err = g.cl.internalRequest(g.ctx, req)
 			if err == nil {
 				resp := kresp.(*kmsg.HeartbeatResponse)
 				err = kerr.ErrorForCode(resp.ErrorCode)
@@ -851,16 +888,15 @@ func (g *groupConsumer) joinAndSync() error {
 	g.prejoin()
 
 start:
-	req := kmsg.JoinGroupRequest{
-		Group:                  g.id,
-		SessionTimeoutMillis:   int32(g.sessionTimeout.Milliseconds()),
-		RebalanceTimeoutMillis: int32(g.rebalanceTimeout.Milliseconds()),
-		ProtocolType:           clientGroupProtocol,
-		MemberID:               g.memberID,
-		InstanceID:             g.instanceID,
-		Protocols:              g.joinGroupProtocols(),
-	}
-	kresp, err := g.cl.Request(g.ctx, &req)
+	req := kmsg.NewJoinGroupRequest()
+	req.Group = g.id
+	req.SessionTimeoutMillis = int32(g.sessionTimeout.Milliseconds())
+	req.RebalanceTimeoutMillis = int32(g.rebalanceTimeout.Milliseconds())
+	req.ProtocolType = clientGroupProtocol
+	req.MemberID = g.memberID
+	req.InstanceID = g.instanceID
+	req.Protocols = g.joinGroupProtocols()
+	kresp, err := g.cl.internalRequest(g.ctx, &req)
 	if err != nil {
 		g.cl.cfg.logger.Log(LogLevelWarn, "join group failed", "err", err)
 		return err
@@ -937,22 +973,21 @@ start:
 }
 
 func (g *groupConsumer) syncGroup(leader bool, plan balancePlan, protocol string) error {
-	req := kmsg.SyncGroupRequest{
-		Group:           g.id,
-		Generation:      g.generation,
-		MemberID:        g.memberID,
-		InstanceID:      g.instanceID,
-		ProtocolType:    &clientGroupProtocol,
-		Protocol:        &protocol,
-		GroupAssignment: plan.intoAssignment(), // nil unless we are the leader
-	}
+	req := kmsg.NewSyncGroupRequest()
+	req.Group = g.id
+	req.Generation = g.generation
+	req.MemberID = g.memberID
+	req.InstanceID = g.instanceID
+	req.ProtocolType = &clientGroupProtocol
+	req.Protocol = &protocol
+	req.GroupAssignment = plan.intoAssignment() // nil unless we are the leader
 
 	g.cl.cfg.logger.Log(LogLevelInfo, "syncing",
 		"protocol_type", clientGroupProtocol,
 		"protocol", protocol,
 	)
 
-	kresp, err := g.cl.Request(g.ctx, &req)
+	kresp, err := g.cl.internalRequest(g.ctx, &req)
 	if err != nil {
 		g.cl.cfg.logger.Log(LogLevelWarn, "sync failed", "err", err)
 		return err // Request retries as necesary, so this must be a failure
@@ -963,7 +998,7 @@ func (g *groupConsumer) syncGroup(leader bool, plan balancePlan, protocol string
 		return err
 	}
 
-	kassignment := new(kmsg.GroupMemberAssignment)
+	kassignment := kmsg.NewPtrGroupMemberAssignment()
 	if err = kassignment.ReadFrom(resp.MemberAssignment); err != nil {
 		g.cl.cfg.logger.Log(LogLevelError, "sync assignment parse failed", "err", err)
 		if g.cl.cfg.logger.Level() >= LogLevelDebug {
@@ -994,14 +1029,14 @@ func (g *groupConsumer) joinGroupProtocols() []kmsg.JoinGroupRequestProtocol {
 	g.mu.Unlock()
 	var protos []kmsg.JoinGroupRequestProtocol
 	for _, balancer := range g.balancers {
-		protos = append(protos, kmsg.JoinGroupRequestProtocol{
-			Name: balancer.protocolName(),
-			Metadata: balancer.metaFor(
-				topics,
-				g.nowAssigned,
-				g.generation,
-			),
-		})
+		proto := kmsg.NewJoinGroupRequestProtocol()
+		proto.Name = balancer.protocolName()
+		proto.Metadata = balancer.metaFor(
+			topics,
+			g.nowAssigned,
+			g.generation,
+		)
+		protos = append(protos, proto)
 	}
 	return protos
 }
@@ -1010,29 +1045,44 @@ func (g *groupConsumer) joinGroupProtocols() []kmsg.JoinGroupRequestProtocol {
 // were for the partitions we were assigned.
 func (g *groupConsumer) fetchOffsets(ctx context.Context, newAssigned map[string][]int32) error {
 start:
-	req := kmsg.OffsetFetchRequest{
-		Group:         g.id,
-		RequireStable: g.requireStable,
-	}
+	req := kmsg.NewOffsetFetchRequest()
+	req.Group = g.id
+	req.RequireStable = g.requireStable
+	reqGroup := kmsg.NewOffsetFetchRequestGroup() // v8+
+	reqGroup.Group = g.id
 	for topic, partitions := range newAssigned {
-		req.Topics = append(req.Topics, kmsg.OffsetFetchRequestTopic{
-			Topic:      topic,
-			Partitions: partitions,
-		})
-	}
-	kresp, err := g.cl.Request(ctx, &req)
+		reqTopic := kmsg.NewOffsetFetchRequestTopic()
+		reqTopic.Topic = topic
+		reqTopic.Partitions = partitions
+		req.Topics = append(req.Topics, reqTopic)
+
+		groupTopic := kmsg.NewOffsetFetchRequestGroupTopic()
+		groupTopic.Topic = topic
+		groupTopic.Partitions = partitions
+		reqGroup.Topics = append(reqGroup.Topics, groupTopic)
+	}
+	req.Groups = append(req.Groups, reqGroup)
+	kresp, err := g.cl.internalRequest(ctx, &req)
 	if err != nil {
 		g.cl.cfg.logger.Log(LogLevelWarn, "fetch offsets failed", "err", err)
 		return err
 	}
 	resp := kresp.(*kmsg.OffsetFetchResponse)
-	if err != nil {
+
+	rTopics, errCode := resp.Topics, resp.ErrorCode
+	if resp.Version >= 8 { // KIP-709: responses are per group
+		if len(resp.Groups) != 1 {
+			return ErrInvalidResp
+		}
+		rTopics, errCode = offsetFetchGroupTopics(resp.Groups[0]), resp.Groups[0].ErrorCode
+	}
+	if err = kerr.ErrorForCode(errCode); err != nil {
 		g.cl.cfg.logger.Log(LogLevelError, "fetch offsets failed with non-retriable error", "err", err)
 		return err
 	}
 
 	offsets := make(map[string]map[int32]Offset)
-	for _, rTopic := range resp.Topics {
+	for _, rTopic := range rTopics {
 		topicOffsets := make(map[int32]Offset)
 		offsets[rTopic.Topic] = topicOffsets
 		for _, rPartition := range rTopic.Partitions {
@@ -1121,6 +1171,28 @@ start:
 	return nil
 }
 
+// offsetFetchGroupTopics converts a v8+ per group offset fetch response into
+// the v0-v7 topic layout that fetchOffsets processes.
+func offsetFetchGroupTopics(group kmsg.OffsetFetchResponseGroup) []kmsg.OffsetFetchResponseTopic {
+	topics := make([]kmsg.OffsetFetchResponseTopic, 0, len(group.Topics))
+	for _, gt := range group.Topics {
+		topic := kmsg.NewOffsetFetchResponseTopic()
+		topic.Topic = gt.Topic
+		topic.Partitions = make([]kmsg.OffsetFetchResponseTopicPartition, 0, len(gt.Partitions))
+		for _, gp := range gt.Partitions {
+			partition := kmsg.NewOffsetFetchResponseTopicPartition()
+			partition.Partition = gp.Partition
+			partition.Offset = gp.Offset
+			partition.LeaderEpoch = gp.LeaderEpoch
+			partition.Metadata = gp.Metadata
+			partition.ErrorCode = gp.ErrorCode
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
 // findNewAssignments is called under the consumer lock at the end of a
 // metadata update, updating the topics the group wants to use and other
 // metadata.
@@ -1165,12 +1237,7 @@ func (g *groupConsumer) findNewAssignments(topics map[string]*topicPartitions) {
 		if g.regexTopics {
 			if _, exists := g.reSeen[topic]; !exists {
 				g.reSeen[topic] = struct{}{} // set we have seen so we do not reevaluate next time
-				for reTopic := range g.topics {
-					if match, _ := regexp.MatchString(reTopic, topic); match {
-						useTopic = true
-						break
-					}
-				}
+				useTopic = g.reMatches(topic)
 			}
 		} else {
 			_, useTopic = g.topics[topic]
@@ -1194,12 +1261,12 @@ func (g *groupConsumer) findNewAssignments(topics map[string]*topicPartitions) {
 		return
 	}
 
-	wasManaging := len(g.using) != 0
 	for topic, change := range toChange {
 		g.using[topic] += change.delta
 	}
 
-	if !wasManaging {
+	if !g.managing {
+		g.managing = true
 		go g.manage()
 	}
 
@@ -1208,6 +1275,156 @@ func (g *groupConsumer) findNewAssignments(topics map[string]*topicPartitions) {
 	}
 }
 
+// compileRegexes compiles topics into reCompiled if we are consuming with
+// regex, returning any error from compiling the topics or from
+// GroupTopicsRegexExclude. On error, reCompiled is unchanged.
+func (g *groupConsumer) compileRegexes(topics map[string]struct{}) error {
+	if !g.regexTopics {
+		return nil
+	}
+	if g.reErr != nil {
+		return g.reErr
+	}
+	compiled := make(map[string]*regexp.Regexp, len(topics))
+	for topic := range topics {
+		res, err := compileTopicRegexes([]string{topic})
+		if err != nil {
+			return err
+		}
+		compiled[topic] = res[0]
+	}
+	if g.reCompiled == nil {
+		g.reCompiled = compiled
+		return nil
+	}
+	for topic, re := range compiled {
+		g.reCompiled[topic] = re
+	}
+	return nil
+}
+
+// reMatches, called under the group mu, returns whether a topic matches any
+// of our topic expressions and none of our exclude expressions.
+func (g *groupConsumer) reMatches(topic string) bool {
+	var match bool
+	for reTopic := range g.topics {
+		if match = g.reCompiled[reTopic].MatchString(topic); match {
+			break
+		}
+	}
+	if !match {
+		return false
+	}
+	for _, reExclude := range g.reExclude {
+		if reExclude.MatchString(topic) {
+			return false
+		}
+	}
+	return true
+}
+
+// AddConsumeTopics adds topics (or regular expressions, if using
+// GroupTopicsRegex) to the topics that the group member is consuming. The new
+// topics are picked up on the next metadata update, after which the member
+// rejoins the group with its new subscription. With the cooperative balancer,
+// partitions already assigned continue to be consumed through the rejoin.
+//
+// This returns ErrNotGroup if the client is not consuming as a group, or an
+// error if using GroupTopicsRegex and any expression is invalid, in which case
+// no topics are added.
+func (cl *Client) AddConsumeTopics(topics ...string) error {
+	c := &cl.consumer
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := c.group
+	if c.typ != consumerTypeGroup || g == nil {
+		return ErrNotGroup
+	}
+
+	added := make(map[string]struct{}, len(topics))
+	for _, topic := range topics {
+		added[topic] = struct{}{}
+	}
+
+	g.mu.Lock()
+	if err := g.compileRegexes(added); err != nil {
+		g.mu.Unlock()
+		return err
+	}
+	for topic := range added {
+		g.topics[topic] = struct{}{}
+	}
+	if g.regexTopics {
+		// All topics we have not yet used must be reevaluated.
+		g.reSeen = make(map[string]struct{})
+		for topic := range g.using {
+			g.reSeen[topic] = struct{}{}
+		}
+	}
+	g.mu.Unlock()
+
+	if !g.regexTopics {
+		cl.topicsMu.Lock()
+		clientTopics := cl.cloneTopics()
+		for _, topic := range topics {
+			if _, exists := clientTopics[topic]; !exists {
+				clientTopics[topic] = newTopicPartitions(topic)
+			}
+		}
+		cl.topics.Store(clientTopics)
+		cl.topicsMu.Unlock()
+	}
+
+	cl.triggerUpdateMetadata()
+	return nil
+}
+
+// RemoveConsumeTopics removes topics (or regular expressions, if using
+// GroupTopicsRegex) from the topics that the group member is consuming. The
+// member immediately rejoins the group with its new subscription, and
+// partitions for topics that are no longer consumed are revoked through the
+// usual OnRevoked path. Removing every topic keeps the member in the group
+// with an empty subscription; topics can be added back with AddConsumeTopics.
+//
+// This returns ErrNotGroup if the client is not consuming as a group.
+func (cl *Client) RemoveConsumeTopics(topics ...string) error {
+	c := &cl.consumer
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g := c.group
+	if c.typ != consumerTypeGroup || g == nil {
+		return ErrNotGroup
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, topic := range topics {
+		delete(g.topics, topic)
+		delete(g.reCompiled, topic)
+	}
+
+	var removed bool
+	for topic := range g.using {
+		var keep bool
+		if g.regexTopics {
+			keep = g.reMatches(topic)
+		} else {
+			_, keep = g.topics[topic]
+		}
+		if !keep {
+			delete(g.using, topic)
+			removed = true
+		}
+	}
+	if removed {
+		g.rejoin()
+	}
+	return nil
+}
+
 // uncommit tracks the latest offset polled (+1) and the latest commit.
 // The reason head is just past the latest offset is because we want
 // to commit TO an offset, not BEFORE an offset.
@@ -1365,6 +1582,12 @@ func (g *groupConsumer) loopCommit() {
 // SetOffsets sets any matching offsets in setOffsets to the given
 // epoch/offset. Partitions that are not specified are not set.
 //
+// Setting an offset for a partition that was stopped, such as with the
+// FailOffsetOutOfRange policy, resumes consuming the partition at that offset.
+// This is the only way to resume a stopped partition without leaving the
+// group, since cooperative balancers never reassign partitions a member
+// keeps.
+//
 // If using transactions, it is advised to just use a GroupTransactSession and
 // avoid this function entirely.
 func (cl *Client) SetOffsets(setOffsets map[string]map[int32]EpochOffset) {
@@ -1416,8 +1639,11 @@ func (cl *Client) SetOffsets(setOffsets map[string]map[int32]EpochOffset) {
 			// If we are setting the offset to the head, then we do
 			// not need to invalidate anything we have buffered.
 			// Ideal optimization for transactions.
+			//
+			// A stopped partition must be reassigned to resume,
+			// even if the offset is unchanged.
 			current, exists := topicUncommitted[partition]
-			if exists && current.head == epochOffset {
+			if exists && current.head == epochOffset && !partitionStopped(clientTopics[topic], partition) {
 				current.committed = epochOffset
 				topicUncommitted[partition] = current
 				continue
@@ -1451,6 +1677,13 @@ func (cl *Client) SetOffsets(setOffsets map[string]map[int32]EpochOffset) {
 	c.resetAndLoadOffsets()
 }
 
+// partitionStopped returns whether the partition's cursor is not fetching
+// because it is waiting on offsets to be set.
+func partitionStopped(topic *topicPartitions, partition int32) bool {
+	tp := topic.load().all[partition]
+	return tp != nil && tp.cursor != nil && tp.cursor.isLoadingOffsets()
+}
+
 // UncommittedOffsets returns the latest uncommitted offsets. Uncommitted
 // offsets are always updated on calls to PollFetches.
 //
@@ -1567,12 +1800,12 @@ func (cl *Client) BlockingCommitOffsets(
 		defer cl.consumer.mu.Unlock()
 
 		if cl.consumer.typ != consumerTypeGroup {
-			onDone(new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), ErrNotGroup)
+			onDone(kmsg.NewPtrOffsetCommitRequest(), kmsg.NewPtrOffsetCommitResponse(), ErrNotGroup)
 			close(done)
 			return
 		}
 		if len(uncommitted) == 0 {
-			onDone(new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), nil)
+			onDone(kmsg.NewPtrOffsetCommitRequest(), kmsg.NewPtrOffsetCommitResponse(), nil)
 			close(done)
 			return
 		}
@@ -1649,11 +1882,11 @@ func (cl *Client) CommitOffsets(
 	cl.consumer.mu.Lock()
 	defer cl.consumer.mu.Unlock()
 	if cl.consumer.typ != consumerTypeGroup {
-		onDone(new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), ErrNotGroup)
+		onDone(kmsg.NewPtrOffsetCommitRequest(), kmsg.NewPtrOffsetCommitResponse(), ErrNotGroup)
 		return
 	}
 	if len(uncommitted) == 0 {
-		onDone(new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), nil)
+		onDone(kmsg.NewPtrOffsetCommitRequest(), kmsg.NewPtrOffsetCommitResponse(), nil)
 		return
 	}
 
@@ -1722,7 +1955,7 @@ func (g *groupConsumer) commit(
 		onDone = func(_ *kmsg.OffsetCommitRequest, _ *kmsg.OffsetCommitResponse, _ error) {}
 	}
 	if len(uncommitted) == 0 { // only empty if called thru autocommit / default revoke
-		onDone(new(kmsg.OffsetCommitRequest), new(kmsg.OffsetCommitResponse), nil)
+		onDone(kmsg.NewPtrOffsetCommitRequest(), kmsg.NewPtrOffsetCommitResponse(), nil)
 		return
 	}
 
@@ -1736,12 +1969,11 @@ func (g *groupConsumer) commit(
 	g.commitDone = commitDone
 
 	memberID := g.memberID
-	req := &kmsg.OffsetCommitRequest{
-		Group:      g.id,
-		Generation: g.generation,
-		MemberID:   memberID,
-		InstanceID: g.instanceID,
-	}
+	req := kmsg.NewPtrOffsetCommitRequest()
+	req.Group = g.id
+	req.Generation = g.generation
+	req.MemberID = memberID
+	req.InstanceID = g.instanceID
 
 	if ctx.Done() != nil {
 		go func() {
@@ -1768,24 +2000,23 @@ func (g *groupConsumer) commit(
 		g.cl.cfg.logger.Log(LogLevelDebug, "issuing commit", "uncommitted", uncommitted)
 
 		for topic, partitions := range uncommitted {
-			req.Topics = append(req.Topics, kmsg.OffsetCommitRequestTopic{
-				Topic: topic,
-			})
-			reqTopic := &req.Topics[len(req.Topics)-1]
+			reqTopic := kmsg.NewOffsetCommitRequestTopic()
+			reqTopic.Topic = topic
 			for partition, eo := range partitions {
-				reqTopic.Partitions = append(reqTopic.Partitions, kmsg.OffsetCommitRequestTopicPartition{
-					Partition:   partition,
-					Offset:      eo.Offset,
-					LeaderEpoch: eo.Epoch, // KIP-320
-					Metadata:    &memberID,
-				})
+				reqPartition := kmsg.NewOffsetCommitRequestTopicPartition()
+				reqPartition.Partition = partition
+				reqPartition.Offset = eo.Offset
+				reqPartition.LeaderEpoch = eo.Epoch // KIP-320
+				reqPartition.Metadata = &memberID
+				reqTopic.Partitions = append(reqTopic.Partitions, reqPartition)
 			}
+			req.Topics = append(req.Topics, reqTopic)
 		}
 
 		var kresp kmsg.Response
 		var err error
 		if len(req.Topics) > 0 {
-			kresp, err = g.cl.Request(commitCtx, req)
+			kresp, err = g.cl.internalRequest(commitCtx, req)
 		}
 		if err != nil {
 			onDone(req, nil, err)
@@ -0,0 +1,489 @@
+package kgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/twmb/kafka-go/pkg/kerr"
+	"github.com/twmb/kafka-go/pkg/kmsg"
+)
+
+func TestBuildListReqTimestamps(t *testing.T) {
+	var load offsetLoadMap
+	load.setLoadOffset("foo", 0, NewOffset().AfterMilli(1000), -1, 0)
+	load.setLoadOffset("foo", 1, NewOffset().AfterMilli(1000).AtEnd(), -1, 0)
+	load.setLoadOffset("foo", 2, NewOffset().AtStart(), -1, 0)
+	load.setLoadOffset("foo", 3, NewOffset().At(5), -1, 0)
+
+	exp := map[int32]int64{
+		0: 1000, // timestamp
+		1: -1,   // AtEnd clears the timestamp
+		2: -2,
+		3: -1, // exact offsets are loaded with latest
+	}
+	req := load.buildListReq(0)
+	for _, part := range req.Topics[0].Partitions {
+		if part.Timestamp != exp[part.Partition] {
+			t.Errorf("partition %d: got timestamp %d, exp %d", part.Partition, part.Timestamp, exp[part.Partition])
+		}
+	}
+}
+
+func TestListOffsetsErrWrapped(t *testing.T) {
+	const addr = "127.0.0.1:9092"
+	r, err := NewWireReplayer([]WireEntry{
+		{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: (&kmsg.ApiVersionsResponse{
+			Version: 3,
+			ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+				{ApiKey: 2, MaxVersion: 1},
+				{ApiKey: 18, MaxVersion: 3},
+			},
+		}).AppendTo(nil)},
+		{Addr: addr, IsResponse: true, Key: 2, Version: 1, Body: (&kmsg.ListOffsetsResponse{
+			Version: 1,
+			Topics: []kmsg.ListOffsetsResponseTopic{{
+				Topic: "foo",
+				Partitions: []kmsg.ListOffsetsResponseTopicPartition{{
+					ErrorCode: kerr.TopicAuthorizationFailed.Code,
+				}},
+			}},
+		}).AppendTo(nil)},
+	})
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+
+	cl, err := NewClient(SeedBrokers(addr), Dialer(r.Dial))
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+	b := cl.newBroker(addr, 1)
+	defer b.stopForever()
+
+	var load offsetLoadMap
+	load.setLoadOffset("foo", 0, NewOffset().AtStart(), -1, 0)
+	cl.consumer.tryBrokerOffsetLoadList(b, load)
+
+	if len(cl.consumer.fakeReadyForDraining) != 1 {
+		t.Fatalf("got %d fake fetches, exp 1", len(cl.consumer.fakeReadyForDraining))
+	}
+	err = cl.consumer.fakeReadyForDraining[0].Topics[0].Partitions[0].Err
+	var respErr *ErrResponse
+	if !errors.As(err, &respErr) {
+		t.Fatalf("got %T, exp *ErrResponse", err)
+	}
+	if respErr.Key != 2 || respErr.Broker != 1 || respErr.Topic != "foo" || respErr.Partition != 0 {
+		t.Errorf("got unexpected error context %+v", respErr)
+	}
+	if !errors.Is(err, kerr.TopicAuthorizationFailed) {
+		t.Errorf("got err %v, exp TopicAuthorizationFailed", err)
+	}
+}
+
+func TestRegexTopicsExclude(t *testing.T) {
+	cl, err := NewClient(SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+
+	topics := make(map[string]*topicPartitions)
+	for _, topic := range []string{"events.a", "events.a.dlq", "events.b", "other"} {
+		parts := newTopicPartitions(topic)
+		parts.v.Store(&topicPartitionsData{partitions: []int32{0}})
+		topics[topic] = parts
+	}
+
+	t.Run("direct", func(t *testing.T) {
+		d := &directConsumer{
+			reTopics: make(map[string]Offset),
+			reIgnore: make(map[string]struct{}),
+			using:    make(map[string]map[int32]struct{}),
+		}
+		for _, opt := range []DirectConsumeOpt{
+			ConsumeTopics(NewOffset(), `^events\..*`),
+			ConsumeTopicsRegex(),
+			ConsumeTopicsRegexExclude(`.*\.dlq$`),
+		} {
+			opt.apply(d)
+		}
+		if err := d.compileRegexes(); err != nil {
+			t.Fatalf("unexpected compile err: %v", err)
+		}
+		assigned := d.findNewAssignments(topics)
+		if len(assigned) != 2 || assigned["events.a"] == nil || assigned["events.b"] == nil {
+			t.Errorf("got assigned topics %v, exp events.a and events.b", assigned)
+		}
+	})
+
+	t.Run("group", func(t *testing.T) {
+		g := &groupConsumer{
+			cl:       cl,
+			using:    map[string]int{"unrelated": 1},
+			managing: true, // do not start managing this partial group
+			rejoinCh: make(chan struct{}, 1),
+			reSeen:   make(map[string]struct{}),
+		}
+		for _, opt := range []GroupOpt{
+			GroupTopics(`^events\..*`),
+			GroupTopicsRegex(),
+			GroupTopicsRegexExclude(`.*\.dlq$`),
+		} {
+			opt.apply(g)
+		}
+		if err := g.compileRegexes(g.topics); err != nil {
+			t.Fatalf("unexpected compile err: %v", err)
+		}
+		setGroup := func(typ consumerType, g *groupConsumer) {
+			cl.consumer.mu.Lock()
+			defer cl.consumer.mu.Unlock()
+			cl.consumer.typ, cl.consumer.group = typ, g
+		}
+		setGroup(consumerTypeGroup, g)
+		defer setGroup(consumerTypeUnset, nil)
+
+		g.findNewAssignments(topics)
+		<-g.rejoinCh
+		if _, exists := g.using["events.a.dlq"]; exists || len(g.using) != 3 {
+			t.Errorf("got using %v, exp unrelated, events.a, events.b", g.using)
+		}
+
+		// Adding a topic reevaluates topics we previously skipped.
+		if err := cl.AddConsumeTopics("^other$"); err != nil {
+			t.Fatalf("unexpected add err: %v", err)
+		}
+		g.findNewAssignments(topics)
+		<-g.rejoinCh
+		if _, exists := g.using["other"]; !exists {
+			t.Errorf("got using %v, missing added topic other", g.using)
+		}
+
+		// Removing a topic stops using what no longer matches and
+		// rejoins.
+		if err := cl.RemoveConsumeTopics(`^events\..*`); err != nil {
+			t.Fatalf("unexpected remove err: %v", err)
+		}
+		select {
+		case <-g.rejoinCh:
+		default:
+			t.Error("remove did not trigger a rejoin")
+		}
+		if len(g.using) != 1 || g.using["other"] != 1 {
+			t.Errorf("got using %v, exp only other", g.using)
+		}
+
+		if err := cl.AddConsumeTopics("(invalid"); err == nil {
+			t.Error("expected err adding an invalid expression")
+		}
+		if _, exists := g.topics["(invalid"]; exists {
+			t.Error("invalid expression was added")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		d := &directConsumer{topics: map[string]Offset{"events": NewOffset()}}
+		for _, opt := range []DirectConsumeOpt{
+			ConsumeTopicsRegex(),
+			ConsumeTopicsRegexExclude(`(invalid`),
+		} {
+			opt.apply(d)
+		}
+		if err := d.compileRegexes(); err == nil {
+			t.Error("expected err for invalid exclude expression")
+		}
+
+		g := &groupConsumer{topics: map[string]struct{}{"(invalid": {}}}
+		GroupTopicsRegex().apply(g)
+		if err := g.compileRegexes(g.topics); err == nil {
+			t.Error("expected err for invalid topic expression")
+		}
+
+		cl.AssignPartitions(ConsumeTopics(NewOffset(), "events"), ConsumeTopicsRegex(), ConsumeTopicsRegexExclude(`(invalid`))
+		cl.consumer.mu.Lock()
+		typ := cl.consumer.typ
+		cl.consumer.mu.Unlock()
+		if typ != consumerTypeUnset {
+			t.Error("consuming with an invalid exclude expression")
+		}
+	})
+
+	if err := cl.AddConsumeTopics("foo"); err != ErrNotGroup {
+		t.Errorf("got add err %v when not a group, exp ErrNotGroup", err)
+	}
+}
+
+func TestRemoveAllConsumeTopics(t *testing.T) {
+	cl, err := NewClient(
+		SeedBrokers("127.0.0.1:1"),
+		RequestRetries(0),
+		RetryBackoff(func(int) time.Duration { return time.Millisecond }),
+	)
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+
+	topics := map[string]*topicPartitions{"foo": newTopicPartitions("foo")}
+	topics["foo"].v.Store(&topicPartitionsData{partitions: []int32{0}})
+
+	cl.AssignGroup("group", GroupTopics("foo"))
+	c := &cl.consumer
+	c.mu.Lock()
+	g := c.group
+	g.findNewAssignments(topics)
+	c.mu.Unlock()
+
+	if err := cl.RemoveConsumeTopics("foo"); err != nil {
+		t.Fatalf("unexpected remove err: %v", err)
+	}
+	g.mu.Lock()
+	if len(g.using) != 0 || !g.managing {
+		t.Errorf("got using %v, managing %v; exp no topics, still managing", g.using, g.managing)
+	}
+	g.mu.Unlock()
+
+	// Adding the topic back must not start a second manage goroutine,
+	// which would panic closing manageDone a second time.
+	if err := cl.AddConsumeTopics("foo"); err != nil {
+		t.Fatalf("unexpected add err: %v", err)
+	}
+	c.mu.Lock()
+	g.findNewAssignments(topics)
+	c.mu.Unlock()
+	g.mu.Lock()
+	if _, exists := g.using["foo"]; !exists {
+		t.Errorf("got using %v, exp foo", g.using)
+	}
+	g.mu.Unlock()
+
+	// Leaving the group waits for the manage goroutine.
+	cl.Close()
+	select {
+	case <-g.manageDone:
+	default:
+		t.Error("manage still running after leaving the group")
+	}
+}
+
+func TestCooperativeRevokeRemovedTopics(t *testing.T) {
+	cl, err := NewClient(SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+
+	var revoked map[string][]int32
+	g := &groupConsumer{
+		c:           &cl.consumer,
+		cl:          cl,
+		cooperative: true,
+		using:       map[string]int{"keep": 1},
+		nowAssigned: map[string][]int32{"keep": {0}, "removed": {0, 1}},
+		uncommitted: uncommitted{"keep": {0: {}}, "removed": {0: {}}},
+		rejoinCh:    make(chan struct{}, 1),
+		onRevoked:   func(_ context.Context, lost map[string][]int32) { revoked = lost },
+	}
+	g.revoke(revokeThisSession, nil)
+
+	if exp := map[string][]int32{"removed": {0, 1}}; !reflect.DeepEqual(revoked, exp) {
+		t.Errorf("got revoked %v, exp %v", revoked, exp)
+	}
+	if exp := map[string][]int32{"keep": {0}}; !reflect.DeepEqual(g.nowAssigned, exp) {
+		t.Errorf("got now assigned %v, exp %v", g.nowAssigned, exp)
+	}
+	if _, exists := g.uncommitted["removed"]; exists || len(g.uncommitted) != 1 {
+		t.Errorf("got uncommitted %v, exp only keep", g.uncommitted)
+	}
+	select {
+	case <-g.rejoinCh:
+		t.Error("revoking at the end of a session triggered another rejoin")
+	default:
+	}
+}
+
+// TestConsumeBetweenTimes replays a cluster with one broker and two
+// partitions. Partition 0 has records in range; partition 1 has no record at
+// or after either time, so its end falls back to the latest offset and its
+// start falls back to the end.
+func TestConsumeBetweenTimes(t *testing.T) {
+	const addr = "127.0.0.1:9092"
+
+	// Timestamps are not monotonic: v2 is within the listed offsets but
+	// before the start time.
+	batch, err := kmsg.NewBatchBuilder().RecordBatch([]kmsg.BatchRecord{
+		{Value: []byte("v0"), Timestamp: 500},
+		{Value: []byte("v1"), Timestamp: 1000},
+		{Value: []byte("v2"), Timestamp: 900},
+		{Value: []byte("v3"), Timestamp: 1500},
+		{Value: []byte("v4"), Timestamp: 2000},
+	})
+	if err != nil {
+		t.Fatalf("unexpected build err: %v", err)
+	}
+
+	listOffsets := func(offsets map[int32]int64) WireEntry {
+		resp := kmsg.NewPtrListOffsetsResponse()
+		resp.Version = 1
+		topic := kmsg.NewListOffsetsResponseTopic()
+		topic.Topic = "foo"
+		for partition, offset := range offsets {
+			part := kmsg.NewListOffsetsResponseTopicPartition()
+			part.Partition = partition
+			part.Offset = offset
+			topic.Partitions = append(topic.Partitions, part)
+		}
+		resp.Topics = append(resp.Topics, topic)
+		return WireEntry{Addr: addr, IsResponse: true, Key: 2, Version: 1, Body: resp.AppendTo(nil)}
+	}
+
+	entries := []WireEntry{
+		listOffsets(map[int32]int64{0: 4, 1: -1}), // at the end time
+		listOffsets(map[int32]int64{1: 2}),        // partition 1 latest
+		listOffsets(map[int32]int64{0: 1, 1: -1}), // at the start time
+		listOffsets(map[int32]int64{1: 2}),        // partition 1 at end
+		{Addr: addr, IsResponse: true, Key: 1, Version: 4, Body: (&kmsg.FetchResponse{
+			Version: 4,
+			Topics: []kmsg.FetchResponseTopic{{
+				Topic: "foo",
+				Partitions: []kmsg.FetchResponseTopicPartition{{
+					Partition:        0,
+					HighWatermark:    5,
+					LastStableOffset: 5,
+					RecordBatches:    batch.AppendTo(nil),
+				}},
+			}},
+		}).AppendTo(nil)},
+	}
+	apiVersions := (&kmsg.ApiVersionsResponse{
+		Version: 3,
+		ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+			{ApiKey: 1, MaxVersion: 4},
+			{ApiKey: 2, MaxVersion: 1},
+			{ApiKey: 3, MaxVersion: 1},
+			{ApiKey: 18, MaxVersion: 3},
+		},
+	}).AppendTo(nil)
+	meta := (&kmsg.MetadataResponse{
+		Version:      1,
+		Brokers:      []kmsg.MetadataResponseBroker{{NodeID: 1, Host: "127.0.0.1", Port: 9092}},
+		ControllerID: 1,
+		Topics: []kmsg.MetadataResponseTopic{{
+			Topic: "foo",
+			Partitions: []kmsg.MetadataResponseTopicPartition{
+				{Partition: 0, Leader: 1, Replicas: []int32{1}, ISR: []int32{1}},
+				{Partition: 1, Leader: 1, Replicas: []int32{1}, ISR: []int32{1}},
+			},
+		}},
+	}).AppendTo(nil)
+	for i := 0; i < 10; i++ {
+		entries = append(entries,
+			WireEntry{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: apiVersions},
+			WireEntry{Addr: addr, IsResponse: true, Key: 3, Version: 1, Body: meta},
+		)
+	}
+
+	r, err := NewWireReplayer(entries)
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+
+	var recording bytes.Buffer
+	cl, err := NewClient(
+		SeedBrokers(addr),
+		Dialer(r.Dial),
+		MetadataMinAge(10*time.Millisecond),
+		RecordWire(&recording),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer cl.Close()
+
+	// A range that is empty or backwards is rejected.
+	cl.AssignPartitions(
+		ConsumePartitions(map[string]map[int32]Offset{"foo": {0: NewOffset(), 1: NewOffset()}}),
+		ConsumeBetweenTimes(time.Unix(2, 0), time.Unix(2, 0)),
+	)
+	if typ := cl.consumer.typ; typ != consumerTypeUnset {
+		t.Errorf("got consumer type %v after an empty time range, exp unset", typ)
+	}
+
+	cl.AssignPartitions(
+		ConsumePartitions(map[string]map[int32]Offset{"foo": {0: NewOffset(), 1: NewOffset()}}),
+		ConsumeBetweenTimes(time.Unix(1, 0), time.Unix(2, 0)),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var values []string
+	for done := false; !done; {
+		fetches := cl.PollFetches(ctx)
+		if ctx.Err() != nil {
+			t.Fatalf("consuming did not complete, consumed %v", values)
+		}
+		for _, fetch := range fetches {
+			for _, topic := range fetch.Topics {
+				for _, partition := range topic.Partitions {
+					if partition.Err == ErrConsumeComplete {
+						done = true
+						continue
+					}
+					if partition.Err != nil {
+						t.Fatalf("unexpected partition err: %v", partition.Err)
+					}
+					for _, record := range partition.Records {
+						values = append(values, string(record.Value))
+					}
+				}
+			}
+		}
+	}
+
+	if exp := []string{"v1", "v3"}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("got values %v, exp %v", values, exp)
+	}
+	b := cl.consumer.loadBounds()
+	for partition, exp := range map[int32]int64{0: 4, 1: 2} {
+		if end, ok := b.end("foo", partition, b.seq); !ok || end != exp {
+			t.Errorf("partition %d: got end %d, %v, exp %d, true", partition, end, ok, exp)
+		}
+	}
+
+	// The ListOffsets requests, in order, list partition timestamps.
+	cl.Close()
+	recorded, err := ReadWireEntries(&recording)
+	if err != nil {
+		t.Fatalf("unable to read recording: %v", err)
+	}
+	var lists []map[int32]int64
+	for _, e := range recorded {
+		if e.IsResponse || e.Key != 2 {
+			continue
+		}
+		req := kmsg.NewPtrListOffsetsRequest()
+		req.SetVersion(e.Version)
+		if err := req.ReadFrom(e.Body); err != nil {
+			t.Fatalf("unable to read recorded list offsets request: %v", err)
+		}
+		timestamps := make(map[int32]int64)
+		for _, part := range req.Topics[0].Partitions {
+			timestamps[part.Partition] = part.Timestamp
+		}
+		lists = append(lists, timestamps)
+	}
+	expLists := []map[int32]int64{
+		{0: 2000, 1: 2000}, // ends at the end time
+		{1: -1},            // no record at or after the end time: latest
+		{0: 1000, 1: 1000}, // starts at the start time
+		{1: -1},            // no record at or after the start time: at end
+	}
+	if !reflect.DeepEqual(lists, expLists) {
+		t.Errorf("got list offsets timestamps %v, exp %v", lists, expLists)
+	}
+}
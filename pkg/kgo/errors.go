@@ -3,6 +3,9 @@ package kgo
 import (
 	"errors"
 	"fmt"
+
+	"github.com/twmb/kafka-go/pkg/kerr"
+	"github.com/twmb/kafka-go/pkg/kmsg"
 )
 
 var (
@@ -94,6 +97,13 @@ var (
 	// ErrCommitWithFatalID is returned when trying to commit in
 	// EndTransaction with a producer ID that has failed.
 	ErrCommitWithFatalID = errors.New("cannot commit with a fatal producer id; retry with an abort")
+
+	// ErrConsumeComplete is returned from PollFetches once all partitions
+	// bounded by ConsumeUntilEnd, ConsumeUntilOffsets, or
+	// ConsumeBetweenTimes have been consumed to their end offsets. The
+	// error is in a fake fetch with an empty topic and partition -1, and is
+	// returned on every poll until the client is assigned new partitions.
+	ErrConsumeComplete = errors.New("bounded consuming is complete")
 )
 
 // ErrDataLoss is returned for Kafka >=2.1.0 when data loss is detected and the
@@ -117,6 +127,63 @@ func (e *ErrDataLoss) Error() string {
 		e.Topic, e.Partition, e.ConsumedTo, e.ResetTo)
 }
 
+// ErrResponse is a Kafka error returned in a response for a specific topic
+// partition, along with the request and broker the error was returned from.
+//
+// Every Kafka partition error that the client returns from a broker response
+// is wrapped in this: errors in produce promises from Produce responses, and
+// errors in FetchPartition.Err from Fetch, ListOffsets, and
+// OffsetForLeaderEpoch responses. It unwraps to the underlying *kerr.Error,
+// so use errors.Is or errors.As to check for specific Kafka errors:
+//
+//	if errors.Is(err, kerr.NotLeaderForPartition) {
+//	        ...
+//	}
+//
+// kerr.IsRetriable also works through this wrapper.
+//
+// When upgrading, note that direct comparisons such as
+// err == kerr.NotLeaderForPartition against these errors no longer match and
+// must be changed to errors.Is. Topic and partition errors from metadata
+// loads, as well as errors from the client itself (such as ErrDataLoss), are
+// not wrapped.
+type ErrResponse struct {
+	// Key is the key of the request that the error was returned for.
+	Key int16
+	// Broker is the ID of the broker that returned the error.
+	Broker int32
+	// Topic is the topic the error was returned for.
+	Topic string
+	// Partition is the partition the error was returned for.
+	Partition int32
+	// Err is the underlying Kafka error.
+	Err error
+}
+
+func (e *ErrResponse) Error() string {
+	return fmt.Sprintf("%s response from broker %d for topic %s partition %d: %v",
+		kmsg.NameForKey(e.Key), e.Broker, e.Topic, e.Partition, e.Err)
+}
+
+// Unwrap returns the underlying Kafka error.
+func (e *ErrResponse) Unwrap() error { return e.Err }
+
+// wrapResponseErr wraps err in an ErrResponse if err is a Kafka error.
+// Internally, we always compare against raw Kafka errors; we wrap only when
+// returning errors to users.
+func wrapResponseErr(err error, key int16, broker int32, topic string, partition int32) error {
+	if _, ok := err.(*kerr.Error); !ok {
+		return err
+	}
+	return &ErrResponse{
+		Key:       key,
+		Broker:    broker,
+		Topic:     topic,
+		Partition: partition,
+		Err:       err,
+	}
+}
+
 func isRetriableBrokerErr(err error) bool {
 	switch err {
 	case ErrBrokerDead,
@@ -0,0 +1,32 @@
+package kgo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/twmb/kafka-go/pkg/kerr"
+)
+
+func TestWrapResponseErr(t *testing.T) {
+	err := wrapResponseErr(kerr.NotLeaderForPartition, 0, 3, "foo", 1)
+	var respErr *ErrResponse
+	if !errors.As(err, &respErr) {
+		t.Fatalf("got %T, exp *ErrResponse", err)
+	}
+	if respErr.Broker != 3 || respErr.Topic != "foo" || respErr.Partition != 1 {
+		t.Errorf("got unexpected error context %+v", respErr)
+	}
+	if !errors.Is(err, kerr.NotLeaderForPartition) {
+		t.Error("wrapped error is not the underlying Kafka error")
+	}
+	if !kerr.IsRetriable(err) {
+		t.Error("wrapped retriable error is not retriable")
+	}
+
+	if err := wrapResponseErr(nil, 0, 3, "foo", 1); err != nil {
+		t.Errorf("got %v wrapping nil, exp nil", err)
+	}
+	if err := wrapResponseErr(ErrConnDead, 0, 3, "foo", 1); err != ErrConnDead {
+		t.Errorf("got %v wrapping a non-Kafka error, exp it unchanged", err)
+	}
+}
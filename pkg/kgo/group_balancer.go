@@ -86,17 +86,17 @@ func (plan balancePlan) addPartitions(member groupMemberID, topic string, partit
 func (plan balancePlan) intoAssignment() []kmsg.SyncGroupRequestGroupAssignment {
 	kassignments := make([]kmsg.SyncGroupRequestGroupAssignment, 0, len(plan))
 	for member, assignment := range plan {
-		var kassignment kmsg.GroupMemberAssignment
+		kassignment := kmsg.NewGroupMemberAssignment()
 		for topic, partitions := range assignment {
-			kassignment.Topics = append(kassignment.Topics, kmsg.GroupMemberAssignmentTopic{
-				Topic:      topic,
-				Partitions: partitions,
-			})
+			assignTopic := kmsg.NewGroupMemberAssignmentTopic()
+			assignTopic.Topic = topic
+			assignTopic.Partitions = partitions
+			kassignment.Topics = append(kassignment.Topics, assignTopic)
 		}
-		kassignments = append(kassignments, kmsg.SyncGroupRequestGroupAssignment{
-			MemberID:         member.memberID,
-			MemberAssignment: kassignment.AppendTo(nil),
-		})
+		syncAssignment := kmsg.NewSyncGroupRequestGroupAssignment()
+		syncAssignment.MemberID = member.memberID
+		syncAssignment.MemberAssignment = kassignment.AppendTo(nil)
+		kassignments = append(kassignments, syncAssignment)
 	}
 	return kassignments
 }
@@ -153,10 +153,10 @@ func parseGroupMembers(kmembers []kmsg.JoinGroupResponseMember) ([]groupMember,
 }
 
 func basicMetaFor(interests []string) []byte {
-	return (&kmsg.GroupMemberMetadata{
-		Version: 0,
-		Topics:  interests,
-	}).AppendTo(nil)
+	meta := kmsg.NewGroupMemberMetadata()
+	meta.Version = 0
+	meta.Topics = interests
+	return meta.AppendTo(nil)
 }
 
 ///////////////////
@@ -387,28 +387,25 @@ func (s *stickyBalancer) protocolName() string {
 }
 func (s *stickyBalancer) isCooperative() bool { return s.cooperative }
 func (s *stickyBalancer) metaFor(interests []string, currentAssignment map[string][]int32, generation int32) []byte {
-	meta := kmsg.GroupMemberMetadata{
-		Version: 0,
-		Topics:  interests,
-	}
+	meta := kmsg.NewGroupMemberMetadata()
+	meta.Version = 0
+	meta.Topics = interests
 	if s.cooperative {
 		meta.Version = 1
 	}
-	stickyMeta := kmsg.StickyMemberMetadata{
-		Generation: generation,
-	}
+	stickyMeta := kmsg.NewStickyMemberMetadata()
+	stickyMeta.Generation = generation
 	for topic, partitions := range currentAssignment {
 		if s.cooperative {
-			meta.OwnedPartitions = append(meta.OwnedPartitions, kmsg.GroupMemberMetadataOwnedPartition{
-				Topic:      topic,
-				Partitions: partitions,
-			})
+			owned := kmsg.NewGroupMemberMetadataOwnedPartition()
+			owned.Topic = topic
+			owned.Partitions = partitions
+			meta.OwnedPartitions = append(meta.OwnedPartitions, owned)
 		}
-		stickyMeta.CurrentAssignment = append(stickyMeta.CurrentAssignment,
-			kmsg.StickyMemberMetadataCurrentAssignment{
-				Topic:      topic,
-				Partitions: partitions,
-			})
+		current := kmsg.NewStickyMemberMetadataCurrentAssignment()
+		current.Topic = topic
+		current.Partitions = partitions
+		stickyMeta.CurrentAssignment = append(stickyMeta.CurrentAssignment, current)
 	}
 	meta.UserData = stickyMeta.AppendTo(nil)
 	return meta.AppendTo(nil)
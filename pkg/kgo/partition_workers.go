@@ -0,0 +1,250 @@
+package kgo
+
+import (
+	"context"
+	"sync"
+)
+
+// PartitionWorkers fans out consumed records to one goroutine per partition,
+// providing ordered processing within a partition and parallel processing
+// across partitions.
+//
+// When consuming as a group, the workers must be hooked into the group with
+// GroupOpt. Workers are started for partitions as they are assigned, and
+// workers are stopped and waited on when partitions are revoked or lost,
+// before the prior revoke or lost function (such as the default blocking
+// commit) is called. Thus, by the time offsets are committed in a revoke,
+// every record that was polled for a revoked partition has been processed.
+//
+// When consuming directly, workers are started for partitions as records for
+// those partitions are first seen.
+//
+// A worker that is slow to receive records eventually blocks polling for all
+// partitions.
+type PartitionWorkers struct {
+	cl *Client
+	fn func(context.Context, string, int32, <-chan []*Record)
+
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	group   bool // set when GroupOpt is applied; workers are only started on assign
+	workers map[string]map[int32]*partitionWorker
+
+	// polling is true while Run is in PollFetches, and pollCancel cancels
+	// that poll. Stopping workers waits for an in progress poll (tracked by
+	// pollGen) to return and for its records to be dispatched.
+	polling    bool
+	pollGen    uint64
+	pollCancel func()
+}
+
+type partitionWorker struct {
+	cancel  func()
+	records chan []*Record
+	done    chan struct{}
+}
+
+// NewPartitionWorkers returns workers that, once Run, call fn in a new
+// goroutine for every partition being consumed.
+//
+// Records are sent to fn in order as they are polled. The records channel is
+// closed when the partition is revoked or when Run returns, and fn should
+// return once the channel is closed. The context passed to fn is canceled
+// when the partition is lost (or after fn returns), and can be used to abort
+// processing early; records that a worker does not process for a lost
+// partition will be consumed again by the partition's next owner.
+func NewPartitionWorkers(
+	cl *Client,
+	fn func(ctx context.Context, topic string, partition int32, records <-chan []*Record),
+) *PartitionWorkers {
+	w := &PartitionWorkers{
+		cl:      cl,
+		fn:      fn,
+		workers: make(map[string]map[int32]*partitionWorker),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	return w
+}
+
+// GroupOpt returns an option to hook the workers into a group's OnAssigned,
+// OnRevoked, and OnLost functions. Any of those functions that are set in
+// options before this option are called after the workers are started or
+// stopped; this option must come after them.
+func (w *PartitionWorkers) GroupOpt() GroupOpt {
+	return groupOpt{func(cfg *groupConsumer) {
+		w.mu.Lock()
+		w.group = true
+		w.mu.Unlock()
+
+		onAssigned, onRevoked, onLost := cfg.onAssigned, cfg.onRevoked, cfg.onLost
+		if onLost == nil {
+			onLost = onRevoked // as the group consumer does if OnLost is unset
+		}
+		cfg.onAssigned = func(ctx context.Context, assigned map[string][]int32) {
+			w.start(assigned)
+			if onAssigned != nil {
+				onAssigned(ctx, assigned)
+			}
+		}
+		cfg.onRevoked = func(ctx context.Context, revoked map[string][]int32) {
+			w.stop(revoked, false, false)
+			if onRevoked != nil {
+				onRevoked(ctx, revoked)
+			}
+		}
+		cfg.onLost = func(ctx context.Context, lost map[string][]int32) {
+			w.stop(lost, false, true)
+			if onLost != nil {
+				onLost(ctx, lost)
+			}
+		}
+	}}
+}
+
+// Run polls the client and sends records to partition workers until the
+// context is canceled, at which point all workers are stopped and waited on.
+//
+// Fetch errors are logged and otherwise dropped.
+func (w *PartitionWorkers) Run(ctx context.Context) {
+	defer w.stop(nil, true, false)
+
+	for {
+		pollCtx, pollCancel := context.WithCancel(ctx)
+		w.mu.Lock()
+		w.polling = true
+		w.pollGen++
+		w.pollCancel = pollCancel
+		w.mu.Unlock()
+
+		fetches := w.cl.PollFetches(pollCtx)
+		pollCancel()
+
+		w.mu.Lock()
+		w.polling = false
+		w.dispatchLocked(fetches)
+		w.cond.Broadcast()
+		w.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// dispatchLocked, called under the mu, sends records to their workers,
+// blocking until each worker receives its records or quits.
+func (w *PartitionWorkers) dispatchLocked(fetches Fetches) {
+	for _, fetch := range fetches {
+		for _, topic := range fetch.Topics {
+			for _, partition := range topic.Partitions {
+				if partition.Err != nil {
+					w.cl.cfg.logger.Log(LogLevelError, "partition workers dropping fetch error",
+						"topic", topic.Topic,
+						"partition", partition.Partition,
+						"err", partition.Err,
+					)
+				}
+				if len(partition.Records) == 0 {
+					continue
+				}
+				pw := w.workers[topic.Topic][partition.Partition]
+				if pw == nil {
+					if w.group {
+						continue // revoked before we could dispatch
+					}
+					pw = w.startLocked(topic.Topic, partition.Partition)
+				}
+				select {
+				case pw.records <- partition.Records:
+				case <-pw.done:
+				}
+			}
+		}
+	}
+}
+
+func (w *PartitionWorkers) start(assigned map[string][]int32) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for topic, partitions := range assigned {
+		for _, partition := range partitions {
+			if w.workers[topic][partition] == nil {
+				w.startLocked(topic, partition)
+			}
+		}
+	}
+}
+
+func (w *PartitionWorkers) startLocked(topic string, partition int32) *partitionWorker {
+	ctx, cancel := context.WithCancel(w.cl.ctx)
+	pw := &partitionWorker{
+		cancel:  cancel,
+		records: make(chan []*Record, 4),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(pw.done)
+		defer cancel()
+		w.fn(ctx, topic, partition, pw.records)
+	}()
+
+	workers := w.workers[topic]
+	if workers == nil {
+		workers = make(map[int32]*partitionWorker)
+		w.workers[topic] = workers
+	}
+	workers[partition] = pw
+	return pw
+}
+
+// stop stops and waits for the workers for the given partitions, or for all
+// workers if all is true. If lost, the workers' contexts are canceled before
+// waiting.
+//
+// Before stopping, we wait for any in progress poll to be dispatched, which
+// ensures that everything polled before a revoke is processed.
+func (w *PartitionWorkers) stop(partitions map[string][]int32, all, lost bool) {
+	w.mu.Lock()
+	if w.polling {
+		w.pollCancel()
+		for gen := w.pollGen; w.polling && w.pollGen == gen; {
+			w.cond.Wait()
+		}
+	}
+
+	var stopping []*partitionWorker
+	stopWorker := func(topic string, partition int32) {
+		pw := w.workers[topic][partition]
+		if pw == nil {
+			return
+		}
+		delete(w.workers[topic], partition)
+		if len(w.workers[topic]) == 0 {
+			delete(w.workers, topic)
+		}
+		if lost {
+			pw.cancel()
+		}
+		close(pw.records)
+		stopping = append(stopping, pw)
+	}
+	if all {
+		for topic, workers := range w.workers {
+			for partition := range workers {
+				stopWorker(topic, partition)
+			}
+		}
+	} else {
+		for topic, topicPartitions := range partitions {
+			for _, partition := range topicPartitions {
+				stopWorker(topic, partition)
+			}
+		}
+	}
+	w.mu.Unlock()
+
+	for _, pw := range stopping {
+		<-pw.done
+	}
+}
@@ -0,0 +1,87 @@
+package kgo
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestPartitionWorkers(t *testing.T) {
+	cl, err := NewClient(SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+
+	var mu sync.Mutex
+	got := make(map[int32][]int64)
+	var lostCanceled bool
+	w := NewPartitionWorkers(cl, func(ctx context.Context, topic string, partition int32, records <-chan []*Record) {
+		for rs := range records {
+			for _, r := range rs {
+				mu.Lock()
+				got[partition] = append(got[partition], r.Offset)
+				mu.Unlock()
+			}
+		}
+		if partition == 1 {
+			mu.Lock()
+			lostCanceled = ctx.Err() != nil
+			mu.Unlock()
+		}
+	})
+	w.group = true // as if GroupOpt was applied
+
+	w.start(map[string][]int32{"foo": {0, 1}})
+
+	fetch := func(partition int32, offsets ...int64) Fetch {
+		var rs []*Record
+		for _, offset := range offsets {
+			rs = append(rs, &Record{Topic: "foo", Partition: partition, Offset: offset})
+		}
+		return Fetch{Topics: []FetchTopic{{
+			Topic:      "foo",
+			Partitions: []FetchPartition{{Partition: partition, Records: rs}},
+		}}}
+	}
+
+	w.mu.Lock()
+	w.dispatchLocked(Fetches{
+		fetch(0, 0, 1),
+		fetch(1, 5),
+		fetch(0, 2),
+		fetch(2, 9), // not assigned; dropped
+	})
+	w.mu.Unlock()
+
+	// Revoking waits for the worker to process everything dispatched.
+	w.stop(map[string][]int32{"foo": {0}}, false, false)
+	mu.Lock()
+	if exp := []int64{0, 1, 2}; len(got[0]) != len(exp) || got[0][0] != 0 || got[0][1] != 1 || got[0][2] != 2 {
+		t.Errorf("partition 0: got offsets %v, exp %v", got[0], exp)
+	}
+	if _, exists := got[2]; exists {
+		t.Error("unassigned partition 2 had a worker")
+	}
+	mu.Unlock()
+
+	// Losing cancels the worker's context.
+	w.stop(map[string][]int32{"foo": {1}}, false, true)
+	mu.Lock()
+	if len(got[1]) != 1 || got[1][0] != 5 {
+		t.Errorf("partition 1: got offsets %v, exp [5]", got[1])
+	}
+	if !lostCanceled {
+		t.Error("lost partition worker context was not canceled")
+	}
+	mu.Unlock()
+
+	if len(w.workers) != 0 {
+		t.Errorf("got %d topics with workers after stopping, exp 0", len(w.workers))
+	}
+
+	// Run quits once its context is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.Run(ctx)
+}
@@ -178,7 +178,7 @@ func TestRecBatchAppendTo(t *testing.T) {
 	// ***As a produce request***
 	txid := "tx"
 	kmsgReq := kmsg.ProduceRequest{
-		Version:       99,
+		Version:       8, // our produceRequest does not support flexible versions
 		TransactionID: &txid,
 		Acks:          -1,
 		TimeoutMillis: 1000,
@@ -191,7 +191,7 @@ func TestRecBatchAppendTo(t *testing.T) {
 		}},
 	}
 	ourReq := produceRequest{
-		version:       99,
+		version:       8,
 		txnID:         &txid,
 		acks:          -1,
 		timeout:       1000,
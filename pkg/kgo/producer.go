@@ -263,16 +263,15 @@ func (cl *Client) failProducerID(id int64, epoch int16, err error) {
 // doInitProducerID inits the idempotent ID and potentially the transactional
 // producer epoch.
 func (cl *Client) doInitProducerID(lastID int64, lastEpoch int16) *producerID {
-	req := &kmsg.InitProducerIDRequest{
-		TransactionalID: cl.cfg.txnID,
-		ProducerID:      lastID,
-		ProducerEpoch:   lastEpoch,
-	}
+	req := kmsg.NewPtrInitProducerIDRequest()
+	req.TransactionalID = cl.cfg.txnID
+	req.ProducerID = lastID
+	req.ProducerEpoch = lastEpoch
 	if cl.cfg.txnID != nil {
 		req.TransactionTimeoutMillis = int32(cl.cfg.txnTimeout.Milliseconds())
 	}
 
-	kresp, err := cl.Request(cl.ctx, req)
+	kresp, err := cl.internalRequest(cl.ctx, req)
 	if err != nil {
 		// If our broker is too old, then well...
 		//
@@ -122,6 +122,9 @@ type FetchPartition struct {
 	Partition int32
 	// Err is an error for this partition in the fetch.
 	//
+	// Kafka errors are wrapped in *ErrResponse; use errors.Is to check
+	// for specific Kafka errors.
+	//
 	// Note that if this is a fatal error, such as data loss or non
 	// retriable errors, this partition will never be fetched again.
 	Err error
@@ -155,11 +158,27 @@ type FetchTopic struct {
 type Fetch struct {
 	// Topics are all topics being responded to from a fetch to a broker.
 	Topics []FetchTopic
+
+	// bufs are the pooled buffers that records alias when consuming with
+	// ZeroCopyFetches.
+	bufs *fetchBufs
 }
 
 // Fetches is a group of fetches from brokers.
 type Fetches []Fetch
 
+// Release returns the buffers backing these fetches to the client for reuse
+// when consuming with ZeroCopyFetches. After releasing, records from these
+// fetches, and their keys, values, and header values, must not be used.
+//
+// Releasing fetches more than once is safe. Without ZeroCopyFetches, this is
+// a no-op.
+func (fs Fetches) Release() {
+	for _, f := range fs {
+		f.bufs.release()
+	}
+}
+
 // FetchError is an error in a fetch along with the topic and partition that
 // the error was on.
 type FetchError struct {
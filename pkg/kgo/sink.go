@@ -175,9 +175,8 @@ func (s *sink) createReq() (*produceRequest, *kmsg.AddPartitionsToTxnRequest, bo
 		if transactional && !recBuf.addedToTxn {
 			recBuf.addedToTxn = true
 			if txnReq == nil {
-				txnReq = &kmsg.AddPartitionsToTxnRequest{
-					TransactionalID: *req.txnID,
-				}
+				txnReq = kmsg.NewPtrAddPartitionsToTxnRequest()
+				txnReq.TransactionalID = *req.txnID
 			}
 			if txnAddedTopics == nil {
 				txnAddedTopics = make(map[string]int, 10)
@@ -186,9 +185,9 @@ func (s *sink) createReq() (*produceRequest, *kmsg.AddPartitionsToTxnRequest, bo
 			if !exists {
 				idx = len(txnReq.Topics)
 				txnAddedTopics[recBuf.topic] = idx
-				txnReq.Topics = append(txnReq.Topics, kmsg.AddPartitionsToTxnRequestTopic{
-					Topic: recBuf.topic,
-				})
+				txnTopic := kmsg.NewAddPartitionsToTxnRequestTopic()
+				txnTopic.Topic = recBuf.topic
+				txnReq.Topics = append(txnReq.Topics, txnTopic)
 			}
 			txnReq.Topics[idx].Partitions = append(txnReq.Topics[idx].Partitions, recBuf.partition)
 		}
@@ -358,7 +357,7 @@ func (s *sink) doTxnReq(
 	start := time.Now()
 	tries := 0
 start:
-	kresp, err := s.cl.Request(s.cl.ctx, txnReq)
+	kresp, err := s.cl.internalRequest(s.cl.ctx, txnReq)
 
 	if err != nil { // if we could not even complete the request, this is fatal.
 		return err
@@ -577,7 +576,7 @@ func (s *sink) handleReqResp(req *produceRequest, resp kmsg.Response, err error)
 				// we do not try any logic in the idempotent case.
 				if s.cl.cfg.stopOnDataLoss || err == kerr.UnknownProducerID && s.cl.producer.idVersion >= 3 && s.cl.cfg.txnID != nil {
 					s.cl.failProducerID(req.producerID, req.producerEpoch, err)
-					s.cl.finishBatch(batch.recBatch, partition, rPartition.BaseOffset, err)
+					s.cl.finishBatch(batch.recBatch, partition, rPartition.BaseOffset, wrapResponseErr(err, req.Key(), s.b.id, topic, partition))
 					continue
 				}
 				if s.cl.cfg.onDataLoss != nil {
@@ -586,11 +585,20 @@ func (s *sink) handleReqResp(req *produceRequest, resp kmsg.Response, err error)
 				batch.owner.resetSeq()
 				reqRetry.addSeqBatch(topic, partition, batch)
 
+			case kerr.IsProducerFenced(err) && s.cl.cfg.txnID != nil:
+				// A fenced transactional producer can never produce
+				// again; we fail our ID so that all future produces
+				// and EndTransaction fail. Idempotent producers have
+				// no transactional ID to be fenced on, so an
+				// InvalidProducerEpoch is simply returned.
+				s.cl.failProducerID(req.producerID, req.producerEpoch, err)
+				s.cl.finishBatch(batch.recBatch, partition, rPartition.BaseOffset, wrapResponseErr(err, req.Key(), s.b.id, topic, partition))
+
 			case err == kerr.DuplicateSequenceNumber: // ignorable, but we should not get
 				err = nil
 				fallthrough
 			default:
-				s.cl.finishBatch(batch.recBatch, partition, rPartition.BaseOffset, err)
+				s.cl.finishBatch(batch.recBatch, partition, rPartition.BaseOffset, wrapResponseErr(err, req.Key(), s.b.id, topic, partition))
 			}
 		}
 
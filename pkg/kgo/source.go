@@ -3,8 +3,11 @@ package kgo
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/twmb/kafka-go/pkg/internal/compress"
+	"github.com/twmb/kafka-go/pkg/kbin"
 	"github.com/twmb/kafka-go/pkg/kerr"
 	"github.com/twmb/kafka-go/pkg/kmsg"
 )
@@ -304,6 +307,14 @@ func (c *cursor) setLoadingOffsetsLocked(fromSeq uint64) {
 	c.loadingOffsets = true
 }
 
+// isLoadingOffsets returns whether the cursor is loading offsets, which is
+// also the state a cursor is left in when it is stopped.
+func (c *cursor) isLoadingOffsets() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loadingOffsets
+}
+
 // bufferedFetch is a fetch response waiting to be consumed by the client, as
 // well as offsets to update cursors to once the fetch is taken.
 type bufferedFetch struct {
@@ -349,15 +360,26 @@ func (s *source) createReq() (req *fetchRequest, again bool) {
 		maxPartBytes:   s.cl.cfg.maxPartBytes,
 		rack:           s.cl.cfg.rack,
 		isolationLevel: s.cl.cfg.isolationLevel,
+		zeroCopy:       s.cl.cfg.zeroCopy,
+		stream:         s.cl.cfg.streamFetches,
+		decompressor:   s.cl.decompressor,
 
 		session: &s.session,
 	}
 
+	// Bounded partitions that have been consumed to their end are finished
+	// after we release our locks; finishing can wake PollFetches.
+	bounds := s.cl.consumer.loadBounds()
+	var finished []*cursor
+
 	var reloadOffsets offsetsLoad
 	defer func() {
 		if !reloadOffsets.isEmpty() {
 			reloadOffsets.mergeInto(&s.cl.consumer)
 		}
+		for _, c := range finished {
+			s.cl.consumer.finishBounded(bounds, c.topic, c.partition)
+		}
 	}()
 
 	s.mu.Lock()
@@ -387,6 +409,17 @@ func (s *source) createReq() (req *fetchRequest, again bool) {
 			continue
 		}
 
+		// If we are consuming to an end offset and we are already at
+		// the end, we stop the cursor the same way we stop cursors
+		// that are loading offsets: it remains stopped until it is
+		// reassigned.
+		if end, ok := bounds.end(c.topic, c.partition, c.seq); ok && c.offset >= end {
+			c.setLoadingOffsetsLocked(c.seq)
+			finished = append(finished, c)
+			c.mu.Unlock()
+			continue
+		}
+
 		// KIP-320: if we needLoadEpoch, we just migrated from one
 		// broker to another. We need to validate the leader epoch on
 		// the new broker to see if we experienced data loss before we
@@ -467,6 +500,25 @@ func (s *source) backoff() {
 }
 
 func (s *source) handleReqResp(req *fetchRequest, kresp kmsg.Response, err error) {
+	// With zero copy fetches, the pooled buffers are owned by the fetch
+	// we build below; any return before then releases them.
+	var bufs *fetchBufs
+	var streamedBatches [][][]fetchBatch
+	switch r := kresp.(type) {
+	case *zeroCopyFetchResponse:
+		bufs = r.bufs
+		kresp = &r.FetchResponse
+	case *streamedFetchResponse:
+		streamedBatches = r.batches
+		kresp = &r.FetchResponse
+	}
+	keepBufs := false
+	defer func() {
+		if !keepBufs {
+			bufs.release()
+		}
+	}()
+
 	if err != nil {
 		s.backoff() // backoff before unuseAll to avoid inflight race
 		s.unuseAll(req.offsets)
@@ -513,7 +565,9 @@ func (s *source) handleReqResp(req *fetchRequest, kresp kmsg.Response, err error
 	// for that partition a per to the client's configured offset policy.
 	var reloadOffsets offsetsLoad
 	var needsMetaUpdate bool
-	for _, rTopic := range resp.Topics {
+	bounds := s.cl.consumer.loadBounds()
+	var finished []*cursor
+	for t, rTopic := range resp.Topics {
 		topic := rTopic.Topic
 		topicOffsets, ok := req.offsets[topic]
 		if !ok {
@@ -533,12 +587,49 @@ func (s *source) handleReqResp(req *fetchRequest, kresp kmsg.Response, err error
 				continue
 			}
 
-			fetchPart, partNeedsMetaUpdate, migrating := partOffset.processRespPartition(topic, resp.Version, rPartition, s.cl.decompressor, &s.session)
+			// A streamed response has already decompressed the
+			// batches of each partition as it was read.
+			var batches []fetchBatch
+			if streamedBatches != nil {
+				batches = streamedBatches[t][i]
+			}
+
+			fetchPart, partNeedsMetaUpdate, migrating := partOffset.processRespPartition(topic, resp.Version, rPartition, batches, s.cl.decompressor, bufs, &s.session)
 			if migrating {
 				continue
 			}
 
-			fetchTopic.Partitions = append(fetchTopic.Partitions, fetchPart)
+			// If this partition is bounded, we drop anything at or
+			// past the end and stop the partition once we reach
+			// the end. When consuming between times, we also drop
+			// records whose timestamps are out of range: the end
+			// offset is only the first record at or after the end
+			// time, and later timestamps need not increase.
+			if end, ok := bounds.end(topic, partition, partOffset.seq); ok {
+				keep := fetchPart.Records[:0]
+				reachedEnd := false
+				for _, r := range fetchPart.Records {
+					if r.Offset >= end {
+						reachedEnd = true
+						continue
+					}
+					if bounds.inTimes(r) {
+						keep = append(keep, r)
+					}
+				}
+				fetchPart.Records = keep
+				if reachedEnd {
+					partOffset.offset = end
+				}
+				if partOffset.offset >= end {
+					partOffset.from.setLoadingOffsets(partOffset.seq)
+					finished = append(finished, partOffset.from)
+				}
+			}
+
+			userPart := fetchPart
+			userPart.Err = wrapResponseErr(fetchPart.Err, req.Key(), s.b.id, topic, partition)
+			fetchTopic.Partitions = append(fetchTopic.Partitions, userPart)
 			needsMetaUpdate = needsMetaUpdate || partNeedsMetaUpdate
 
 			// If we are out of range, we reset to what we can.
@@ -563,12 +654,29 @@ func (s *source) handleReqResp(req *fetchRequest, kresp kmsg.Response, err error
 			// rare". Rather than falling back to listing offsets,
 			// we will set in a cycle of validating the leader
 			// epoch until the follower has caught up.
+			//
+			// If the user's policy is to not reset, we leave the
+			// cursor loading offsets without loading any. The
+			// partition is stopped until it is reassigned, which
+			// bumps the cursor's seq; for groups, SetOffsets
+			// reassigns stopped partitions.
 			if fetchPart.Err == kerr.OffsetOutOfRange {
 				partOffset.from.setLoadingOffsets(partOffset.seq)
-				if partOffset.currentPreferredReplica == -1 {
-					reloadOffsets.list.setLoadOffset(topic, partition, s.cl.cfg.resetOffset, -1, req.maxSeq)
-				} else if partOffset.offset < fetchPart.LogStartOffset {
-					reloadOffsets.list.setLoadOffset(topic, partition, s.cl.cfg.resetOffset, s.b.id, req.maxSeq)
+				if partOffset.currentPreferredReplica == -1 || partOffset.offset < fetchPart.LogStartOffset {
+					reset := s.cl.cfg.outOfRangeOffset(topic, partition, partOffset.offset)
+					if reset.noReset {
+						s.cl.cfg.logger.Log(LogLevelWarn, "offset out of range and the policy is to not reset; stopping partition",
+							"topic", topic,
+							"partition", partition,
+							"offset", partOffset.offset,
+						)
+						continue
+					}
+					replica := int32(-1)
+					if partOffset.currentPreferredReplica != -1 {
+						replica = s.b.id
+					}
+					reloadOffsets.list.setLoadOffset(topic, partition, reset, replica, req.maxSeq)
 				} else { // partOffset.offset > fetchPart.HighWatermark
 					reloadOffsets.epoch.setLoadOffset(topic, partition, Offset{
 						request:      partOffset.offset,
@@ -604,6 +712,10 @@ func (s *source) handleReqResp(req *fetchRequest, kresp kmsg.Response, err error
 		reloadOffsets.mergeInto(&s.cl.consumer)
 	}
 
+	for _, c := range finished {
+		s.cl.consumer.finishBounded(bounds, c.topic, c.partition)
+	}
+
 	if needsMetaUpdate {
 		s.cl.triggerUpdateMetadataNow()
 		s.cl.cfg.logger.Log(LogLevelInfo, "fetch had a partition error; trigging metadata update and resetting the session")
@@ -611,6 +723,8 @@ func (s *source) handleReqResp(req *fetchRequest, kresp kmsg.Response, err error
 	}
 
 	if len(newFetch.Topics) > 0 {
+		newFetch.bufs = bufs
+		keepBufs = true
 		s.buffered = bufferedFetch{
 			fetch:      newFetch,
 			seq:        req.maxSeq,
@@ -625,7 +739,8 @@ func (s *source) handleReqResp(req *fetchRequest, kresp kmsg.Response, err error
 
 // processRespPartition processes all records in all potentially compressed
 // batches (or message sets) and returns a fetch partition containing those
-// records.
+// records. If batches is non-nil, it holds the partition's already read and
+// decompressed record batches.
 //
 // This returns that a metadata update is needed if any part has a recoverable
 // error.
@@ -636,7 +751,9 @@ func (o *seqOffsetFrom) processRespPartition(
 	topic string,
 	version int16,
 	rPartition *kmsg.FetchResponseTopicPartition,
+	batches []fetchBatch,
 	decompressor *decompressor,
+	bufs *fetchBufs,
 	session *fetchSession,
 ) (
 	fetchPart FetchPartition,
@@ -668,11 +785,13 @@ func (o *seqOffsetFrom) processRespPartition(
 
 	switch version {
 	case 0, 1:
-		o.processV0Messages(topic, &fetchPart, kmsg.ReadV0Messages(rPartition.RecordBatches), decompressor)
+		o.processV0Messages(topic, &fetchPart, kmsg.ReadV0Messages(rPartition.RecordBatches), decompressor, bufs)
 	case 2, 3:
-		o.processV1Messages(topic, &fetchPart, kmsg.ReadV1Messages(rPartition.RecordBatches), decompressor)
+		o.processV1Messages(topic, &fetchPart, kmsg.ReadV1Messages(rPartition.RecordBatches), decompressor, bufs)
 	default:
-		batches := kmsg.ReadRecordBatches(rPartition.RecordBatches)
+		if batches == nil {
+			batches = readFetchBatches(rPartition.RecordBatches, decompressor, bufs)
+		}
 		var numPartitionRecords int
 		for i := range batches {
 			numPartitionRecords += int(batches[i].NumRecords)
@@ -680,7 +799,7 @@ func (o *seqOffsetFrom) processRespPartition(
 		fetchPart.Records = make([]*Record, 0, numPartitionRecords)
 		aborter := buildAborter(rPartition)
 		for i := range batches {
-			o.processRecordBatch(topic, &fetchPart, &batches[i], keepControl, aborter, decompressor)
+			o.processRecordBatch(topic, &fetchPart, &batches[i], keepControl, aborter, bufs)
 			if fetchPart.Err != nil {
 				break
 			}
@@ -758,39 +877,77 @@ func (a aborter) trackAbortedPID(producerID int64) {
 // processing records to fetch part //
 //////////////////////////////////////
 
+// fetchBatch is a record batch whose records have been decompressed.
+type fetchBatch struct {
+	kmsg.RecordBatch
+	decompressErr error // if non-nil, Records is still compressed
+}
+
+// readFetchBatches reads all record batches in raw, decompressing the records
+// of each batch. Batches that are not magic v2 are left as is for
+// processRecordBatch to reject.
+func readFetchBatches(raw []byte, decompressor *decompressor, bufs *fetchBufs) []fetchBatch {
+	batches := kmsg.ReadRecordBatches(raw)
+	fetchBatches := make([]fetchBatch, len(batches))
+	for i := range batches {
+		batch := &fetchBatches[i]
+		batch.RecordBatch = batches[i]
+		if batch.Magic != 2 {
+			continue
+		}
+		if compression := byte(batch.Attributes & 0x0007); compression != 0 {
+			if records, err := bufs.decompress(decompressor, batch.Records, compression); err != nil {
+				batch.decompressErr = err
+			} else {
+				batch.Records = records
+			}
+		}
+	}
+	return fetchBatches
+}
+
 func (o *seqOffset) processRecordBatch(
 	topic string,
 	fetchPart *FetchPartition,
-	batch *kmsg.RecordBatch,
+	batch *fetchBatch,
 	keepControl bool,
 	aborter aborter,
-	decompressor *decompressor,
+	bufs *fetchBufs,
 ) {
 	if batch.Magic != 2 {
 		fetchPart.Err = fmt.Errorf("unknown batch magic %d", batch.Magic)
 		return
 	}
-	rawRecords := batch.Records
-	if compression := byte(batch.Attributes & 0x0007); compression != 0 {
-		var err error
-		if rawRecords, err = decompressor.decompress(rawRecords, compression); err != nil {
-			fetchPart.Err = fmt.Errorf("unable to decompress batch: %v", err)
-			return
-		}
+	if batch.decompressErr != nil {
+		fetchPart.Err = fmt.Errorf("unable to decompress batch: %v", batch.decompressErr)
+		return
 	}
-	krecords, err := kmsg.ReadRecords(int(batch.NumRecords), rawRecords)
+	krecords, err := kmsg.ReadRecords(int(batch.NumRecords), batch.Records)
 	if err != nil {
 		fetchPart.Err = fmt.Errorf("invalid record batch: %v", err)
 		return
 	}
 
-	abortBatch := aborter.shouldAbortBatch(batch)
+	abortBatch := aborter.shouldAbortBatch(&batch.RecordBatch)
 	var lastRecord *Record
+
+	// Zero copy records already live only as long as their fetch, so we
+	// allocate them in one slab per batch. Otherwise, records are
+	// allocated individually so that keeping one record does not keep
+	// every other record in its batch alive.
+	var slab []Record
+	if bufs != nil {
+		slab = make([]Record, len(krecords))
+	}
 	for i := range krecords {
-		record := recordToRecord(
+		record := new(Record)
+		if slab != nil {
+			record = &slab[i]
+		}
+		*record = recordToRecord(
 			topic,
 			fetchPart.Partition,
-			batch,
+			&batch.RecordBatch,
 			&krecords[i],
 		)
 		lastRecord = record
@@ -807,6 +964,7 @@ func (o *seqOffset) processV1Messages(
 	fetchPart *FetchPartition,
 	messages []kmsg.MessageV1,
 	decompressor *decompressor,
+	bufs *fetchBufs,
 ) {
 	for i := range messages {
 		message := &messages[i]
@@ -818,7 +976,7 @@ func (o *seqOffset) processV1Messages(
 			continue
 		}
 
-		rawMessages, err := decompressor.decompress(message.Value, compression)
+		rawMessages, err := bufs.decompress(decompressor, message.Value, compression)
 		if err != nil {
 			fetchPart.Err = fmt.Errorf("unable to decompress messages: %v", err)
 			return
@@ -861,6 +1019,7 @@ func (o *seqOffset) processV0Messages(
 	fetchPart *FetchPartition,
 	messages []kmsg.MessageV0,
 	decompressor *decompressor,
+	bufs *fetchBufs,
 ) {
 	for i := range messages {
 		message := &messages[i]
@@ -872,7 +1031,7 @@ func (o *seqOffset) processV0Messages(
 			continue
 		}
 
-		rawMessages, err := decompressor.decompress(message.Value, compression)
+		rawMessages, err := bufs.decompress(decompressor, message.Value, compression)
 		if err != nil {
 			fetchPart.Err = fmt.Errorf("unable to decompress messages: %v", err)
 			return
@@ -950,7 +1109,7 @@ func recordToRecord(
 	partition int32,
 	batch *kmsg.RecordBatch,
 	record *kmsg.Record,
-) *Record {
+) Record {
 	h := make([]RecordHeader, 0, len(record.Headers))
 	for _, kv := range record.Headers {
 		h = append(h, RecordHeader{
@@ -959,7 +1118,7 @@ func recordToRecord(
 		})
 	}
 
-	return &Record{
+	return Record{
 		Key:         record.Key,
 		Value:       record.Value,
 		Headers:     h,
@@ -1028,6 +1187,13 @@ type fetchRequest struct {
 
 	isolationLevel int8
 
+	// zeroCopy, if true, reads the response into pooled buffers; see
+	// ZeroCopyFetches. stream, if true, decodes the response as it is
+	// read, decompressing with decompressor; see StreamFetchResponses.
+	zeroCopy     bool
+	stream       bool
+	decompressor *decompressor
+
 	maxSeq     uint64
 	numOffsets int
 	offsets    map[string]map[int32]*seqOffsetFrom
@@ -1067,24 +1233,22 @@ func (f *fetchRequest) SetVersion(v int16) { f.version = v }
 func (f *fetchRequest) GetVersion() int16  { return f.version }
 func (f *fetchRequest) IsFlexible() bool   { return false } // version 11 is not flexible
 func (f *fetchRequest) AppendTo(dst []byte) []byte {
-	req := kmsg.FetchRequest{
-		Version:        f.version,
-		ReplicaID:      -1,
-		MaxWaitMillis:  f.maxWait,
-		MinBytes:       1,
-		MaxBytes:       f.maxBytes,
-		IsolationLevel: f.isolationLevel,
-		SessionID:      f.session.id,
-		SessionEpoch:   f.session.epoch,
-		Topics:         make([]kmsg.FetchRequestTopic, 0, len(f.offsets)),
-		Rack:           f.rack,
-	}
+	req := kmsg.NewFetchRequest()
+	req.Version = f.version
+	req.MaxWaitMillis = f.maxWait
+	req.MinBytes = 1
+	req.MaxBytes = f.maxBytes
+	req.IsolationLevel = f.isolationLevel
+	req.SessionID = f.session.id
+	req.SessionEpoch = f.session.epoch
+	req.Topics = make([]kmsg.FetchRequestTopic, 0, len(f.offsets))
+	req.Rack = f.rack
 
 	for topic, partitions := range f.offsets {
-		req.Topics = append(req.Topics, kmsg.FetchRequestTopic{
-			Topic:      topic,
-			Partitions: make([]kmsg.FetchRequestTopicPartition, 0, len(partitions)),
-		})
+		fetchTopic := kmsg.NewFetchRequestTopic()
+		fetchTopic.Topic = topic
+		fetchTopic.Partitions = make([]kmsg.FetchRequestTopicPartition, 0, len(partitions))
+		req.Topics = append(req.Topics, fetchTopic)
 		reqTopic := &req.Topics[len(req.Topics)-1]
 
 		if f.session.used == nil {
@@ -1108,13 +1272,12 @@ func (f *fetchRequest) AppendTo(dst []byte) []byte {
 			}
 
 			if !partInSession || sessionOffset != epochOffset {
-				reqTopic.Partitions = append(reqTopic.Partitions, kmsg.FetchRequestTopicPartition{
-					Partition:          partition,
-					CurrentLeaderEpoch: seqOffset.currentLeaderEpoch,
-					FetchOffset:        seqOffset.offset,
-					LogStartOffset:     -1,
-					PartitionMaxBytes:  f.maxPartBytes,
-				})
+				fetchPart := kmsg.NewFetchRequestTopicPartition()
+				fetchPart.Partition = partition
+				fetchPart.CurrentLeaderEpoch = seqOffset.currentLeaderEpoch
+				fetchPart.FetchOffset = seqOffset.offset
+				fetchPart.PartitionMaxBytes = f.maxPartBytes
+				reqTopic.Partitions = append(reqTopic.Partitions, fetchPart)
 				partsInSession[partition] = epochOffset
 			}
 		}
@@ -1126,9 +1289,172 @@ func (*fetchRequest) ReadFrom([]byte) error {
 	panic("unreachable -- the client never uses ReadFrom on its internal fetchRequest")
 }
 func (f *fetchRequest) ResponseKind() kmsg.Response {
+	if f.zeroCopy {
+		return &zeroCopyFetchResponse{
+			FetchResponse: kmsg.FetchResponse{Version: f.version},
+			bufs:          new(fetchBufs),
+		}
+	}
+	if f.stream {
+		return &streamedFetchResponse{
+			FetchResponse: kmsg.FetchResponse{Version: f.version},
+			decompressor:  f.decompressor,
+		}
+	}
 	return &kmsg.FetchResponse{Version: f.version}
 }
 
+// streamedFetchResponse is the response kind of fetch requests when using
+// StreamFetchResponses. The response is decoded as it is read from the
+// connection rather than after being read in full, and each partition's
+// record batches are decompressed as soon as the partition is read so that
+// the compressed batches are not held for the rest of the response.
+type streamedFetchResponse struct {
+	kmsg.FetchResponse
+	decompressor *decompressor
+
+	// batches holds the decompressed record batches of every partition,
+	// indexed by topic and then partition. Partitions read from message
+	// sets (fetch versions before 4) have nil batches and keep their raw
+	// RecordBatches.
+	batches [][][]fetchBatch
+}
+
+func (*streamedFetchResponse) readStreamed() {}
+
+// ReadFromReader decodes the response from b. This mirrors the generated
+// decoding for the versions that fetchRequest issues (v0 through v11, none
+// of which are flexible), but after each partition is read, its record
+// batches are decompressed and its raw RecordBatches are dropped.
+func (r *streamedFetchResponse) ReadFromReader(b *kbin.Reader) error {
+	version := r.Version
+	if version >= 1 {
+		r.ThrottleMillis = b.Int32()
+	}
+	if version >= 7 {
+		r.ErrorCode = b.Int16()
+		r.SessionID = b.Int32()
+	}
+	numTopics := b.ArrayLen()
+	if !b.Ok() {
+		return b.Complete()
+	}
+	r.Topics = make([]kmsg.FetchResponseTopic, 0, numTopics)
+	r.batches = make([][][]fetchBatch, 0, numTopics)
+	for i := int32(0); i < numTopics; i++ {
+		rTopic := kmsg.NewFetchResponseTopic()
+		rTopic.Topic = b.String()
+		numPartitions := b.ArrayLen()
+		if !b.Ok() {
+			return b.Complete()
+		}
+		rTopic.Partitions = make([]kmsg.FetchResponseTopicPartition, 0, numPartitions)
+		topicBatches := make([][]fetchBatch, 0, numPartitions)
+		for j := int32(0); j < numPartitions; j++ {
+			rPartition := kmsg.NewFetchResponseTopicPartition()
+			rPartition.Partition = b.Int32()
+			rPartition.ErrorCode = b.Int16()
+			rPartition.HighWatermark = b.Int64()
+			if version >= 4 {
+				rPartition.LastStableOffset = b.Int64()
+			}
+			if version >= 5 {
+				rPartition.LogStartOffset = b.Int64()
+			}
+			if version >= 4 {
+				numAborted := b.ArrayLen()
+				if !b.Ok() {
+					return b.Complete()
+				}
+				for k := int32(0); k < numAborted; k++ {
+					aborted := kmsg.NewFetchResponseTopicPartitionAbortedTransaction()
+					aborted.ProducerID = b.Int64()
+					aborted.FirstOffset = b.Int64()
+					rPartition.AbortedTransactions = append(rPartition.AbortedTransactions, aborted)
+				}
+			}
+			if version >= 11 {
+				rPartition.PreferredReadReplica = b.Int32()
+			}
+			rPartition.RecordBatches = b.NullableBytes()
+			if !b.Ok() {
+				return b.Complete()
+			}
+
+			var batches []fetchBatch
+			if version >= 4 {
+				batches = readFetchBatches(rPartition.RecordBatches, r.decompressor, nil)
+				rPartition.RecordBatches = nil
+			}
+			rTopic.Partitions = append(rTopic.Partitions, rPartition)
+			topicBatches = append(topicBatches, batches)
+		}
+		r.Topics = append(r.Topics, rTopic)
+		r.batches = append(r.batches, topicBatches)
+	}
+	return b.Complete()
+}
+
+// zeroCopyFetchResponse is the response kind of fetch requests when using
+// ZeroCopyFetches. The response is read into a pooled buffer, and all pooled
+// buffers that records from the response alias are tracked in bufs.
+type zeroCopyFetchResponse struct {
+	kmsg.FetchResponse
+	bufs *fetchBufs
+}
+
+func (r *zeroCopyFetchResponse) allocRead(size int) []byte { return r.bufs.get(size) }
+
+// fetchBufs tracks the pooled buffers that records in a Fetch alias. The
+// buffers are put back in their pool when the fetch is released.
+type fetchBufs struct {
+	released uint32 // atomic
+	bufs     [][]byte
+}
+
+var fetchBufPool = sync.Pool{New: func() interface{} { r := make([]byte, 0, 64<<10); return &r }}
+
+// get returns a pooled slice of length size and tracks it for release.
+func (b *fetchBufs) get(size int) []byte {
+	buf := *fetchBufPool.Get().(*[]byte)
+	if cap(buf) < size {
+		fetchBufPool.Put(&buf)
+		buf = make([]byte, size)
+	}
+	buf = buf[:size]
+	b.bufs = append(b.bufs, buf)
+	return buf
+}
+
+// decompress decompresses src into a tracked pooled buffer, or into a newly
+// allocated slice if b is nil (the fetch is not zero copy).
+func (b *fetchBufs) decompress(d *decompressor, src []byte, codec byte) ([]byte, error) {
+	if b == nil {
+		return d.Decompress(src, compress.Codec(codec))
+	}
+	dst, err := d.DecompressTo(b.get(0), src, compress.Codec(codec))
+	if err != nil {
+		return nil, err
+	}
+	// The decompressor may have grown the buffer; we track what we
+	// return so that the larger buffer is what is reused.
+	b.bufs[len(b.bufs)-1] = dst
+	return dst, nil
+}
+
+// release puts all tracked buffers back in the pool. Releasing more than
+// once is a no-op.
+func (b *fetchBufs) release() {
+	if b == nil || !atomic.CompareAndSwapUint32(&b.released, 0, 1) {
+		return
+	}
+	for _, buf := range b.bufs {
+		buf = buf[:0]
+		fetchBufPool.Put(&buf)
+	}
+	b.bufs = nil
+}
+
 // fetchSessions, introduced in KIP-227, allow us to send less information back
 // and forth to a Kafka broker. Rather than relying on forgotten topics to
 // remove partitions from a session, we just simply reset the session.
@@ -0,0 +1,354 @@
+package kgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/twmb/kafka-go/pkg/kbin"
+	"github.com/twmb/kafka-go/pkg/kerr"
+	"github.com/twmb/kafka-go/pkg/kmsg"
+)
+
+func TestZeroCopyFetch(t *testing.T) {
+	bb := kmsg.NewBatchBuilder()
+	bb.Codec = kmsg.CodecGzip
+	batch, err := bb.RecordBatch([]kmsg.BatchRecord{
+		{Key: []byte("k"), Value: []byte("v0")},
+		{Value: []byte("v1")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected build err: %v", err)
+	}
+	raw := (&kmsg.FetchResponse{
+		Version: 11,
+		Topics: []kmsg.FetchResponseTopic{{
+			Topic: "foo",
+			Partitions: []kmsg.FetchResponseTopicPartition{{
+				RecordBatches: batch.AppendTo(nil),
+			}},
+		}},
+	}).AppendTo(nil)
+
+	kresp := (&fetchRequest{version: 11, zeroCopy: true}).ResponseKind()
+	zc, ok := kresp.(*zeroCopyFetchResponse)
+	if !ok {
+		t.Fatalf("got response kind %T, exp *zeroCopyFetchResponse", kresp)
+	}
+	buf := zc.allocRead(len(raw))
+	copy(buf, raw)
+	if err := zc.ReadFrom(buf); err != nil {
+		t.Fatalf("unexpected read err: %v", err)
+	}
+
+	var fetchPart FetchPartition
+	batches := readFetchBatches(zc.Topics[0].Partitions[0].RecordBatches, newDecompressor(), zc.bufs)
+	new(seqOffset).processRecordBatch("foo", &fetchPart, &batches[0], false, nil, zc.bufs)
+	if fetchPart.Err != nil || len(fetchPart.Records) != 2 {
+		t.Fatalf("got %d records, err %v; exp 2 records", len(fetchPart.Records), fetchPart.Err)
+	}
+
+	// The response buffer and the decompressed buffer are both tracked,
+	// and the record values alias the decompressed buffer.
+	if len(zc.bufs.bufs) != 2 {
+		t.Fatalf("got %d tracked buffers, exp 2", len(zc.bufs.bufs))
+	}
+	decompressed := zc.bufs.bufs[1]
+	if i := bytes.Index(decompressed, []byte("v1")); i < 0 {
+		t.Error("decompressed buffer does not contain the record value")
+	} else if decompressed[i] = 'x'; string(fetchPart.Records[1].Value) != "x1" {
+		t.Errorf("record value %q does not alias the decompressed buffer", fetchPart.Records[1].Value)
+	}
+
+	fs := Fetches{{bufs: zc.bufs}}
+	fs.Release()
+	fs.Release() // double release is a no-op
+	if zc.bufs.bufs != nil {
+		t.Error("buffers still tracked after release")
+	}
+}
+
+func TestStreamedFetchResponse(t *testing.T) {
+	value := bytes.Repeat([]byte("v"), 100<<10)
+	bb := kmsg.NewBatchBuilder()
+	bb.Codec = kmsg.CodecGzip
+	batch, err := bb.RecordBatch([]kmsg.BatchRecord{{Value: value}})
+	if err != nil {
+		t.Fatalf("unexpected build err: %v", err)
+	}
+	partition := kmsg.NewFetchResponseTopicPartition()
+	partition.LastStableOffset = 1
+	partition.AbortedTransactions = []kmsg.FetchResponseTopicPartitionAbortedTransaction{{ProducerID: 3, FirstOffset: 4}}
+	partition.RecordBatches = batch.AppendTo(nil)
+	body := (&kmsg.FetchResponse{
+		Version: 11,
+		Topics: []kmsg.FetchResponseTopic{{
+			Topic:      "foo",
+			Partitions: []kmsg.FetchResponseTopicPartition{partition},
+		}},
+	}).AppendTo(nil)
+
+	// Two responses back to back: the first with trailing bytes that the
+	// decode does not read, to ensure the stream is drained.
+	var raw []byte
+	for corrID, trailing := range [][]byte{[]byte("junk"), nil} {
+		raw = kbin.AppendInt32(raw, int32(4+len(body)+len(trailing)))
+		raw = kbin.AppendInt32(raw, int32(corrID))
+		raw = append(raw, body...)
+		raw = append(raw, trailing...)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		server.Write(raw)
+		server.Close()
+	}()
+
+	var last kmsg.Response
+	for corrID := int32(0); corrID < 2; corrID++ {
+		kresp := (&fetchRequest{version: 11, stream: true, decompressor: newDecompressor()}).ResponseKind()
+		last = kresp
+		if _, ok := kresp.(interface{ readStreamed() }); !ok {
+			t.Fatalf("got response kind %T, exp a streamed response", kresp)
+		}
+		decodeErr, connErr := readStreamedResponse(client, corrID, 0, false, kresp)
+		if decodeErr != nil || connErr != nil {
+			t.Fatalf("response %d: unexpected errs: decode %v, conn %v", corrID, decodeErr, connErr)
+		}
+		// Every field but the raw record batches is decoded; the
+		// batches are decompressed as the partition is read, and the
+		// compressed batches are dropped.
+		streamed := kresp.(*streamedFetchResponse)
+		got := streamed.Topics[0].Partitions[0]
+		exp := partition
+		exp.RecordBatches = nil
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("response %d: got partition %+v, exp %+v", corrID, got, exp)
+		}
+		batches := streamed.batches[0][0]
+		if len(batches) != 1 || batches[0].decompressErr != nil {
+			t.Fatalf("response %d: got %d batches, exp 1 decompressed", corrID, len(batches))
+		}
+		var fetchPart FetchPartition
+		new(seqOffset).processRecordBatch("foo", &fetchPart, &batches[0], false, nil, nil)
+		if len(fetchPart.Records) != 1 || !bytes.Equal(fetchPart.Records[0].Value, value) {
+			t.Errorf("response %d: streamed records mismatch", corrID)
+		}
+	}
+
+	// Handling the response uses the already decompressed batches.
+	cl, err := NewClient(SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+	s := newSource(cl, &broker{id: 1})
+	c := &cursor{topic: "foo", partition: 0, source: s, preferredReplica: -1}
+	s.handleReqResp(&fetchRequest{
+		version: 11,
+		offsets: map[string]map[int32]*seqOffsetFrom{"foo": {0: c.use()}},
+	}, last, nil)
+	records := s.buffered.fetch.Topics[0].Partitions[0].Records
+	if len(records) != 1 || !bytes.Equal(records[0].Value, value) {
+		t.Errorf("got %d handled records, exp the streamed record", len(records))
+	}
+}
+
+func TestOnOffsetOutOfRange(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		policy  func(string, int32, int64) Offset
+		expLoad bool
+	}{
+		{"reset", func(string, int32, int64) Offset { return NewOffset().At(7) }, true},
+		{"fail", FailOffsetOutOfRange, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var gotTopic string
+			var gotPartition int32
+			var gotOffset int64
+			policy := func(topic string, partition int32, offset int64) Offset {
+				gotTopic, gotPartition, gotOffset = topic, partition, offset
+				return test.policy(topic, partition, offset)
+			}
+			cl, err := NewClient(SeedBrokers("127.0.0.1:1"), OnOffsetOutOfRange(policy))
+			if err != nil {
+				t.Fatalf("unexpected client err: %v", err)
+			}
+			defer cl.Close()
+
+			s := newSource(cl, &broker{id: 1})
+			c := &cursor{source: s, preferredReplica: -1}
+			c.offset = 5
+			req := &fetchRequest{
+				version: 11,
+				offsets: map[string]map[int32]*seqOffsetFrom{"foo": {0: c.use()}},
+			}
+			s.handleReqResp(req, &kmsg.FetchResponse{
+				Version: 11,
+				Topics: []kmsg.FetchResponseTopic{{
+					Topic: "foo",
+					Partitions: []kmsg.FetchResponseTopicPartition{{
+						ErrorCode:      kerr.OffsetOutOfRange.Code,
+						LogStartOffset: 10,
+					}},
+				}},
+			}, nil)
+
+			if gotTopic != "foo" || gotPartition != 0 || gotOffset != 5 {
+				t.Errorf("policy called with %s %d %d, exp foo 0 5", gotTopic, gotPartition, gotOffset)
+			}
+			if err := s.buffered.fetch.Topics[0].Partitions[0].Err; !errors.Is(err, kerr.OffsetOutOfRange) {
+				t.Errorf("got fetch err %v, exp OffsetOutOfRange", err)
+			}
+			if !c.loadingOffsets {
+				t.Error("cursor is not loading offsets, so it would be fetched again")
+			}
+
+			load, loading := cl.consumer.offsetsWaitingLoad.list["foo"][0]
+			if loading != test.expLoad {
+				t.Fatalf("got loading %v, exp %v", loading, test.expLoad)
+			}
+			if loading && load.request != 7 {
+				t.Errorf("got reset offset %d, exp 7", load.request)
+			}
+		})
+	}
+}
+
+func TestSetOffsetsResumesStoppedPartition(t *testing.T) {
+	cl, err := NewClient(SeedBrokers("127.0.0.1:1"), OnOffsetOutOfRange(FailOffsetOutOfRange))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+
+	s := newSource(cl, &broker{id: 1})
+	c := &cursor{topic: "foo", partition: 0, source: s, preferredReplica: -1}
+	c.offset = 5
+	tp := &topicPartition{cursor: c}
+	topic := newTopicPartitions("foo")
+	topic.load().all[0] = tp
+	cl.topics.Store(map[string]*topicPartitions{"foo": topic})
+	defer cl.topics.Store(make(map[string]*topicPartitions)) // our partition has no records to fail on close
+
+	// The partition is kept across cooperative rebalances, so setting
+	// its offset is the only way to resume it. Its head is unchanged.
+	head := EpochOffset{Epoch: -1, Offset: 5}
+	cl.consumer.mu.Lock()
+	cl.consumer.typ = consumerTypeGroup
+	cl.consumer.group = &groupConsumer{
+		cl:          cl,
+		uncommitted: uncommitted{"foo": {0: {head: head, committed: head}}},
+	}
+	cl.consumer.usingPartitions = []*topicPartition{tp}
+	cl.consumer.mu.Unlock()
+	defer func() {
+		cl.consumer.mu.Lock()
+		defer cl.consumer.mu.Unlock()
+		cl.consumer.typ, cl.consumer.group = consumerTypeUnset, nil
+	}()
+
+	s.handleReqResp(&fetchRequest{
+		version: 11,
+		offsets: map[string]map[int32]*seqOffsetFrom{"foo": {0: c.use()}},
+	}, &kmsg.FetchResponse{
+		Version: 11,
+		Topics: []kmsg.FetchResponseTopic{{
+			Topic: "foo",
+			Partitions: []kmsg.FetchResponseTopicPartition{{
+				ErrorCode:      kerr.OffsetOutOfRange.Code,
+				LogStartOffset: 10,
+			}},
+		}},
+	}, nil)
+	if !c.isLoadingOffsets() {
+		t.Fatal("partition was not stopped")
+	}
+
+	cl.SetOffsets(map[string]map[int32]EpochOffset{"foo": {0: head}})
+	if c.isLoadingOffsets() {
+		t.Error("partition is still stopped after setting its offset")
+	}
+	c.mu.Lock()
+	offset := c.offset
+	c.mu.Unlock()
+	if offset != 5 {
+		t.Errorf("got resumed offset %d, exp 5", offset)
+	}
+}
+
+func TestConsumeUntilOffsets(t *testing.T) {
+	batch, err := kmsg.NewBatchBuilder().RecordBatch([]kmsg.BatchRecord{
+		{Value: []byte("v0")},
+		{Value: []byte("v1")},
+		{Value: []byte("v2")},
+		{Value: []byte("v3")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected build err: %v", err)
+	}
+
+	cl, err := NewClient(SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatalf("unexpected client err: %v", err)
+	}
+	defer cl.Close()
+	cl.consumer.bounds.Store(newConsumeBounds(0, map[string]map[int32]int64{
+		"foo": {0: 2, 1: 0},
+	}))
+
+	s := newSource(cl, &broker{id: 1})
+
+	// Partition 1 starts at its end, so it is never fetched.
+	c1 := &cursor{topic: "foo", partition: 1, source: s, preferredReplica: -1}
+	s.addCursor(c1)
+	if _, again := s.createReq(); again {
+		t.Error("created fetch for a partition that is already at its end")
+	}
+	if !c1.loadingOffsets {
+		t.Error("partition at its end was not stopped")
+	}
+	if cl.consumer.complete {
+		t.Fatal("consuming completed before all partitions reached their end")
+	}
+
+	// Partition 0 is fetched past its end; records at and past the end
+	// are dropped.
+	c0 := &cursor{topic: "foo", partition: 0, source: s, preferredReplica: -1}
+	req := &fetchRequest{
+		version: 11,
+		offsets: map[string]map[int32]*seqOffsetFrom{"foo": {0: c0.use()}},
+	}
+	s.handleReqResp(req, &kmsg.FetchResponse{
+		Version: 11,
+		Topics: []kmsg.FetchResponseTopic{{
+			Topic: "foo",
+			Partitions: []kmsg.FetchResponseTopicPartition{{
+				HighWatermark: 4,
+				RecordBatches: batch.AppendTo(nil),
+			}},
+		}},
+	}, nil)
+
+	records := s.buffered.fetch.Topics[0].Partitions[0].Records
+	if len(records) != 2 || records[1].Offset != 1 {
+		t.Errorf("got %d records, exp offsets 0 and 1", len(records))
+	}
+	if offset := req.offsets["foo"][0].offset; offset != 2 {
+		t.Errorf("got next offset %d, exp 2", offset)
+	}
+	if !c0.loadingOffsets {
+		t.Error("partition at its end was not stopped")
+	}
+
+	fetches := cl.PollFetches(context.Background())
+	last := fetches[len(fetches)-1].Topics[0].Partitions[0]
+	if last.Err != ErrConsumeComplete {
+		t.Errorf("got last fetch err %v, exp ErrConsumeComplete", last.Err)
+	}
+}
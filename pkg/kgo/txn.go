@@ -405,16 +405,20 @@ func (cl *Client) EndTransaction(ctx context.Context, commit TransactionEndTry)
 		"epoch", epoch,
 		"commit", commit,
 	)
-	kresp, err := cl.Request(ctx, &kmsg.EndTxnRequest{
-		TransactionalID: *cl.cfg.txnID,
-		ProducerID:      id,
-		ProducerEpoch:   epoch,
-		Commit:          bool(commit),
-	})
+	req := kmsg.NewPtrEndTxnRequest()
+	req.TransactionalID = *cl.cfg.txnID
+	req.ProducerID = id
+	req.ProducerEpoch = epoch
+	req.Commit = bool(commit)
+	kresp, err := cl.internalRequest(ctx, req)
 	if err != nil {
 		return err
 	}
-	return kerr.ErrorForCode(kresp.(*kmsg.EndTxnResponse).ErrorCode)
+	err = kerr.ErrorForCode(kresp.(*kmsg.EndTxnResponse).ErrorCode)
+	if kerr.IsTxnFatal(err) {
+		cl.failProducerID(id, epoch, err)
+	}
+	return err
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -458,11 +462,11 @@ func (cl *Client) commitTransactionOffsets(
 
 	defer cl.consumer.mu.Unlock()
 	if cl.consumer.typ != consumerTypeGroup {
-		onDone(new(kmsg.TxnOffsetCommitRequest), new(kmsg.TxnOffsetCommitResponse), ErrNotGroup)
+		onDone(kmsg.NewPtrTxnOffsetCommitRequest(), kmsg.NewPtrTxnOffsetCommitResponse(), ErrNotGroup)
 		return
 	}
 	if len(uncommitted) == 0 {
-		onDone(new(kmsg.TxnOffsetCommitRequest), new(kmsg.TxnOffsetCommitResponse), nil)
+		onDone(kmsg.NewPtrTxnOffsetCommitRequest(), kmsg.NewPtrTxnOffsetCommitResponse(), nil)
 		return
 	}
 
@@ -498,12 +502,12 @@ func (cl *Client) addOffsetsToTxn(ctx context.Context, group string) error {
 		"producerEpoch", epoch,
 		"group", group,
 	)
-	kresp, err := cl.Request(ctx, &kmsg.AddOffsetsToTxnRequest{
-		TransactionalID: *cl.cfg.txnID,
-		ProducerID:      id,
-		ProducerEpoch:   epoch,
-		Group:           group,
-	})
+	req := kmsg.NewPtrAddOffsetsToTxnRequest()
+	req.TransactionalID = *cl.cfg.txnID
+	req.ProducerID = id
+	req.ProducerEpoch = epoch
+	req.Group = group
+	kresp, err := cl.internalRequest(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -525,7 +529,7 @@ func (g *groupConsumer) commitTxn(
 		onDone = func(_ *kmsg.TxnOffsetCommitRequest, _ *kmsg.TxnOffsetCommitResponse, _ error) {}
 	}
 	if len(uncommitted) == 0 { // only empty if called thru autocommit / default revoke
-		onDone(new(kmsg.TxnOffsetCommitRequest), new(kmsg.TxnOffsetCommitResponse), nil)
+		onDone(kmsg.NewPtrTxnOffsetCommitRequest(), kmsg.NewPtrTxnOffsetCommitResponse(), nil)
 		return
 	}
 
@@ -548,15 +552,14 @@ func (g *groupConsumer) commitTxn(
 	// addOffsetsToTxn.
 	id, epoch, _ := g.cl.producerID()
 	memberID := g.memberID
-	req := &kmsg.TxnOffsetCommitRequest{
-		TransactionalID: *g.cl.cfg.txnID,
-		Group:           g.id,
-		ProducerID:      id,
-		ProducerEpoch:   epoch,
-		Generation:      g.generation,
-		MemberID:        memberID,
-		InstanceID:      g.instanceID,
-	}
+	req := kmsg.NewPtrTxnOffsetCommitRequest()
+	req.TransactionalID = *g.cl.cfg.txnID
+	req.Group = g.id
+	req.ProducerID = id
+	req.ProducerEpoch = epoch
+	req.Generation = g.generation
+	req.MemberID = memberID
+	req.InstanceID = g.instanceID
 
 	if ctx.Done() != nil {
 		go func() {
@@ -583,24 +586,23 @@ func (g *groupConsumer) commitTxn(
 		g.cl.cfg.logger.Log(LogLevelDebug, "issuing txn offset commit", "uncommitted", uncommitted)
 
 		for topic, partitions := range uncommitted {
-			req.Topics = append(req.Topics, kmsg.TxnOffsetCommitRequestTopic{
-				Topic: topic,
-			})
-			reqTopic := &req.Topics[len(req.Topics)-1]
+			reqTopic := kmsg.NewTxnOffsetCommitRequestTopic()
+			reqTopic.Topic = topic
 			for partition, eo := range partitions {
-				reqTopic.Partitions = append(reqTopic.Partitions, kmsg.TxnOffsetCommitRequestTopicPartition{
-					Partition:   partition,
-					Offset:      eo.Offset,
-					LeaderEpoch: eo.Epoch,
-					Metadata:    &memberID,
-				})
+				reqPart := kmsg.NewTxnOffsetCommitRequestTopicPartition()
+				reqPart.Partition = partition
+				reqPart.Offset = eo.Offset
+				reqPart.LeaderEpoch = eo.Epoch
+				reqPart.Metadata = &memberID
+				reqTopic.Partitions = append(reqTopic.Partitions, reqPart)
 			}
+			req.Topics = append(req.Topics, reqTopic)
 		}
 
 		var kresp kmsg.Response
 		var err error
 		if len(req.Topics) > 0 {
-			kresp, err = g.cl.Request(commitCtx, req)
+			kresp, err = g.cl.internalRequest(commitCtx, req)
 		}
 		if err != nil {
 			onDone(req, nil, err)
@@ -0,0 +1,326 @@
+package kgo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/twmb/kafka-go/pkg/kbin"
+	"github.com/twmb/kafka-go/pkg/kmsg"
+)
+
+// WireEntry is a single request or response recorded with RecordWire.
+type WireEntry struct {
+	// Timestamp is when the request was written or the response was read.
+	Timestamp time.Time
+	// Broker is the node ID of the broker the entry was sent to or read
+	// from. Seed brokers have negative IDs.
+	Broker int32
+	// Addr is the host:port address the client dialed for the broker.
+	Addr string
+	// IsResponse is whether this entry is a response rather than a
+	// request.
+	IsResponse bool
+	// Key is the request key of the entry.
+	Key int16
+	// Version is the version of the request or response.
+	Version int16
+	// CorrelationID is the correlation ID of the request or response.
+	// Correlation IDs are per connection, so they are only unique per
+	// connection.
+	CorrelationID int32
+	// Body is the request or response body, not including the size prefix
+	// or the header.
+	Body []byte
+}
+
+// appendTo appends the entry to dst, prefixed by its size.
+func (e *WireEntry) appendTo(dst []byte) []byte {
+	start := len(dst)
+	dst = append(dst, 0, 0, 0, 0)
+	dst = kbin.AppendInt64(dst, e.Timestamp.UnixNano())
+	dst = kbin.AppendInt32(dst, e.Broker)
+	dst = kbin.AppendString(dst, e.Addr)
+	dst = kbin.AppendBool(dst, e.IsResponse)
+	dst = kbin.AppendInt16(dst, e.Key)
+	dst = kbin.AppendInt16(dst, e.Version)
+	dst = kbin.AppendInt32(dst, e.CorrelationID)
+	dst = kbin.AppendBytes(dst, e.Body)
+	binary.BigEndian.PutUint32(dst[start:], uint32(len(dst)-start-4))
+	return dst
+}
+
+// ReadWireEntries reads all entries written by RecordWire from r until EOF.
+func ReadWireEntries(r io.Reader) ([]WireEntry, error) {
+	var entries []WireEntry
+	sizeBuf := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, sizeBuf); err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return entries, err
+		}
+		size := int32(binary.BigEndian.Uint32(sizeBuf))
+		if size < 0 {
+			return entries, errors.New("invalid negative wire entry size")
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return entries, err
+		}
+
+		b := kbin.Reader{Src: buf}
+		e := WireEntry{
+			Timestamp:     time.Unix(0, b.Int64()),
+			Broker:        b.Int32(),
+			Addr:          b.String(),
+			IsResponse:    b.Bool(),
+			Key:           b.Int16(),
+			Version:       b.Int16(),
+			CorrelationID: b.Int32(),
+			Body:          b.Bytes(),
+		}
+		if err := b.Complete(); err != nil {
+			return entries, fmt.Errorf("invalid wire entry: %v", err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+// wireRecorder serializes writing entries from all broker connections.
+type wireRecorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	buf    []byte
+	err    error
+	logger Logger
+}
+
+func (r *wireRecorder) record(e *WireEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.buf = e.appendTo(r.buf[:0])
+	if _, r.err = r.w.Write(r.buf); r.err != nil {
+		r.logger.Log(LogLevelWarn, "unable to record wire entry, stopping recording", "err", r.err)
+	}
+}
+
+// record records a request or response body if the client is recording.
+func (cxn *brokerCxn) record(isResponse bool, key, version int16, corrID int32, body []byte) {
+	if cxn.recorder == nil {
+		return
+	}
+	cxn.recorder.record(&WireEntry{
+		Timestamp:     time.Now(),
+		Broker:        cxn.nodeID,
+		Addr:          cxn.addr,
+		IsResponse:    isResponse,
+		Key:           key,
+		Version:       version,
+		CorrelationID: corrID,
+		Body:          body,
+	})
+}
+
+// recordedRequestBody returns the body to record for req. SASLAuthenticate
+// requests carry credentials (PLAIN passwords, SCRAM proofs), so we never
+// record their auth bytes.
+func recordedRequestBody(req kmsg.Request) []byte {
+	if sasl, ok := req.(*kmsg.SASLAuthenticateRequest); ok {
+		redacted := *sasl
+		redacted.SASLAuthBytes = nil
+		return redacted.AppendTo(nil)
+	}
+	return req.AppendTo(nil)
+}
+
+// WireReplayer serves responses recorded with RecordWire to a client, allowing
+// a recorded session to be replayed without a cluster.
+//
+// Every broker address in the recording is served by its own local listener.
+// Use the replayer's Dial function with the Dialer option to redirect the
+// client's connections to those listeners, and use the recorded addresses as
+// seed brokers.
+//
+// Each request is answered with the next recorded response for the same
+// broker address, request key, and version, in the order the responses were
+// recorded. Timing is not replayed. If no recorded response remains for a
+// request, the replayer closes the connection.
+//
+// SASL is replayed only if the recording used SASLAuthenticate requests;
+// unframed v0 SASL exchanges are not recorded. Recorded SASLAuthenticate
+// auth bytes are empty (see RecordWire), so only mechanisms that accept an
+// empty server response, such as PLAIN, can be replayed.
+type WireReplayer struct {
+	mu      sync.Mutex
+	brokers map[string]*replayBroker
+	conns   map[net.Conn]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type replayKey struct{ key, version int16 }
+
+type replayBroker struct {
+	ln    net.Listener
+	resps map[replayKey][]WireEntry
+}
+
+// NewWireReplayer returns a replayer serving the responses in entries,
+// listening on a local port for every broker address in entries.
+func NewWireReplayer(entries []WireEntry) (*WireReplayer, error) {
+	r := &WireReplayer{
+		brokers: make(map[string]*replayBroker),
+		conns:   make(map[net.Conn]struct{}),
+	}
+	for _, e := range entries {
+		rb := r.brokers[e.Addr]
+		if rb == nil {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				r.Close()
+				return nil, err
+			}
+			rb = &replayBroker{
+				ln:    ln,
+				resps: make(map[replayKey][]WireEntry),
+			}
+			r.brokers[e.Addr] = rb
+		}
+		if e.IsResponse {
+			k := replayKey{e.Key, e.Version}
+			rb.resps[k] = append(rb.resps[k], e)
+		}
+	}
+	for _, rb := range r.brokers {
+		r.wg.Add(1)
+		go r.accept(rb)
+	}
+	return r, nil
+}
+
+// Addrs returns the recorded broker addresses the replayer serves.
+func (r *WireReplayer) Addrs() []string {
+	addrs := make([]string, 0, len(r.brokers))
+	for addr := range r.brokers {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// Dial dials the local listener serving the recorded broker at addr. This
+// can be used with the Dialer option.
+func (r *WireReplayer) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	rb, exists := r.brokers[addr]
+	if !exists {
+		return nil, fmt.Errorf("no recorded broker at %s", addr)
+	}
+	return stddial(ctx, rb.ln.Addr().String())
+}
+
+// Close closes all listeners and connections and waits for all serving to
+// stop.
+func (r *WireReplayer) Close() {
+	r.mu.Lock()
+	r.closed = true
+	for _, rb := range r.brokers {
+		rb.ln.Close()
+	}
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+func (r *WireReplayer) accept(rb *replayBroker) {
+	defer r.wg.Done()
+	for {
+		conn, err := rb.ln.Accept()
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			conn.Close()
+			return
+		}
+		r.conns[conn] = struct{}{}
+		r.wg.Add(1)
+		r.mu.Unlock()
+		go r.serve(rb, conn)
+	}
+}
+
+func (r *WireReplayer) serve(rb *replayBroker, conn net.Conn) {
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		conn.Close()
+		r.wg.Done()
+	}()
+
+	for {
+		raw, err := readConn(conn, 0, nil)
+		if err != nil || len(raw) < 8 {
+			return
+		}
+		key := int16(binary.BigEndian.Uint16(raw))
+		version := int16(binary.BigEndian.Uint16(raw[2:]))
+		corrID := int32(binary.BigEndian.Uint32(raw[4:]))
+
+		req := kmsg.RequestForKey(key)
+		if req == nil {
+			return
+		}
+		req.SetVersion(version)
+
+		// Produce requests with no acks have no response.
+		if produce, ok := req.(*kmsg.ProduceRequest); ok {
+			b := kbin.Reader{Src: raw[8:]}
+			b.NullableString() // client ID
+			if produce.IsFlexible() {
+				kmsg.SkipTags(&b)
+			}
+			if err := produce.ReadFrom(b.Src); err != nil {
+				return
+			}
+			if produce.Acks == 0 {
+				continue
+			}
+		}
+
+		r.mu.Lock()
+		k := replayKey{key, version}
+		resps := rb.resps[k]
+		if len(resps) == 0 {
+			r.mu.Unlock()
+			return
+		}
+		resp := resps[0]
+		rb.resps[k] = resps[1:]
+		r.mu.Unlock()
+
+		out := make([]byte, 4, 4+4+1+len(resp.Body))
+		out = kbin.AppendInt32(out, corrID)
+		if req.IsFlexible() && key != 18 { // see promisedResp doc
+			out = append(out, 0) // no header tags
+		}
+		out = append(out, resp.Body...)
+		binary.BigEndian.PutUint32(out, uint32(len(out)-4))
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
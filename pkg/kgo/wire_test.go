@@ -0,0 +1,207 @@
+package kgo
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/twmb/kafka-go/pkg/kmsg"
+	"github.com/twmb/kafka-go/pkg/sasl/plain"
+)
+
+func TestWireRecordReplay(t *testing.T) {
+	const addr = "127.0.0.1:9092"
+	apiVersions := (&kmsg.ApiVersionsResponse{
+		Version: 3,
+		ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+			{ApiKey: 15, MaxVersion: 0},
+			{ApiKey: 18, MaxVersion: 3},
+		},
+	}).AppendTo(nil)
+	describe := (&kmsg.DescribeGroupsResponse{
+		Groups: []kmsg.DescribeGroupsResponseGroup{{Group: "g", State: "Stable"}},
+	}).AppendTo(nil)
+
+	r, err := NewWireReplayer([]WireEntry{
+		{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: apiVersions},
+		{Addr: addr, IsResponse: true, Key: 15, Version: 0, Body: describe},
+	})
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+	if addrs := r.Addrs(); len(addrs) != 1 || addrs[0] != addr {
+		t.Errorf("got replayer addrs %v, exp [%s]", addrs, addr)
+	}
+
+	var recording bytes.Buffer
+	cl, err := NewClient(
+		SeedBrokers(addr),
+		Dialer(r.Dial),
+		RecordWire(&recording),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	kresp, err := cl.SeedBrokers()[0].Request(context.Background(), &kmsg.DescribeGroupsRequest{Groups: []string{"g"}})
+	if err != nil {
+		t.Fatalf("unexpected request err: %v", err)
+	}
+	resp := kresp.(*kmsg.DescribeGroupsResponse)
+	if len(resp.Groups) != 1 || resp.Groups[0].State != "Stable" {
+		t.Errorf("got unexpected replayed response %+v", resp)
+	}
+	cl.Close()
+
+	entries, err := ReadWireEntries(&recording)
+	if err != nil {
+		t.Fatalf("unable to read recording: %v", err)
+	}
+	exp := []struct {
+		isResponse bool
+		key        int16
+		version    int16
+		body       []byte
+	}{
+		{false, 18, 3, nil},
+		{true, 18, 3, apiVersions},
+		{false, 15, 0, nil},
+		{true, 15, 0, describe},
+	}
+	if len(entries) != len(exp) {
+		t.Fatalf("got %d recorded entries, exp %d", len(entries), len(exp))
+	}
+	for i, e := range entries {
+		x := exp[i]
+		if e.Addr != addr || e.Broker >= 0 || e.Timestamp.IsZero() ||
+			e.IsResponse != x.isResponse || e.Key != x.key || e.Version != x.version ||
+			x.body != nil && !bytes.Equal(e.Body, x.body) {
+			t.Errorf("entry %d: got %+v, exp %+v", i, e, x)
+		}
+	}
+	if entries[2].CorrelationID != entries[3].CorrelationID {
+		t.Errorf("request and response correlation IDs %d and %d differ", entries[2].CorrelationID, entries[3].CorrelationID)
+	}
+}
+
+func TestWireRecordRedactsSASL(t *testing.T) {
+	const addr = "127.0.0.1:9092"
+	resp := func(r kmsg.Response) []byte { return r.AppendTo(nil) }
+	r, err := NewWireReplayer([]WireEntry{
+		{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: resp(&kmsg.ApiVersionsResponse{
+			Version: 3,
+			ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+				{ApiKey: 15, MaxVersion: 0},
+				{ApiKey: 17, MaxVersion: 1},
+				{ApiKey: 18, MaxVersion: 3},
+				{ApiKey: 36, MaxVersion: 1},
+			},
+		})},
+		{Addr: addr, IsResponse: true, Key: 17, Version: 1, Body: resp(&kmsg.SASLHandshakeResponse{
+			Version:             1,
+			SupportedMechanisms: []string{"PLAIN"},
+		})},
+		{Addr: addr, IsResponse: true, Key: 36, Version: 1, Body: resp(&kmsg.SASLAuthenticateResponse{
+			Version: 1,
+		})},
+		{Addr: addr, IsResponse: true, Key: 15, Version: 0, Body: resp(&kmsg.DescribeGroupsResponse{})},
+	})
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+
+	const password = "hunter2"
+	var recording bytes.Buffer
+	cl, err := NewClient(
+		SeedBrokers(addr),
+		Dialer(r.Dial),
+		RecordWire(&recording),
+		SASL(plain.Plain(func(context.Context) (plain.Auth, error) {
+			return plain.Auth{User: "user", Pass: password}, nil
+		})),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	if _, err := cl.SeedBrokers()[0].Request(context.Background(), &kmsg.DescribeGroupsRequest{Groups: []string{"g"}}); err != nil {
+		t.Fatalf("unexpected request err: %v", err)
+	}
+	cl.Close()
+
+	if bytes.Contains(recording.Bytes(), []byte(password)) {
+		t.Error("recording contains the sasl password")
+	}
+	entries, err := ReadWireEntries(&recording)
+	if err != nil {
+		t.Fatalf("unable to read recording: %v", err)
+	}
+	var authenticates int
+	for _, e := range entries {
+		if e.Key == 36 {
+			authenticates++
+		}
+	}
+	if authenticates != 2 {
+		t.Errorf("got %d recorded sasl authenticate entries, exp 2", authenticates)
+	}
+}
+
+func TestSASLAuthenticateVersion(t *testing.T) {
+	// SASLAuthenticate is key 36. The broker supports a different max
+	// version for CreatePartitions (key 37), which we must not use.
+	const addr = "127.0.0.1:9092"
+	resp := func(r kmsg.Response) []byte { return r.AppendTo(nil) }
+	r, err := NewWireReplayer([]WireEntry{
+		{Addr: addr, IsResponse: true, Key: 18, Version: 3, Body: resp(&kmsg.ApiVersionsResponse{
+			Version: 3,
+			ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+				{ApiKey: 15, MaxVersion: 0},
+				{ApiKey: 17, MaxVersion: 1},
+				{ApiKey: 18, MaxVersion: 3},
+				{ApiKey: 36, MaxVersion: 1},
+				{ApiKey: 37, MaxVersion: 0},
+			},
+		})},
+		{Addr: addr, IsResponse: true, Key: 17, Version: 1, Body: resp(&kmsg.SASLHandshakeResponse{
+			Version:             1,
+			SupportedMechanisms: []string{"PLAIN"},
+		})},
+		{Addr: addr, IsResponse: true, Key: 36, Version: 1, Body: resp(&kmsg.SASLAuthenticateResponse{
+			Version: 1,
+		})},
+		{Addr: addr, IsResponse: true, Key: 15, Version: 0, Body: resp(&kmsg.DescribeGroupsResponse{})},
+	})
+	if err != nil {
+		t.Fatalf("unable to create replayer: %v", err)
+	}
+	defer r.Close()
+
+	var recording bytes.Buffer
+	cl, err := NewClient(
+		SeedBrokers(addr),
+		Dialer(r.Dial),
+		RecordWire(&recording),
+		SASL(plain.Plain(func(context.Context) (plain.Auth, error) {
+			return plain.Auth{User: "user", Pass: "pass"}, nil
+		})),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	if _, err := cl.SeedBrokers()[0].Request(context.Background(), &kmsg.DescribeGroupsRequest{Groups: []string{"g"}}); err != nil {
+		t.Fatalf("unexpected request err: %v", err)
+	}
+	cl.Close()
+
+	entries, err := ReadWireEntries(&recording)
+	if err != nil {
+		t.Fatalf("unable to read recording: %v", err)
+	}
+	for _, e := range entries {
+		if e.Key == 36 && e.Version != 1 {
+			t.Errorf("got sasl authenticate version %d, exp 1", e.Version)
+		}
+	}
+}
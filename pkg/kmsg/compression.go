@@ -0,0 +1,49 @@
+package kmsg
+
+import (
+	"github.com/twmb/kafka-go/pkg/internal/compress"
+)
+
+// CompressionCodec is a codec used to compress records in a RecordBatch or
+// messages in a message set. The value of a codec is what is encoded in the
+// low three bits of batch or message attributes.
+//
+// The zstd codec requires produce request version 7+ and cannot be used in
+// message sets.
+type CompressionCodec int8
+
+const (
+	CodecNone   CompressionCodec = 0
+	CodecGzip   CompressionCodec = 1
+	CodecSnappy CompressionCodec = 2
+	CodecLz4    CompressionCodec = 3
+	CodecZstd   CompressionCodec = 4
+)
+
+var (
+	// compressors holds a compressor at each codec's default level,
+	// indexed by codec.
+	compressors = func() []*compress.Compressor {
+		var cs []*compress.Compressor
+		for _, codec := range []compress.Codec{compress.None, compress.Gzip, compress.Snappy, compress.Lz4, compress.Zstd} {
+			c, _ := compress.NewCompressor(codec, -1) // invalid levels use the default
+			cs = append(cs, c)
+		}
+		return cs
+	}()
+
+	decompressor = compress.NewDecompressor()
+)
+
+// compress returns src compressed with the codec.
+func (c CompressionCodec) compress(src []byte) ([]byte, error) {
+	if c < 0 || int(c) >= len(compressors) {
+		return nil, compress.ErrUnknownCodec
+	}
+	return compressors[c].CompressTo(nil, src)
+}
+
+// decompress returns src decompressed with the codec.
+func (c CompressionCodec) decompress(src []byte) ([]byte, error) {
+	return decompressor.Decompress(src, compress.Codec(c))
+}
@@ -0,0 +1,412 @@
+//go:build go1.18
+// +build go1.18
+
+package kmsg
+
+import "testing"
+
+// Code generated by kafka-go/generate. DO NOT EDIT.
+
+func FuzzProduceRequest(f *testing.F) { fuzzReadFrom(f, func() message { return new(ProduceRequest) }) }
+func FuzzProduceResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ProduceResponse) })
+}
+func FuzzFetchRequest(f *testing.F)  { fuzzReadFrom(f, func() message { return new(FetchRequest) }) }
+func FuzzFetchResponse(f *testing.F) { fuzzReadFrom(f, func() message { return new(FetchResponse) }) }
+func FuzzListOffsetsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ListOffsetsRequest) })
+}
+func FuzzListOffsetsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ListOffsetsResponse) })
+}
+func FuzzMetadataRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(MetadataRequest) })
+}
+func FuzzMetadataResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(MetadataResponse) })
+}
+func FuzzLeaderAndISRRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(LeaderAndISRRequest) })
+}
+func FuzzLeaderAndISRResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(LeaderAndISRResponse) })
+}
+func FuzzStopReplicaRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(StopReplicaRequest) })
+}
+func FuzzStopReplicaResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(StopReplicaResponse) })
+}
+func FuzzUpdateMetadataRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(UpdateMetadataRequest) })
+}
+func FuzzUpdateMetadataResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(UpdateMetadataResponse) })
+}
+func FuzzControlledShutdownRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ControlledShutdownRequest) })
+}
+func FuzzControlledShutdownResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ControlledShutdownResponse) })
+}
+func FuzzOffsetCommitRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(OffsetCommitRequest) })
+}
+func FuzzOffsetCommitResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(OffsetCommitResponse) })
+}
+func FuzzOffsetFetchRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(OffsetFetchRequest) })
+}
+func FuzzOffsetFetchResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(OffsetFetchResponse) })
+}
+func FuzzFindCoordinatorRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(FindCoordinatorRequest) })
+}
+func FuzzFindCoordinatorResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(FindCoordinatorResponse) })
+}
+func FuzzJoinGroupRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(JoinGroupRequest) })
+}
+func FuzzJoinGroupResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(JoinGroupResponse) })
+}
+func FuzzHeartbeatRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(HeartbeatRequest) })
+}
+func FuzzHeartbeatResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(HeartbeatResponse) })
+}
+func FuzzLeaveGroupRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(LeaveGroupRequest) })
+}
+func FuzzLeaveGroupResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(LeaveGroupResponse) })
+}
+func FuzzSyncGroupRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(SyncGroupRequest) })
+}
+func FuzzSyncGroupResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(SyncGroupResponse) })
+}
+func FuzzDescribeGroupsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeGroupsRequest) })
+}
+func FuzzDescribeGroupsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeGroupsResponse) })
+}
+func FuzzListGroupsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ListGroupsRequest) })
+}
+func FuzzListGroupsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ListGroupsResponse) })
+}
+func FuzzSASLHandshakeRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(SASLHandshakeRequest) })
+}
+func FuzzSASLHandshakeResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(SASLHandshakeResponse) })
+}
+func FuzzApiVersionsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ApiVersionsRequest) })
+}
+func FuzzApiVersionsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ApiVersionsResponse) })
+}
+func FuzzCreateTopicsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(CreateTopicsRequest) })
+}
+func FuzzCreateTopicsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(CreateTopicsResponse) })
+}
+func FuzzDeleteTopicsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DeleteTopicsRequest) })
+}
+func FuzzDeleteTopicsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DeleteTopicsResponse) })
+}
+func FuzzDeleteRecordsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DeleteRecordsRequest) })
+}
+func FuzzDeleteRecordsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DeleteRecordsResponse) })
+}
+func FuzzInitProducerIDRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(InitProducerIDRequest) })
+}
+func FuzzInitProducerIDResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(InitProducerIDResponse) })
+}
+func FuzzOffsetForLeaderEpochRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(OffsetForLeaderEpochRequest) })
+}
+func FuzzOffsetForLeaderEpochResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(OffsetForLeaderEpochResponse) })
+}
+func FuzzAddPartitionsToTxnRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AddPartitionsToTxnRequest) })
+}
+func FuzzAddPartitionsToTxnResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AddPartitionsToTxnResponse) })
+}
+func FuzzAddOffsetsToTxnRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AddOffsetsToTxnRequest) })
+}
+func FuzzAddOffsetsToTxnResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AddOffsetsToTxnResponse) })
+}
+func FuzzEndTxnRequest(f *testing.F)  { fuzzReadFrom(f, func() message { return new(EndTxnRequest) }) }
+func FuzzEndTxnResponse(f *testing.F) { fuzzReadFrom(f, func() message { return new(EndTxnResponse) }) }
+func FuzzWriteTxnMarkersRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(WriteTxnMarkersRequest) })
+}
+func FuzzWriteTxnMarkersResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(WriteTxnMarkersResponse) })
+}
+func FuzzTxnOffsetCommitRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(TxnOffsetCommitRequest) })
+}
+func FuzzTxnOffsetCommitResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(TxnOffsetCommitResponse) })
+}
+func FuzzDescribeACLsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeACLsRequest) })
+}
+func FuzzDescribeACLsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeACLsResponse) })
+}
+func FuzzCreateACLsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(CreateACLsRequest) })
+}
+func FuzzCreateACLsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(CreateACLsResponse) })
+}
+func FuzzDeleteACLsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DeleteACLsRequest) })
+}
+func FuzzDeleteACLsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DeleteACLsResponse) })
+}
+func FuzzDescribeConfigsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeConfigsRequest) })
+}
+func FuzzDescribeConfigsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeConfigsResponse) })
+}
+func FuzzAlterConfigsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterConfigsRequest) })
+}
+func FuzzAlterConfigsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterConfigsResponse) })
+}
+func FuzzAlterReplicaLogDirsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterReplicaLogDirsRequest) })
+}
+func FuzzAlterReplicaLogDirsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterReplicaLogDirsResponse) })
+}
+func FuzzDescribeLogDirsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeLogDirsRequest) })
+}
+func FuzzDescribeLogDirsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeLogDirsResponse) })
+}
+func FuzzSASLAuthenticateRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(SASLAuthenticateRequest) })
+}
+func FuzzSASLAuthenticateResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(SASLAuthenticateResponse) })
+}
+func FuzzCreatePartitionsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(CreatePartitionsRequest) })
+}
+func FuzzCreatePartitionsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(CreatePartitionsResponse) })
+}
+func FuzzCreateDelegationTokenRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(CreateDelegationTokenRequest) })
+}
+func FuzzCreateDelegationTokenResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(CreateDelegationTokenResponse) })
+}
+func FuzzRenewDelegationTokenRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(RenewDelegationTokenRequest) })
+}
+func FuzzRenewDelegationTokenResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(RenewDelegationTokenResponse) })
+}
+func FuzzExpireDelegationTokenRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ExpireDelegationTokenRequest) })
+}
+func FuzzExpireDelegationTokenResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ExpireDelegationTokenResponse) })
+}
+func FuzzDescribeDelegationTokenRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeDelegationTokenRequest) })
+}
+func FuzzDescribeDelegationTokenResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeDelegationTokenResponse) })
+}
+func FuzzDeleteGroupsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DeleteGroupsRequest) })
+}
+func FuzzDeleteGroupsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DeleteGroupsResponse) })
+}
+func FuzzElectLeadersRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ElectLeadersRequest) })
+}
+func FuzzElectLeadersResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ElectLeadersResponse) })
+}
+func FuzzIncrementalAlterConfigsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(IncrementalAlterConfigsRequest) })
+}
+func FuzzIncrementalAlterConfigsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(IncrementalAlterConfigsResponse) })
+}
+func FuzzAlterPartitionAssignmentsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterPartitionAssignmentsRequest) })
+}
+func FuzzAlterPartitionAssignmentsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterPartitionAssignmentsResponse) })
+}
+func FuzzListPartitionReassignmentsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ListPartitionReassignmentsRequest) })
+}
+func FuzzListPartitionReassignmentsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ListPartitionReassignmentsResponse) })
+}
+func FuzzOffsetDeleteRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(OffsetDeleteRequest) })
+}
+func FuzzOffsetDeleteResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(OffsetDeleteResponse) })
+}
+func FuzzDescribeClientQuotasRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeClientQuotasRequest) })
+}
+func FuzzDescribeClientQuotasResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeClientQuotasResponse) })
+}
+func FuzzAlterClientQuotasRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterClientQuotasRequest) })
+}
+func FuzzAlterClientQuotasResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterClientQuotasResponse) })
+}
+func FuzzDescribeClusterRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeClusterRequest) })
+}
+func FuzzDescribeClusterResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeClusterResponse) })
+}
+func FuzzDescribeProducersRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeProducersRequest) })
+}
+func FuzzDescribeProducersResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeProducersResponse) })
+}
+func FuzzUnregisterBrokerRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(UnregisterBrokerRequest) })
+}
+func FuzzUnregisterBrokerResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(UnregisterBrokerResponse) })
+}
+func FuzzDescribeTransactionsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeTransactionsRequest) })
+}
+func FuzzDescribeTransactionsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeTransactionsResponse) })
+}
+func FuzzListTransactionsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ListTransactionsRequest) })
+}
+func FuzzListTransactionsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ListTransactionsResponse) })
+}
+func FuzzAllocateProducerIDsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AllocateProducerIDsRequest) })
+}
+func FuzzAllocateProducerIDsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AllocateProducerIDsResponse) })
+}
+func FuzzConsumerGroupHeartbeatRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ConsumerGroupHeartbeatRequest) })
+}
+func FuzzConsumerGroupHeartbeatResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(ConsumerGroupHeartbeatResponse) })
+}
+func FuzzDescribeUserSCRAMCredentialsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeUserSCRAMCredentialsRequest) })
+}
+func FuzzDescribeUserSCRAMCredentialsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeUserSCRAMCredentialsResponse) })
+}
+func FuzzAlterUserSCRAMCredentialsRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterUserSCRAMCredentialsRequest) })
+}
+func FuzzAlterUserSCRAMCredentialsResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterUserSCRAMCredentialsResponse) })
+}
+func FuzzVoteRequest(f *testing.F)  { fuzzReadFrom(f, func() message { return new(VoteRequest) }) }
+func FuzzVoteResponse(f *testing.F) { fuzzReadFrom(f, func() message { return new(VoteResponse) }) }
+func FuzzBeginQuorumEpochRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(BeginQuorumEpochRequest) })
+}
+func FuzzBeginQuorumEpochResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(BeginQuorumEpochResponse) })
+}
+func FuzzEndQuorumEpochRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(EndQuorumEpochRequest) })
+}
+func FuzzEndQuorumEpochResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(EndQuorumEpochResponse) })
+}
+func FuzzDescribeQuorumRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeQuorumRequest) })
+}
+func FuzzDescribeQuorumResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(DescribeQuorumResponse) })
+}
+func FuzzAlterISRRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterISRRequest) })
+}
+func FuzzAlterISRResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(AlterISRResponse) })
+}
+func FuzzUpdateFeaturesRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(UpdateFeaturesRequest) })
+}
+func FuzzUpdateFeaturesResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(UpdateFeaturesResponse) })
+}
+func FuzzEnvelopeRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(EnvelopeRequest) })
+}
+func FuzzEnvelopeResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(EnvelopeResponse) })
+}
+func FuzzFetchSnapshotRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(FetchSnapshotRequest) })
+}
+func FuzzFetchSnapshotResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(FetchSnapshotResponse) })
+}
+func FuzzBrokerRegistrationRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(BrokerRegistrationRequest) })
+}
+func FuzzBrokerRegistrationResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(BrokerRegistrationResponse) })
+}
+func FuzzBrokerHeartbeatRequest(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(BrokerHeartbeatRequest) })
+}
+func FuzzBrokerHeartbeatResponse(f *testing.F) {
+	fuzzReadFrom(f, func() message { return new(BrokerHeartbeatResponse) })
+}
+func FuzzReadRecordBatches(f *testing.F) { fuzzRead(f, func(b []byte) { ReadRecordBatches(b) }) }
+func FuzzReadV1Messages(f *testing.F)    { fuzzRead(f, func(b []byte) { ReadV1Messages(b) }) }
+func FuzzReadV0Messages(f *testing.F)    { fuzzRead(f, func(b []byte) { ReadV0Messages(b) }) }
@@ -0,0 +1,420 @@
+package kmsg
+
+import "testing"
+
+// Code generated by kafka-go/generate. DO NOT EDIT.
+
+func TestRoundTripProduceRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ProduceRequest) })
+}
+func TestRoundTripProduceResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ProduceResponse) })
+}
+func TestRoundTripFetchRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(FetchRequest) })
+}
+func TestRoundTripFetchResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(FetchResponse) })
+}
+func TestRoundTripListOffsetsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ListOffsetsRequest) })
+}
+func TestRoundTripListOffsetsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ListOffsetsResponse) })
+}
+func TestRoundTripMetadataRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(MetadataRequest) })
+}
+func TestRoundTripMetadataResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(MetadataResponse) })
+}
+func TestRoundTripLeaderAndISRRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(LeaderAndISRRequest) })
+}
+func TestRoundTripLeaderAndISRResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(LeaderAndISRResponse) })
+}
+func TestRoundTripStopReplicaRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(StopReplicaRequest) })
+}
+func TestRoundTripStopReplicaResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(StopReplicaResponse) })
+}
+func TestRoundTripUpdateMetadataRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(UpdateMetadataRequest) })
+}
+func TestRoundTripUpdateMetadataResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(UpdateMetadataResponse) })
+}
+func TestRoundTripControlledShutdownRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ControlledShutdownRequest) })
+}
+func TestRoundTripControlledShutdownResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ControlledShutdownResponse) })
+}
+func TestRoundTripOffsetCommitRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(OffsetCommitRequest) })
+}
+func TestRoundTripOffsetCommitResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(OffsetCommitResponse) })
+}
+func TestRoundTripOffsetFetchRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(OffsetFetchRequest) })
+}
+func TestRoundTripOffsetFetchResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(OffsetFetchResponse) })
+}
+func TestRoundTripFindCoordinatorRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(FindCoordinatorRequest) })
+}
+func TestRoundTripFindCoordinatorResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(FindCoordinatorResponse) })
+}
+func TestRoundTripJoinGroupRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(JoinGroupRequest) })
+}
+func TestRoundTripJoinGroupResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(JoinGroupResponse) })
+}
+func TestRoundTripHeartbeatRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(HeartbeatRequest) })
+}
+func TestRoundTripHeartbeatResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(HeartbeatResponse) })
+}
+func TestRoundTripLeaveGroupRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(LeaveGroupRequest) })
+}
+func TestRoundTripLeaveGroupResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(LeaveGroupResponse) })
+}
+func TestRoundTripSyncGroupRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(SyncGroupRequest) })
+}
+func TestRoundTripSyncGroupResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(SyncGroupResponse) })
+}
+func TestRoundTripDescribeGroupsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeGroupsRequest) })
+}
+func TestRoundTripDescribeGroupsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeGroupsResponse) })
+}
+func TestRoundTripListGroupsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ListGroupsRequest) })
+}
+func TestRoundTripListGroupsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ListGroupsResponse) })
+}
+func TestRoundTripSASLHandshakeRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(SASLHandshakeRequest) })
+}
+func TestRoundTripSASLHandshakeResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(SASLHandshakeResponse) })
+}
+func TestRoundTripApiVersionsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ApiVersionsRequest) })
+}
+func TestRoundTripApiVersionsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ApiVersionsResponse) })
+}
+func TestRoundTripCreateTopicsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(CreateTopicsRequest) })
+}
+func TestRoundTripCreateTopicsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(CreateTopicsResponse) })
+}
+func TestRoundTripDeleteTopicsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DeleteTopicsRequest) })
+}
+func TestRoundTripDeleteTopicsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DeleteTopicsResponse) })
+}
+func TestRoundTripDeleteRecordsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DeleteRecordsRequest) })
+}
+func TestRoundTripDeleteRecordsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DeleteRecordsResponse) })
+}
+func TestRoundTripInitProducerIDRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(InitProducerIDRequest) })
+}
+func TestRoundTripInitProducerIDResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(InitProducerIDResponse) })
+}
+func TestRoundTripOffsetForLeaderEpochRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(OffsetForLeaderEpochRequest) })
+}
+func TestRoundTripOffsetForLeaderEpochResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(OffsetForLeaderEpochResponse) })
+}
+func TestRoundTripAddPartitionsToTxnRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AddPartitionsToTxnRequest) })
+}
+func TestRoundTripAddPartitionsToTxnResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AddPartitionsToTxnResponse) })
+}
+func TestRoundTripAddOffsetsToTxnRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AddOffsetsToTxnRequest) })
+}
+func TestRoundTripAddOffsetsToTxnResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AddOffsetsToTxnResponse) })
+}
+func TestRoundTripEndTxnRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(EndTxnRequest) })
+}
+func TestRoundTripEndTxnResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(EndTxnResponse) })
+}
+func TestRoundTripWriteTxnMarkersRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(WriteTxnMarkersRequest) })
+}
+func TestRoundTripWriteTxnMarkersResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(WriteTxnMarkersResponse) })
+}
+func TestRoundTripTxnOffsetCommitRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(TxnOffsetCommitRequest) })
+}
+func TestRoundTripTxnOffsetCommitResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(TxnOffsetCommitResponse) })
+}
+func TestRoundTripDescribeACLsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeACLsRequest) })
+}
+func TestRoundTripDescribeACLsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeACLsResponse) })
+}
+func TestRoundTripCreateACLsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(CreateACLsRequest) })
+}
+func TestRoundTripCreateACLsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(CreateACLsResponse) })
+}
+func TestRoundTripDeleteACLsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DeleteACLsRequest) })
+}
+func TestRoundTripDeleteACLsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DeleteACLsResponse) })
+}
+func TestRoundTripDescribeConfigsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeConfigsRequest) })
+}
+func TestRoundTripDescribeConfigsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeConfigsResponse) })
+}
+func TestRoundTripAlterConfigsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterConfigsRequest) })
+}
+func TestRoundTripAlterConfigsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterConfigsResponse) })
+}
+func TestRoundTripAlterReplicaLogDirsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterReplicaLogDirsRequest) })
+}
+func TestRoundTripAlterReplicaLogDirsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterReplicaLogDirsResponse) })
+}
+func TestRoundTripDescribeLogDirsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeLogDirsRequest) })
+}
+func TestRoundTripDescribeLogDirsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeLogDirsResponse) })
+}
+func TestRoundTripSASLAuthenticateRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(SASLAuthenticateRequest) })
+}
+func TestRoundTripSASLAuthenticateResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(SASLAuthenticateResponse) })
+}
+func TestRoundTripCreatePartitionsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(CreatePartitionsRequest) })
+}
+func TestRoundTripCreatePartitionsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(CreatePartitionsResponse) })
+}
+func TestRoundTripCreateDelegationTokenRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(CreateDelegationTokenRequest) })
+}
+func TestRoundTripCreateDelegationTokenResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(CreateDelegationTokenResponse) })
+}
+func TestRoundTripRenewDelegationTokenRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(RenewDelegationTokenRequest) })
+}
+func TestRoundTripRenewDelegationTokenResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(RenewDelegationTokenResponse) })
+}
+func TestRoundTripExpireDelegationTokenRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ExpireDelegationTokenRequest) })
+}
+func TestRoundTripExpireDelegationTokenResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ExpireDelegationTokenResponse) })
+}
+func TestRoundTripDescribeDelegationTokenRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeDelegationTokenRequest) })
+}
+func TestRoundTripDescribeDelegationTokenResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeDelegationTokenResponse) })
+}
+func TestRoundTripDeleteGroupsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DeleteGroupsRequest) })
+}
+func TestRoundTripDeleteGroupsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DeleteGroupsResponse) })
+}
+func TestRoundTripElectLeadersRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ElectLeadersRequest) })
+}
+func TestRoundTripElectLeadersResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ElectLeadersResponse) })
+}
+func TestRoundTripIncrementalAlterConfigsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(IncrementalAlterConfigsRequest) })
+}
+func TestRoundTripIncrementalAlterConfigsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(IncrementalAlterConfigsResponse) })
+}
+func TestRoundTripAlterPartitionAssignmentsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterPartitionAssignmentsRequest) })
+}
+func TestRoundTripAlterPartitionAssignmentsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterPartitionAssignmentsResponse) })
+}
+func TestRoundTripListPartitionReassignmentsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ListPartitionReassignmentsRequest) })
+}
+func TestRoundTripListPartitionReassignmentsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ListPartitionReassignmentsResponse) })
+}
+func TestRoundTripOffsetDeleteRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(OffsetDeleteRequest) })
+}
+func TestRoundTripOffsetDeleteResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(OffsetDeleteResponse) })
+}
+func TestRoundTripDescribeClientQuotasRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeClientQuotasRequest) })
+}
+func TestRoundTripDescribeClientQuotasResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeClientQuotasResponse) })
+}
+func TestRoundTripAlterClientQuotasRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterClientQuotasRequest) })
+}
+func TestRoundTripAlterClientQuotasResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterClientQuotasResponse) })
+}
+func TestRoundTripDescribeClusterRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeClusterRequest) })
+}
+func TestRoundTripDescribeClusterResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeClusterResponse) })
+}
+func TestRoundTripDescribeProducersRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeProducersRequest) })
+}
+func TestRoundTripDescribeProducersResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeProducersResponse) })
+}
+func TestRoundTripUnregisterBrokerRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(UnregisterBrokerRequest) })
+}
+func TestRoundTripUnregisterBrokerResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(UnregisterBrokerResponse) })
+}
+func TestRoundTripDescribeTransactionsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeTransactionsRequest) })
+}
+func TestRoundTripDescribeTransactionsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeTransactionsResponse) })
+}
+func TestRoundTripListTransactionsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ListTransactionsRequest) })
+}
+func TestRoundTripListTransactionsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ListTransactionsResponse) })
+}
+func TestRoundTripAllocateProducerIDsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AllocateProducerIDsRequest) })
+}
+func TestRoundTripAllocateProducerIDsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AllocateProducerIDsResponse) })
+}
+func TestRoundTripConsumerGroupHeartbeatRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ConsumerGroupHeartbeatRequest) })
+}
+func TestRoundTripConsumerGroupHeartbeatResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(ConsumerGroupHeartbeatResponse) })
+}
+func TestRoundTripDescribeUserSCRAMCredentialsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeUserSCRAMCredentialsRequest) })
+}
+func TestRoundTripDescribeUserSCRAMCredentialsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeUserSCRAMCredentialsResponse) })
+}
+func TestRoundTripAlterUserSCRAMCredentialsRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterUserSCRAMCredentialsRequest) })
+}
+func TestRoundTripAlterUserSCRAMCredentialsResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterUserSCRAMCredentialsResponse) })
+}
+func TestRoundTripVoteRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(VoteRequest) })
+}
+func TestRoundTripVoteResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(VoteResponse) })
+}
+func TestRoundTripBeginQuorumEpochRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(BeginQuorumEpochRequest) })
+}
+func TestRoundTripBeginQuorumEpochResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(BeginQuorumEpochResponse) })
+}
+func TestRoundTripEndQuorumEpochRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(EndQuorumEpochRequest) })
+}
+func TestRoundTripEndQuorumEpochResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(EndQuorumEpochResponse) })
+}
+func TestRoundTripDescribeQuorumRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeQuorumRequest) })
+}
+func TestRoundTripDescribeQuorumResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(DescribeQuorumResponse) })
+}
+func TestRoundTripAlterISRRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterISRRequest) })
+}
+func TestRoundTripAlterISRResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(AlterISRResponse) })
+}
+func TestRoundTripUpdateFeaturesRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(UpdateFeaturesRequest) })
+}
+func TestRoundTripUpdateFeaturesResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(UpdateFeaturesResponse) })
+}
+func TestRoundTripEnvelopeRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(EnvelopeRequest) })
+}
+func TestRoundTripEnvelopeResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(EnvelopeResponse) })
+}
+func TestRoundTripFetchSnapshotRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(FetchSnapshotRequest) })
+}
+func TestRoundTripFetchSnapshotResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(FetchSnapshotResponse) })
+}
+func TestRoundTripBrokerRegistrationRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(BrokerRegistrationRequest) })
+}
+func TestRoundTripBrokerRegistrationResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(BrokerRegistrationResponse) })
+}
+func TestRoundTripBrokerHeartbeatRequest(t *testing.T) {
+	testRoundTrip(t, func() message { return new(BrokerHeartbeatRequest) })
+}
+func TestRoundTripBrokerHeartbeatResponse(t *testing.T) {
+	testRoundTrip(t, func() message { return new(BrokerHeartbeatResponse) })
+}
@@ -124,12 +124,61 @@ func AppendRequest(
 	clientID *string,
 ) []byte {
 	dst = append(dst, 0, 0, 0, 0) // reserve length
-	k := r.Key()
-	v := r.GetVersion()
-	dst = kbin.AppendInt16(dst, k)
-	dst = kbin.AppendInt16(dst, v)
-	dst = kbin.AppendInt32(dst, correlationID)
-	if k == 7 && v == 0 {
+	h := RequestHeader{
+		Key:           r.Key(),
+		Version:       r.GetVersion(),
+		CorrelationID: correlationID,
+		ClientID:      clientID,
+	}
+	dst = h.appendTo(dst, r.IsFlexible())
+	dst = r.AppendTo(dst) // now the request body
+
+	kbin.AppendInt32(dst[:0], int32(len(dst[4:])))
+	return dst
+}
+
+// RequestHeader is the header that begins every request, after the four byte
+// request size.
+type RequestHeader struct {
+	// Key is the key of the request that follows this header.
+	Key int16
+
+	// Version is the version of the request that follows this header.
+	Version int16
+
+	// CorrelationID is echoed in the response to this request.
+	CorrelationID int32
+
+	// ClientID is the optional client ID of the client issuing the
+	// request. This is not sent for controlled shutdown v0.
+	ClientID *string
+
+	// UnknownTags are tags in flexible request headers. Kafka currently
+	// does not define any request header tags.
+	UnknownTags Tags
+}
+
+// IsFlexible returns whether the header is a flexible header, which is true
+// if the request it is for is flexible at the header's version.
+func (h *RequestHeader) IsFlexible() bool {
+	r := RequestForKey(h.Key)
+	if r == nil {
+		return false
+	}
+	r.SetVersion(h.Version)
+	return r.IsFlexible()
+}
+
+// AppendTo appends the header to dst and returns the updated slice.
+func (h *RequestHeader) AppendTo(dst []byte) []byte {
+	return h.appendTo(dst, h.IsFlexible())
+}
+
+func (h *RequestHeader) appendTo(dst []byte, flexible bool) []byte {
+	dst = kbin.AppendInt16(dst, h.Key)
+	dst = kbin.AppendInt16(dst, h.Version)
+	dst = kbin.AppendInt32(dst, h.CorrelationID)
+	if h.Key == 7 && h.Version == 0 {
 		return dst
 	}
 
@@ -137,21 +186,80 @@ func AppendRequest(
 	// Clients issue ApiVersions immediately before knowing the broker
 	// version, and old brokers will not be able to understand a compact
 	// client id.
-	dst = kbin.AppendNullableString(dst, clientID)
+	dst = kbin.AppendNullableString(dst, h.ClientID)
 
 	// The flexible tags end the request header, and then begins the
 	// request body.
-	if r.IsFlexible() {
-		dst = append(dst, 0) // tagged section; TODO for when tags are added here
+	if flexible {
+		dst = h.UnknownTags.AppendTo(dst)
+	}
+	return dst
+}
+
+// ReadFrom parses a request header from the beginning of src, which must not
+// include the four byte request size, and returns the request body that
+// follows the header.
+//
+// Controlled shutdown v0 has no client ID and uses its own encoding for the
+// rest of the request, so this returns the remaining bytes immediately
+// after the correlation ID for it.
+func (h *RequestHeader) ReadFrom(src []byte) ([]byte, error) {
+	b := kbin.Reader{Src: src}
+	h.Key = b.Int16()
+	h.Version = b.Int16()
+	h.CorrelationID = b.Int32()
+	if h.Key == 7 && h.Version == 0 {
+		return b.Src, b.Complete()
 	}
+	h.ClientID = b.NullableString()
+	if h.IsFlexible() {
+		h.UnknownTags = ReadTags(&b)
+	}
+	return b.Src, b.Complete()
+}
 
-	// Now the request body.
-	dst = r.AppendTo(dst)
+// ResponseHeader is the header that begins every response, after the four
+// byte response size.
+type ResponseHeader struct {
+	// CorrelationID is the correlation ID of the request this response is
+	// for.
+	CorrelationID int32
 
-	kbin.AppendInt32(dst[:0], int32(len(dst[4:])))
+	// UnknownTags are tags in flexible response headers. Kafka currently
+	// does not define any response header tags.
+	UnknownTags Tags
+}
+
+// IsFlexibleResponseHeader returns whether the header of the response to the
+// given request is flexible. ApiVersions responses always use a non-flexible
+// header, even at flexible versions, so that clients can parse a response to
+// a version the broker does not support (KIP-511).
+func IsFlexibleResponseHeader(r Request) bool {
+	return r.IsFlexible() && r.Key() != 18
+}
+
+// AppendTo appends the header to dst and returns the updated slice, appending
+// tags only if the header is flexible.
+func (h *ResponseHeader) AppendTo(dst []byte, flexible bool) []byte {
+	dst = kbin.AppendInt32(dst, h.CorrelationID)
+	if flexible {
+		dst = h.UnknownTags.AppendTo(dst)
+	}
 	return dst
 }
 
+// ReadFrom parses a response header from the beginning of src, which must not
+// include the four byte response size, and returns the response body that
+// follows the header. Tags are only read if the header is flexible.
+func (h *ResponseHeader) ReadFrom(src []byte, flexible bool) ([]byte, error) {
+	b := kbin.Reader{Src: src}
+	h.CorrelationID = b.Int32()
+	if flexible {
+		h.UnknownTags = ReadTags(&b)
+	}
+	return b.Src, b.Complete()
+}
+
 // StringPtr is a helper to return a pointer to a string.
 func StringPtr(in string) *string {
 	return &in
@@ -160,6 +268,9 @@ func StringPtr(in string) *string {
 // ReadRecords reads n records from in and returns them, returning
 // kerr.ErrNotEnoughData if in does not contain enough data.
 func ReadRecords(n int, in []byte) ([]Record, error) {
+	if n < 0 || n > len(in) { // every record is at least one byte
+		return nil, kbin.ErrNotEnoughData
+	}
 	rs := make([]Record, n)
 	for i := 0; i < n; i++ {
 		length, used := kbin.Varint(in)
@@ -182,9 +293,9 @@ func ReadRecords(n int, in []byte) ([]Record, error) {
 func ReadRecordBatches(in []byte) []RecordBatch {
 	var bs []RecordBatch
 	for len(in) > 12 {
-		length := int32(binary.BigEndian.Uint32(in[8:]))
+		length := int(int32(binary.BigEndian.Uint32(in[8:])))
 		length += 12
-		if len(in) < int(length) {
+		if length < 12 || len(in) < length {
 			return bs
 		}
 		var b RecordBatch
@@ -204,9 +315,9 @@ func ReadRecordBatches(in []byte) []RecordBatch {
 func ReadV1Messages(in []byte) []MessageV1 {
 	var ms []MessageV1
 	for len(in) > 12 {
-		length := int32(binary.BigEndian.Uint32(in[8:]))
+		length := int(int32(binary.BigEndian.Uint32(in[8:])))
 		length += 12
-		if len(in) < int(length) {
+		if length < 12 || len(in) < length {
 			return ms
 		}
 		var m MessageV1
@@ -226,9 +337,9 @@ func ReadV1Messages(in []byte) []MessageV1 {
 func ReadV0Messages(in []byte) []MessageV0 {
 	var ms []MessageV0
 	for len(in) > 12 {
-		length := int32(binary.BigEndian.Uint32(in[8:]))
+		length := int(int32(binary.BigEndian.Uint32(in[8:])))
 		length += 12
-		if len(in) < int(length) {
+		if length < 12 || len(in) < length {
 			return ms
 		}
 		var m MessageV0
@@ -0,0 +1,356 @@
+package kmsg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/twmb/kafka-go/pkg/kerr"
+)
+
+// fieldInfo is the generated information for a struct field.
+type fieldInfo struct {
+	name      string
+	min, max  int  // max is -1 if unbounded; min is -1 if only tagged
+	tag       int  // -1 if not tagged
+	ignorable bool // whether the field can be dropped at versions that do not support it
+	doc       string
+}
+
+// valid returns whether the field is encoded at the given version.
+func (f fieldInfo) valid(version int16, flexible bool) bool {
+	if f.min < 0 {
+		return flexible && f.tag >= 0
+	}
+	return int(version) >= f.min && (f.max < 0 || int(version) <= f.max)
+}
+
+// marshalJSON encodes a pointer to a struct that has a Version field as a
+// JSON object, including only the fields valid at the version.
+func marshalJSON(v interface{}, version int16, flexible bool) ([]byte, error) {
+	var buf bytes.Buffer
+	rv := reflect.ValueOf(v).Elem()
+	buf.WriteString(`{"Version":`)
+	buf.WriteString(strconv.Itoa(int(version)))
+	if err := writeJSONFields(&buf, rv, version, flexible, false); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSONStruct(buf *bytes.Buffer, rv reflect.Value, version int16, flexible bool) error {
+	buf.WriteByte('{')
+	return writeJSONFields(buf, rv, version, flexible, true)
+}
+
+// writeJSONFields writes all valid fields of rv and then closes the object.
+func writeJSONFields(buf *bytes.Buffer, rv reflect.Value, version int16, flexible, first bool) error {
+	for _, f := range structFields[rv.Type().Name()] {
+		if !f.valid(version, flexible) {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.WriteString(strconv.Quote(f.name))
+		buf.WriteByte(':')
+		if err := writeJSONValue(buf, rv.FieldByName(f.name), version, flexible); err != nil {
+			return err
+		}
+	}
+
+	if tags := unknownTags(rv); tags != nil && flexible && tags.Len() > 0 {
+		if !first {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`"UnknownTags":`)
+		m := make(map[string][]byte, tags.Len())
+		tags.Each(func(key uint32, val []byte) { m[strconv.FormatUint(uint64(key), 10)] = val })
+		raw, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		buf.Write(raw)
+	}
+
+	buf.WriteByte('}')
+	return nil
+}
+
+// unknownTags returns a pointer to the UnknownTags field in an addressable
+// struct, or nil if the struct has no such field.
+func unknownTags(rv reflect.Value) *Tags {
+	f := rv.FieldByName("UnknownTags")
+	if !f.IsValid() {
+		return nil
+	}
+	return f.Addr().Interface().(*Tags)
+}
+
+func writeJSONValue(buf *bytes.Buffer, rv reflect.Value, version int16, flexible bool) error {
+	switch {
+	case rv.Kind() == reflect.Struct:
+		return writeJSONStruct(buf, rv, version, flexible)
+	case rv.Kind() == reflect.Ptr && rv.Type().Elem().Kind() == reflect.Struct:
+		if rv.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJSONStruct(buf, rv.Elem(), version, flexible)
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8:
+		if rv.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONValue(buf, rv.Index(i), version, flexible); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	default:
+		raw, err := json.Marshal(rv.Interface())
+		if err != nil {
+			return err
+		}
+		buf.Write(raw)
+		return nil
+	}
+}
+
+// unmarshalJSON decodes a JSON object into a pointer to a struct that has a
+// Version field. The version is read first, and any field that is not valid
+// at that version is ignored.
+func unmarshalJSON(b []byte, v interface{}) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v).Elem()
+	var version int16
+	if raw, ok := m["Version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return fmt.Errorf("invalid Version: %v", err)
+		}
+	}
+	rv.FieldByName("Version").SetInt(int64(version))
+
+	var flexible bool
+	if f, ok := v.(interface{ IsFlexible() bool }); ok {
+		flexible = f.IsFlexible()
+	}
+	return readJSONFields(m, rv, version, flexible)
+}
+
+func readJSONFields(m map[string]json.RawMessage, rv reflect.Value, version int16, flexible bool) error {
+	for _, f := range structFields[rv.Type().Name()] {
+		raw, ok := m[f.name]
+		if !ok || !f.valid(version, flexible) {
+			continue
+		}
+		if err := readJSONValue(raw, rv.FieldByName(f.name), version, flexible); err != nil {
+			return fmt.Errorf("%s.%s: %v", rv.Type().Name(), f.name, err)
+		}
+	}
+
+	if raw, ok := m["UnknownTags"]; ok && flexible {
+		tags := unknownTags(rv)
+		if tags == nil {
+			return nil
+		}
+		var kvs map[string][]byte
+		if err := json.Unmarshal(raw, &kvs); err != nil {
+			return fmt.Errorf("%s.UnknownTags: %v", rv.Type().Name(), err)
+		}
+		for k, val := range kvs {
+			key, err := strconv.ParseUint(k, 10, 32)
+			if err != nil {
+				return fmt.Errorf("%s.UnknownTags: invalid tag key %q", rv.Type().Name(), k)
+			}
+			tags.Set(uint32(key), val)
+		}
+	}
+	return nil
+}
+
+func readJSONValue(raw json.RawMessage, rv reflect.Value, version int16, flexible bool) error {
+	switch {
+	case rv.Kind() == reflect.Struct:
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return err
+		}
+		return readJSONFields(m, rv, version, flexible)
+	case rv.Kind() == reflect.Ptr && rv.Type().Elem().Kind() == reflect.Struct:
+		if string(raw) == "null" {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		rv.Set(reflect.New(rv.Type().Elem()))
+		return readJSONValue(raw, rv.Elem(), version, flexible)
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return err
+		}
+		if elems == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		s := reflect.MakeSlice(rv.Type(), len(elems), len(elems))
+		for i, elem := range elems {
+			if err := readJSONValue(elem, s.Index(i), version, flexible); err != nil {
+				return err
+			}
+		}
+		rv.Set(s)
+		return nil
+	default:
+		return json.Unmarshal(raw, rv.Addr().Interface())
+	}
+}
+
+// Describe returns a human readable, multi-line description of a request or
+// response, including only the fields that are valid for its version.
+//
+// Each field is annotated with the first sentence of its documentation, byte
+// slices are printed as strings if they are printable and as hex otherwise,
+// and error codes are printed with their Kafka error name.
+func Describe(m interface {
+	GetVersion() int16
+	IsFlexible() bool
+}) string {
+	rv := reflect.ValueOf(m)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	version := m.GetVersion()
+	d := describer{version: version, flexible: m.IsFlexible()}
+	fmt.Fprintf(&d.buf, "%s v%d", rv.Type().Name(), version)
+	d.fields(rv, 1)
+	return d.buf.String()
+}
+
+type describer struct {
+	buf      strings.Builder
+	version  int16
+	flexible bool
+}
+
+func (d *describer) line(depth int, format string, args ...interface{}) {
+	d.buf.WriteByte('\n')
+	d.buf.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(&d.buf, format, args...)
+}
+
+func (d *describer) fields(rv reflect.Value, depth int) {
+	for _, f := range structFields[rv.Type().Name()] {
+		if !f.valid(d.version, d.flexible) {
+			continue
+		}
+		fv := rv.FieldByName(f.name)
+		doc := ""
+		if f.doc != "" {
+			doc = "  // " + f.doc
+		}
+
+		switch {
+		case fv.Kind() == reflect.Struct:
+			d.line(depth, "%s:%s", f.name, doc)
+			d.fields(fv, depth+1)
+		case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+			if fv.IsNil() {
+				d.line(depth, "%s: null%s", f.name, doc)
+				continue
+			}
+			d.line(depth, "%s:%s", f.name, doc)
+			d.fields(fv.Elem(), depth+1)
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct:
+			if fv.IsNil() {
+				d.line(depth, "%s: null%s", f.name, doc)
+				continue
+			}
+			d.line(depth, "%s: [%d]%s", f.name, fv.Len(), doc)
+			for i := 0; i < fv.Len(); i++ {
+				d.line(depth+1, "[%d]", i)
+				d.fields(fv.Index(i), depth+2)
+			}
+		default:
+			d.line(depth, "%s: %s%s", f.name, describeValue(f.name, fv), doc)
+		}
+	}
+
+	if tags := unknownTags(rv); tags != nil && d.flexible && tags.Len() > 0 {
+		d.line(depth, "UnknownTags:")
+		tags.Each(func(key uint32, val []byte) {
+			d.line(depth+1, "%d: %s", key, describeBytes(val))
+		})
+	}
+}
+
+func describeValue(name string, rv reflect.Value) string {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "null"
+		}
+		return describeValue(name, rv.Elem())
+	case reflect.String:
+		return strconv.Quote(rv.String())
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if rv.IsNil() {
+				return "null"
+			}
+			return describeBytes(rv.Bytes())
+		}
+		if rv.IsNil() {
+			return "null"
+		}
+		elems := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			elems = append(elems, describeValue(name, rv.Index(i)))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return fmt.Sprintf("%x", b)
+		}
+	case reflect.Int16:
+		if code := int16(rv.Int()); strings.HasSuffix(name, "ErrorCode") && code != 0 {
+			return fmt.Sprintf("%d (%s)", code, describeErrorCode(code))
+		}
+	}
+	return fmt.Sprint(rv.Interface())
+}
+
+func describeErrorCode(code int16) string {
+	err, ok := kerr.ErrorForCode(code).(*kerr.Error)
+	if !ok || err.Code != code {
+		return "UNKNOWN_ERROR_CODE"
+	}
+	return err.Message
+}
+
+// describeBytes returns bytes as a quoted string if every rune is printable,
+// or as hex otherwise.
+func describeBytes(b []byte) string {
+	if utf8.Valid(b) && strings.IndexFunc(string(b), func(r rune) bool { return !unicode.IsPrint(r) }) < 0 {
+		return strconv.Quote(string(b))
+	}
+	return "0x" + hex.EncodeToString(b)
+}
@@ -0,0 +1,76 @@
+package kmsg
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	r := NewMetadataRequest()
+	r.Version = 9
+	r.Topics = []MetadataRequestTopic{{Topic: "foo"}}
+	r.IncludeTopicAuthorizedOperations = true
+	r.UnknownTags.Set(5, []byte{1})
+
+	raw, err := json.Marshal(&r)
+	if err != nil {
+		t.Fatalf("unexpected marshal err: %v", err)
+	}
+	var got MetadataRequest
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("unexpected unmarshal err: %v", err)
+	}
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("round trip mismatch:\ngot %#v\nexp %#v", got, r)
+	}
+}
+
+func TestJSONVersionGated(t *testing.T) {
+	r := MetadataRequest{
+		Version:                3,
+		Topics:                 []MetadataRequestTopic{{Topic: "foo"}},
+		AllowAutoTopicCreation: true, // v4+
+	}
+	raw, err := json.Marshal(&r)
+	if err != nil {
+		t.Fatalf("unexpected marshal err: %v", err)
+	}
+	if exp := `{"Version":3,"Topics":[{"Topic":"foo"}]}`; string(raw) != exp {
+		t.Errorf("got %s != exp %s", raw, exp)
+	}
+
+	var got MetadataRequest
+	if err := json.Unmarshal([]byte(`{"Version":3,"AllowAutoTopicCreation":true}`), &got); err != nil {
+		t.Fatalf("unexpected unmarshal err: %v", err)
+	}
+	if got.Version != 3 || got.AllowAutoTopicCreation {
+		t.Errorf("got %#v, exp v3 with AllowAutoTopicCreation unset", got)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	r := MetadataResponse{
+		Version: 1,
+		Topics: []MetadataResponseTopic{{
+			Topic:     "foo",
+			ErrorCode: 3,
+		}},
+	}
+	d := Describe(&r)
+	for _, exp := range []string{
+		"MetadataResponse v1\n",
+		"\n  Topics: [1]",
+		"\n    [0]\n",
+		"\n      ErrorCode: 3 (UNKNOWN_TOPIC_OR_PARTITION)",
+		"\n      Topic: \"foo\"",
+	} {
+		if !strings.Contains(d, exp) {
+			t.Errorf("description missing %q:\n%s", exp, d)
+		}
+	}
+	if strings.Contains(d, "ThrottleMillis") {
+		t.Errorf("description contains v3+ field at v1:\n%s", d)
+	}
+}
@@ -0,0 +1,292 @@
+package kmsg
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/twmb/kafka-go/pkg/kbin"
+)
+
+// ErrInvalidCRC is returned when reading a RecordBatch or message whose CRC
+// does not match its contents.
+var ErrInvalidCRC = errors.New("invalid crc")
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli) // record batch crc's use Castagnoli
+
+// BatchRecord is a record to be built into a RecordBatch or message set.
+type BatchRecord struct {
+	// Key is the record's key. Message sets encode a nil key as null.
+	Key []byte
+	// Value is the record's value. Message sets encode a nil value as
+	// null, which is a tombstone for compacted topics.
+	Value []byte
+	// Headers are the record's headers. Message sets do not support
+	// headers.
+	Headers []Header
+	// Timestamp is the record's timestamp in milliseconds since the unix
+	// epoch. Magic v0 messages do not support timestamps.
+	Timestamp int64
+}
+
+// BatchBuilder builds valid record batches and message sets, calculating
+// lengths, offset and timestamp deltas, and CRCs, and compressing with the
+// chosen codec.
+//
+// This is meant for tooling that writes produce requests or segment data
+// without a client; the client builds batches itself.
+type BatchBuilder struct {
+	// FirstOffset is the offset of the first record. Producers use 0.
+	FirstOffset int64
+	// PartitionLeaderEpoch is the leader epoch written to v2 batches.
+	// Producers use -1.
+	PartitionLeaderEpoch int32
+	// ProducerID is the producer ID written to v2 batches, or -1 if not
+	// idempotent.
+	ProducerID int64
+	// ProducerEpoch is the producer epoch written to v2 batches, or -1 if
+	// not idempotent.
+	ProducerEpoch int16
+	// FirstSequence is the sequence number of the first record in v2
+	// batches, or -1 if not idempotent.
+	FirstSequence int32
+	// Transactional sets the transactional bit in v2 batch attributes.
+	Transactional bool
+	// Codec is the codec to compress records with.
+	Codec CompressionCodec
+}
+
+// NewBatchBuilder returns a BatchBuilder with the defaults that producers
+// use: no compression, no leader epoch, and not idempotent.
+func NewBatchBuilder() BatchBuilder {
+	return BatchBuilder{
+		PartitionLeaderEpoch: -1,
+		ProducerID:           -1,
+		ProducerEpoch:        -1,
+		FirstSequence:        -1,
+	}
+}
+
+// RecordBatch returns a v2 RecordBatch containing the given records. The
+// first record's timestamp is the batch's FirstTimestamp, and the batch's
+// MaxTimestamp is the largest record timestamp.
+func (bb BatchBuilder) RecordBatch(records []BatchRecord) (RecordBatch, error) {
+	if len(records) == 0 {
+		return RecordBatch{}, errors.New("cannot build a record batch with no records")
+	}
+	return bb.recordBatch(records, 0)
+}
+
+// ControlBatch returns a v2 transactional control RecordBatch containing one
+// end transaction marker, which commits or aborts the producer's transaction.
+// Control batches are written by brokers; this is useful for writing segment
+// data or for testing consumers.
+//
+// Brokers never compress control batches, so this ignores the Codec.
+func (bb BatchBuilder) ControlBatch(commit bool, coordinatorEpoch int32, timestamp int64) (RecordBatch, error) {
+	var typ int16 // 0 is abort, 1 is commit
+	if commit {
+		typ = 1
+	}
+	key := kbin.AppendInt16(nil, 0) // key version
+	key = kbin.AppendInt16(key, typ)
+	value := kbin.AppendInt16(nil, 0) // value version
+	value = kbin.AppendInt32(value, coordinatorEpoch)
+
+	bb.Transactional = true
+	bb.Codec = CodecNone
+	return bb.recordBatch([]BatchRecord{{
+		Key:       key,
+		Value:     value,
+		Timestamp: timestamp,
+	}}, 0x0020) // bit 5 is the control bit
+}
+
+func (bb BatchBuilder) recordBatch(records []BatchRecord, attrs int16) (RecordBatch, error) {
+	b := RecordBatch{
+		FirstOffset:          bb.FirstOffset,
+		PartitionLeaderEpoch: bb.PartitionLeaderEpoch,
+		Magic:                2,
+		Attributes:           attrs | int16(bb.Codec),
+		LastOffsetDelta:      int32(len(records) - 1),
+		FirstTimestamp:       records[0].Timestamp,
+		MaxTimestamp:         records[0].Timestamp,
+		ProducerID:           bb.ProducerID,
+		ProducerEpoch:        bb.ProducerEpoch,
+		FirstSequence:        bb.FirstSequence,
+		NumRecords:           int32(len(records)),
+	}
+	if bb.Transactional {
+		b.Attributes |= 0x0010 // bit 4 is the transactional bit
+	}
+
+	var raw []byte
+	for i, r := range records {
+		if r.Timestamp > b.MaxTimestamp {
+			b.MaxTimestamp = r.Timestamp
+		}
+		kr := Record{
+			TimestampDelta: int32(r.Timestamp - b.FirstTimestamp),
+			OffsetDelta:    int32(i),
+			Key:            r.Key,
+			Value:          r.Value,
+			Headers:        r.Headers,
+		}
+		// The length is the encoded size of everything following it,
+		// which is everything we encode minus the one byte zero
+		// varint length we encode first.
+		kr.Length = int32(len(kr.AppendTo(nil)) - 1)
+		raw = kr.AppendTo(raw)
+	}
+
+	var err error
+	if b.Records, err = bb.Codec.compress(raw); err != nil {
+		return RecordBatch{}, err
+	}
+	b.Length = int32(49 + len(b.Records))
+	b.CRC = b.computeCRC()
+	return b, nil
+}
+
+// computeCRC returns the Castagnoli CRC of everything following the CRC
+// field in the batch.
+func (v *RecordBatch) computeCRC() int32 {
+	const crcEnd = 8 + 4 + 4 + 1 + 4 // first offset, length, leader epoch, magic, crc
+	return int32(crc32.Checksum(v.AppendTo(nil)[crcEnd:], crc32c))
+}
+
+// ReadBatchRecords validates the batch's magic and CRC, decompresses the
+// batch's records if necessary, and returns the records. Returned records may
+// alias the batch's Records if the batch is not compressed.
+func (v *RecordBatch) ReadBatchRecords() ([]Record, error) {
+	if v.Magic != 2 {
+		return nil, fmt.Errorf("unknown batch magic %d", v.Magic)
+	}
+	if v.computeCRC() != v.CRC {
+		return nil, ErrInvalidCRC
+	}
+	raw, err := CompressionCodec(v.Attributes & 0x0007).decompress(v.Records)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress batch: %v", err)
+	}
+	return ReadRecords(int(v.NumRecords), raw)
+}
+
+// MessageSet returns a magic v0 or v1 message set containing the given
+// records. If the builder has a codec, the records are compressed into a
+// single wrapper message whose offset is the offset of the last record.
+// Message sets support neither headers nor the zstd codec.
+func (bb BatchBuilder) MessageSet(magic int8, records []BatchRecord) ([]byte, error) {
+	if magic != 0 && magic != 1 {
+		return nil, fmt.Errorf("invalid message set magic %d", magic)
+	}
+	if bb.Codec == CodecZstd {
+		return nil, errors.New("zstd compression is not supported in message sets")
+	}
+
+	var raw []byte
+	for i, r := range records {
+		if len(r.Headers) > 0 {
+			return nil, errors.New("headers are not supported in message sets")
+		}
+		offset := bb.FirstOffset + int64(i)
+		if magic == 1 && bb.Codec != CodecNone {
+			offset = int64(i) // v1 inner offsets are relative
+		}
+		raw = appendMessage(raw, magic, 0, offset, r.Timestamp, r.Key, r.Value)
+	}
+	if bb.Codec == CodecNone || len(records) == 0 {
+		return raw, nil
+	}
+
+	compressed, err := bb.Codec.compress(raw)
+	if err != nil {
+		return nil, err
+	}
+	last := bb.FirstOffset + int64(len(records)-1)
+	return appendMessage(nil, magic, int8(bb.Codec), last, records[0].Timestamp, nil, compressed), nil
+}
+
+func appendMessage(dst []byte, magic, attrs int8, offset, timestamp int64, key, value []byte) []byte {
+	if magic == 0 {
+		m := MessageV0{Offset: offset, Attributes: attrs, Key: key, Value: value}
+		m.MessageSize = int32(len(m.AppendTo(nil)) - 12)
+		m.CRC = m.computeCRC()
+		return m.AppendTo(dst)
+	}
+	m := MessageV1{
+		Offset:     offset,
+		Magic:      magic,
+		Attributes: attrs,
+		Timestamp:  timestamp,
+		Key:        key,
+		Value:      value,
+	}
+	m.MessageSize = int32(len(m.AppendTo(nil)) - 12)
+	m.CRC = m.computeCRC()
+	return m.AppendTo(dst)
+}
+
+const messageCRCEnd = 8 + 4 + 4 // offset, message size, crc
+
+func (v *MessageV0) computeCRC() int32 {
+	return int32(crc32.ChecksumIEEE(v.AppendTo(nil)[messageCRCEnd:]))
+}
+
+func (v *MessageV1) computeCRC() int32 {
+	return int32(crc32.ChecksumIEEE(v.AppendTo(nil)[messageCRCEnd:]))
+}
+
+// ReadMessages validates the message's CRC and returns the messages it
+// contains: the message itself if it is not compressed, or the decompressed
+// inner messages if it is.
+func (v *MessageV0) ReadMessages() ([]MessageV0, error) {
+	if v.computeCRC() != v.CRC {
+		return nil, ErrInvalidCRC
+	}
+	codec := CompressionCodec(v.Attributes & 0x0003)
+	if codec == CodecNone {
+		return []MessageV0{*v}, nil
+	}
+	raw, err := codec.decompress(v.Value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress messages: %v", err)
+	}
+	inner := ReadV0Messages(raw)
+	for i := range inner {
+		if inner[i].computeCRC() != inner[i].CRC {
+			return nil, ErrInvalidCRC
+		}
+	}
+	return inner, nil
+}
+
+// ReadMessages validates the message's CRC and returns the messages it
+// contains: the message itself if it is not compressed, or the decompressed
+// inner messages if it is. Relative inner offsets are converted to absolute
+// offsets using the wrapper message's offset.
+func (v *MessageV1) ReadMessages() ([]MessageV1, error) {
+	if v.computeCRC() != v.CRC {
+		return nil, ErrInvalidCRC
+	}
+	codec := CompressionCodec(v.Attributes & 0x0003)
+	if codec == CodecNone {
+		return []MessageV1{*v}, nil
+	}
+	raw, err := codec.decompress(v.Value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress messages: %v", err)
+	}
+	inner := ReadV1Messages(raw)
+	if len(inner) == 0 {
+		return nil, nil
+	}
+	base := v.Offset - inner[len(inner)-1].Offset
+	for i := range inner {
+		if inner[i].computeCRC() != inner[i].CRC {
+			return nil, ErrInvalidCRC
+		}
+		inner[i].Offset += base
+	}
+	return inner, nil
+}
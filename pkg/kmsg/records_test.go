@@ -0,0 +1,140 @@
+package kmsg
+
+import (
+	"bytes"
+	"testing"
+)
+
+var testBatchRecords = []BatchRecord{
+	{Key: []byte("k0"), Value: []byte("v0"), Timestamp: 1000},
+	{Value: []byte("v1"), Headers: []Header{{Key: "h", Value: []byte("hv")}}, Timestamp: 1003},
+	{Key: []byte("k2"), Value: bytes.Repeat([]byte("v2"), 100), Timestamp: 1001},
+}
+
+func TestBuildRecordBatch(t *testing.T) {
+	for _, codec := range []CompressionCodec{CodecNone, CodecGzip, CodecSnappy, CodecLz4, CodecZstd} {
+		bb := NewBatchBuilder()
+		bb.FirstOffset = 10
+		bb.Codec = codec
+		b, err := bb.RecordBatch(testBatchRecords)
+		if err != nil {
+			t.Fatalf("codec %d: unexpected build err: %v", codec, err)
+		}
+		if b.LastOffsetDelta != 2 || b.FirstTimestamp != 1000 || b.MaxTimestamp != 1003 {
+			t.Errorf("codec %d: got unexpected batch numbers %d %d %d", codec, b.LastOffsetDelta, b.FirstTimestamp, b.MaxTimestamp)
+		}
+
+		batches := ReadRecordBatches(b.AppendTo(nil))
+		if len(batches) != 1 {
+			t.Fatalf("codec %d: got %d batches after round trip, exp 1", codec, len(batches))
+		}
+		records, err := batches[0].ReadBatchRecords()
+		if err != nil {
+			t.Fatalf("codec %d: unexpected read err: %v", codec, err)
+		}
+		if len(records) != len(testBatchRecords) {
+			t.Fatalf("codec %d: got %d records, exp %d", codec, len(records), len(testBatchRecords))
+		}
+		for i, r := range records {
+			exp := testBatchRecords[i]
+			if r.OffsetDelta != int32(i) ||
+				int64(r.TimestampDelta)+b.FirstTimestamp != exp.Timestamp ||
+				!bytes.Equal(r.Key, exp.Key) ||
+				!bytes.Equal(r.Value, exp.Value) ||
+				len(r.Headers) != len(exp.Headers) {
+				t.Errorf("codec %d: record %d mismatch: got %#v, exp %#v", codec, i, r, exp)
+			}
+		}
+	}
+}
+
+func TestBuildControlBatch(t *testing.T) {
+	bb := NewBatchBuilder()
+	bb.ProducerID, bb.ProducerEpoch = 3, 1
+	bb.Codec = CodecGzip // ignored: control batches are never compressed
+	b, err := bb.ControlBatch(true, 5, 1000)
+	if err != nil {
+		t.Fatalf("unexpected build err: %v", err)
+	}
+	if b.Attributes != 0x0030 {
+		t.Errorf("got attributes %b, exp only transactional and control bits", b.Attributes)
+	}
+	records, err := b.ReadBatchRecords()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("got %d records, err %v; exp 1 record", len(records), err)
+	}
+	if exp := []byte{0, 0, 0, 1}; !bytes.Equal(records[0].Key, exp) {
+		t.Errorf("got control key %v, exp commit %v", records[0].Key, exp)
+	}
+}
+
+func TestBuildMessageSet(t *testing.T) {
+	records := []BatchRecord{
+		{Key: []byte("k0"), Value: []byte("v0"), Timestamp: 1000},
+		{Value: []byte("v1"), Timestamp: 1001},
+	}
+	for _, magic := range []int8{0, 1} {
+		for _, codec := range []CompressionCodec{CodecNone, CodecGzip, CodecSnappy, CodecLz4} {
+			bb := NewBatchBuilder()
+			bb.FirstOffset = 10
+			bb.Codec = codec
+			raw, err := bb.MessageSet(magic, records)
+			if err != nil {
+				t.Fatalf("magic %d codec %d: unexpected build err: %v", magic, codec, err)
+			}
+
+			var offsets []int64
+			var values [][]byte
+			if magic == 0 {
+				for _, m := range ReadV0Messages(raw) {
+					inner, err := m.ReadMessages()
+					if err != nil {
+						t.Fatalf("magic 0 codec %d: unexpected read err: %v", codec, err)
+					}
+					for _, m := range inner {
+						offsets = append(offsets, m.Offset)
+						values = append(values, m.Value)
+					}
+				}
+			} else {
+				for _, m := range ReadV1Messages(raw) {
+					inner, err := m.ReadMessages()
+					if err != nil {
+						t.Fatalf("magic 1 codec %d: unexpected read err: %v", codec, err)
+					}
+					for _, m := range inner {
+						offsets = append(offsets, m.Offset)
+						values = append(values, m.Value)
+					}
+				}
+			}
+			if len(offsets) != 2 || offsets[0] != 10 || offsets[1] != 11 {
+				t.Errorf("magic %d codec %d: got offsets %v, exp [10 11]", magic, codec, offsets)
+			}
+			if len(values) == 2 && string(values[1]) != "v1" {
+				t.Errorf("magic %d codec %d: got second value %q, exp \"v1\"", magic, codec, values[1])
+			}
+		}
+	}
+}
+
+func TestReadInvalidCRC(t *testing.T) {
+	b, err := NewBatchBuilder().RecordBatch(testBatchRecords)
+	if err != nil {
+		t.Fatalf("unexpected build err: %v", err)
+	}
+	b.Records[len(b.Records)-1]++
+	if _, err := b.ReadBatchRecords(); err != ErrInvalidCRC {
+		t.Errorf("got err %v, exp ErrInvalidCRC", err)
+	}
+
+	raw, err := NewBatchBuilder().MessageSet(1, testBatchRecords[:1])
+	if err != nil {
+		t.Fatalf("unexpected build err: %v", err)
+	}
+	raw[len(raw)-1]++
+	m := ReadV1Messages(raw)[0]
+	if _, err := m.ReadMessages(); err != ErrInvalidCRC {
+		t.Errorf("got err %v, exp ErrInvalidCRC", err)
+	}
+}
@@ -0,0 +1,123 @@
+package kmsg
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// message is implemented by every request and response.
+type message interface {
+	MaxVersion() int16
+	SetVersion(int16)
+	GetVersion() int16
+	IsFlexible() bool
+	AppendTo([]byte) []byte
+	ReadFrom([]byte) error
+}
+
+// randomMessage returns a new message at the given version with every field
+// that is valid at that version set to a random value.
+func randomMessage(rng *rand.Rand, newFn func() message, version int16) message {
+	m := newFn()
+	m.SetVersion(version)
+	fillRandom(rng, reflect.ValueOf(m).Elem(), version, m.IsFlexible())
+	return m
+}
+
+// testRoundTrip checks, for every version of a message, that random values
+// survive AppendTo followed by ReadFrom.
+func testRoundTrip(t *testing.T, newFn func() message) {
+	rng := rand.New(rand.NewSource(0))
+	for version := int16(0); version <= newFn().MaxVersion(); version++ {
+		for i := 0; i < 10; i++ {
+			exp := randomMessage(rng, newFn, version)
+			raw := exp.AppendTo(nil)
+
+			got := newFn()
+			got.SetVersion(version)
+			if err := got.ReadFrom(raw); err != nil {
+				t.Fatalf("v%d: unexpected read err: %v", version, err)
+			}
+			if !reflect.DeepEqual(got, exp) {
+				t.Fatalf("v%d: round trip mismatch:\ngot %#v\nexp %#v", version, got, exp)
+			}
+		}
+	}
+}
+
+// fillRandom sets every field in the struct rv that is valid at the version
+// to a random value. Arrays, nullable strings, and nullable bytes are always
+// non-nil, since nil and empty can be indistinguishable on the wire.
+func fillRandom(rng *rand.Rand, rv reflect.Value, version int16, flexible bool) {
+	for _, f := range structFields[rv.Type().Name()] {
+		if f.valid(version, flexible) {
+			randomValue(rng, rv.FieldByName(f.name), version, flexible)
+		}
+	}
+}
+
+func randomValue(rng *rand.Rand, rv reflect.Value, version int16, flexible bool) {
+	switch rv.Kind() {
+	case reflect.Bool:
+		rv.SetBool(rng.Intn(2) == 1)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		rv.SetInt(rng.Int63() >> uint(rng.Intn(64)) * int64(1-2*rng.Intn(2)))
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		rv.SetUint(rng.Uint64() >> uint(rng.Intn(64)))
+	case reflect.Float64:
+		rv.SetFloat(rng.NormFloat64())
+	case reflect.String:
+		rv.SetString(randomString(rng))
+	case reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			randomValue(rng, rv.Index(i), version, flexible)
+		}
+	case reflect.Ptr:
+		rv.Set(reflect.New(rv.Type().Elem()))
+		randomValue(rng, rv.Elem(), version, flexible)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			rv.SetBytes([]byte(randomString(rng)))
+			return
+		}
+		n := 1 + rng.Intn(3)
+		s := reflect.MakeSlice(rv.Type(), n, n)
+		for i := 0; i < n; i++ {
+			randomValue(rng, s.Index(i), version, flexible)
+		}
+		rv.Set(s)
+	case reflect.Struct:
+		fillRandom(rng, rv, version, flexible)
+	}
+}
+
+func randomString(rng *rand.Rand) string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789-._"
+	b := make([]byte, 1+rng.Intn(10))
+	for i := range b {
+		b[i] = chars[rng.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+func TestNullableStruct(t *testing.T) {
+	for _, exp := range []*ConsumerGroupHeartbeatResponse{
+		{},
+		{Assignment: &ConsumerGroupHeartbeatResponseAssignment{
+			Topics: []ConsumerGroupHeartbeatResponseAssignmentTopic{{
+				TopicID:    [16]byte{1, 2, 3},
+				Partitions: []int32{0, 2},
+			}},
+		}},
+	} {
+		raw := exp.AppendTo(nil)
+		got := new(ConsumerGroupHeartbeatResponse)
+		if err := got.ReadFrom(raw); err != nil {
+			t.Fatalf("unexpected read err: %v", err)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("round trip mismatch:\ngot %#v\nexp %#v", got, exp)
+		}
+	}
+}
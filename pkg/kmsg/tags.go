@@ -1,6 +1,10 @@
 package kmsg
 
-import "github.com/twmb/kafka-go/pkg/kbin"
+import (
+	"sort"
+
+	"github.com/twmb/kafka-go/pkg/kbin"
+)
 
 // SkipTags skips tags in a reader.
 func SkipTags(b *kbin.Reader) {
@@ -9,3 +13,92 @@ func SkipTags(b *kbin.Reader) {
 		b.Span(int(size))
 	}
 }
+
+// ReadTags reads tags in a reader and returns the tags.
+func ReadTags(b *kbin.Reader) Tags {
+	var t Tags
+	for num := b.Uvarint(); num > 0; num-- {
+		key, size := b.Uvarint(), b.Uvarint()
+		t.Set(key, b.Span(int(size)))
+	}
+	return t
+}
+
+// Tags is an opaque structure capturing tagged fields (KIP-482) that are not
+// known to this package.
+//
+// Unknown tags are kept when reading a message and are written back when
+// appending the message, which allows proxies and recorders to pass messages
+// through without stripping fields they do not understand. Values are the raw
+// encoded bytes of each tag and may alias the slice the message was read
+// from.
+type Tags struct {
+	keyvals map[uint32][]byte
+}
+
+// Len returns the number of keyvals in Tags.
+func (t *Tags) Len() int {
+	return len(t.keyvals)
+}
+
+// Get returns the raw value for the given tag key and whether it exists.
+func (t *Tags) Get(key uint32) ([]byte, bool) {
+	val, exists := t.keyvals[key]
+	return val, exists
+}
+
+// Each calls fn for each key and val in the tags, in increasing key order.
+func (t *Tags) Each(fn func(uint32, []byte)) {
+	for _, key := range t.sortedKeys() {
+		fn(key, t.keyvals[key])
+	}
+}
+
+// Set sets or overwrites a tag.
+//
+// Known tags are parsed into their typed fields and written from those
+// fields, so setting a tag key that is known for a message results in that
+// key being written twice.
+func (t *Tags) Set(key uint32, val []byte) {
+	if t.keyvals == nil {
+		t.keyvals = make(map[uint32][]byte)
+	}
+	t.keyvals[key] = val
+}
+
+// Delete deletes a tag.
+func (t *Tags) Delete(key uint32) {
+	delete(t.keyvals, key)
+}
+
+// AppendEach appends each keyval in tags to dst in increasing key order and
+// returns the updated dst. This does not append the number of tags.
+func (t *Tags) AppendEach(dst []byte) []byte {
+	for _, key := range t.sortedKeys() {
+		val := t.keyvals[key]
+		dst = kbin.AppendUvarint(dst, key)
+		dst = kbin.AppendUvarint(dst, uint32(len(val)))
+		dst = append(dst, val...)
+	}
+	return dst
+}
+
+// AppendTo appends the number of tags followed by each keyval in tags to dst
+// and returns the updated dst. This is the full tagged field section for a
+// message that has no known tags.
+func (t *Tags) AppendTo(dst []byte) []byte {
+	dst = kbin.AppendUvarint(dst, uint32(t.Len()))
+	return t.AppendEach(dst)
+}
+
+func (t *Tags) sortedKeys() []uint32 {
+	if len(t.keyvals) == 0 {
+		return nil
+	}
+	keys := make([]uint32, 0, len(t.keyvals))
+	for key := range t.keyvals {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,124 @@
+package kmsg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/twmb/kafka-go/pkg/kbin"
+)
+
+func TestUnknownTagsRoundTrip(t *testing.T) {
+	var in []byte
+	in = kbin.AppendCompactString(in, "kgo")
+	in = kbin.AppendCompactString(in, "1.0.0")
+	in = kbin.AppendUvarint(in, 2) // two tags
+	in = kbin.AppendUvarint(in, 3) // tag 3
+	in = kbin.AppendUvarint(in, 1)
+	in = append(in, 'a')
+	in = kbin.AppendUvarint(in, 9) // tag 9
+	in = kbin.AppendUvarint(in, 2)
+	in = append(in, 'b', 'c')
+
+	r := ApiVersionsRequest{Version: 3}
+	if err := r.ReadFrom(in); err != nil {
+		t.Fatalf("unexpected read err: %v", err)
+	}
+	if r.UnknownTags.Len() != 2 {
+		t.Fatalf("got %d unknown tags, exp 2", r.UnknownTags.Len())
+	}
+	if v, _ := r.UnknownTags.Get(9); string(v) != "bc" {
+		t.Errorf("got tag 9 val %q, exp \"bc\"", v)
+	}
+	if out := r.AppendTo(nil); !bytes.Equal(out, in) {
+		t.Errorf("round trip mismatch:\ngot %v\nexp %v", out, in)
+	}
+}
+
+func TestKnownTagWithUnknownTags(t *testing.T) {
+	topic := CreateTopicsResponseTopic{Topic: "foo", ConfigErrorCode: 3}
+	topic.UnknownTags.Set(4, []byte{1, 2})
+
+	r := CreateTopicsResponse{
+		Version: 5,
+		Topics:  []CreateTopicsResponseTopic{topic},
+	}
+	raw := r.AppendTo(nil)
+
+	got := CreateTopicsResponse{Version: 5}
+	if err := got.ReadFrom(raw); err != nil {
+		t.Fatalf("unexpected read err: %v", err)
+	}
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("round trip mismatch:\ngot %#v\nexp %#v", got, r)
+	}
+}
+
+func TestRequestHeaderRoundTrip(t *testing.T) {
+	h := RequestHeader{
+		Key:           18,
+		Version:       3,
+		CorrelationID: 7,
+		ClientID:      StringPtr("client"),
+	}
+	h.UnknownTags.Set(0, []byte("x"))
+	raw := h.AppendTo(nil)
+	raw = append(raw, "body"...)
+
+	var got RequestHeader
+	body, err := got.ReadFrom(raw)
+	if err != nil {
+		t.Fatalf("unexpected read err: %v", err)
+	}
+	if string(body) != "body" {
+		t.Errorf("got body %q, exp \"body\"", body)
+	}
+	if !reflect.DeepEqual(got, h) {
+		t.Errorf("round trip mismatch:\ngot %#v\nexp %#v", got, h)
+	}
+}
+
+func TestAbsentTagsDefault(t *testing.T) {
+	// A v3 ApiVersions response from a broker without features omits
+	// FinalizedFeaturesEpoch (tag 1), which must decode as its default -1
+	// rather than 0.
+	var in []byte
+	in = kbin.AppendInt16(in, 0)           // error code
+	in = kbin.AppendCompactArrayLen(in, 1) // api keys
+	in = kbin.AppendInt16(in, 18)
+	in = kbin.AppendInt16(in, 0)
+	in = kbin.AppendInt16(in, 3)
+	in = kbin.AppendUvarint(in, 0) // api key tags
+	in = kbin.AppendInt32(in, 0)   // throttle
+	in = kbin.AppendUvarint(in, 0) // no tags
+
+	r := ApiVersionsResponse{Version: 3}
+	if err := r.ReadFrom(in); err != nil {
+		t.Fatalf("unexpected read err: %v", err)
+	}
+	if r.FinalizedFeaturesEpoch != -1 {
+		t.Errorf("got FinalizedFeaturesEpoch %d, exp -1", r.FinalizedFeaturesEpoch)
+	}
+	if len(r.ApiKeys) != 1 || r.ApiKeys[0].MaxVersion != 3 {
+		t.Errorf("unexpected api keys %+v", r.ApiKeys)
+	}
+
+	// Nested tagged structs within arrays are defaulted as well; a
+	// defaulted partition encodes without its tags.
+	fetch := FetchResponse{
+		Version: 12,
+		Topics: []FetchResponseTopic{{
+			Topic:      "foo",
+			Partitions: []FetchResponseTopicPartition{NewFetchResponseTopicPartition()},
+		}},
+	}
+	var got FetchResponse
+	got.Version = 12
+	if err := got.ReadFrom(fetch.AppendTo(nil)); err != nil {
+		t.Fatalf("unexpected fetch read err: %v", err)
+	}
+	p := got.Topics[0].Partitions[0]
+	if p.DivergingEpoch.Epoch != -1 || p.CurrentLeader.LeaderID != -1 || p.SnapshotID.EndOffset != -1 {
+		t.Errorf("absent fetch partition tags were not defaulted: %+v %+v %+v", p.DivergingEpoch, p.CurrentLeader, p.SnapshotID)
+	}
+}
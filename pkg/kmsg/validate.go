@@ -0,0 +1,82 @@
+package kmsg
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// FieldVersionError is returned from Validate when a field is set but will
+// not be encoded at the message's version.
+type FieldVersionError struct {
+	// Message is the name of the request or response being validated.
+	Message string
+	// Field is the path to the field that will be dropped, such as
+	// "Topics[0].TopicID".
+	Field string
+	// Version is the version of the message.
+	Version int16
+}
+
+func (e *FieldVersionError) Error() string {
+	return fmt.Sprintf("%s v%d: field %s is set but is not encoded at this version", e.Message, e.Version, e.Field)
+}
+
+// validate returns a *FieldVersionError for the first field in the pointer to
+// struct v that is not valid at the given version, that is not its zero or
+// default value, and that is not ignorable.
+func validate(v interface{}, version int16, flexible bool) error {
+	rv := reflect.ValueOf(v).Elem()
+	if field := invalidField(rv, version, flexible, ""); field != "" {
+		return &FieldVersionError{
+			Message: rv.Type().Name(),
+			Field:   field,
+			Version: version,
+		}
+	}
+	return nil
+}
+
+// invalidField returns the path of the first set field that would be dropped
+// when encoding rv, or an empty string if no field would be dropped.
+func invalidField(rv reflect.Value, version int16, flexible bool, path string) string {
+	def := reflect.New(rv.Type())
+	if d, ok := def.Interface().(interface{ Default() }); ok {
+		d.Default()
+	}
+	def = def.Elem()
+
+	for _, f := range structFields[rv.Type().Name()] {
+		fv := rv.FieldByName(f.name)
+		if !f.valid(version, flexible) {
+			if !f.ignorable && !reflect.DeepEqual(fv.Interface(), def.FieldByName(f.name).Interface()) {
+				return path + f.name
+			}
+			continue
+		}
+		switch {
+		case fv.Kind() == reflect.Struct:
+			if field := invalidField(fv, version, flexible, path+f.name+"."); field != "" {
+				return field
+			}
+		case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+			if !fv.IsNil() {
+				if field := invalidField(fv.Elem(), version, flexible, path+f.name+"."); field != "" {
+					return field
+				}
+			}
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct:
+			for i := 0; i < fv.Len(); i++ {
+				elemPath := path + f.name + "[" + strconv.Itoa(i) + "]."
+				if field := invalidField(fv.Index(i), version, flexible, elemPath); field != "" {
+					return field
+				}
+			}
+		}
+	}
+
+	if tags := unknownTags(rv); tags != nil && !flexible && tags.Len() > 0 {
+		return path + "UnknownTags"
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package kmsg
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	r := NewMetadataRequest() // AllowAutoTopicCreation defaults to true, v4+
+	r.Version = 3
+	r.Topics = []MetadataRequestTopic{{Topic: "foo"}}
+	if err := r.Validate(); err != nil {
+		t.Errorf("unexpected err for default v4+ field at v3: %v", err)
+	}
+
+	r.IncludeTopicAuthorizedOperations = true // v8+
+	err := r.Validate()
+	if err == nil {
+		t.Fatal("expected err for set v8+ field at v3")
+	}
+	if fe, ok := err.(*FieldVersionError); !ok || fe.Field != "IncludeTopicAuthorizedOperations" || fe.Version != 3 {
+		t.Errorf("got unexpected err %#v", err)
+	}
+
+	r.Version = 8
+	if err := r.Validate(); err != nil {
+		t.Errorf("unexpected err at v8: %v", err)
+	}
+	r.Topics[0].UnknownTags.Set(1, []byte{0}) // tags are v9+
+	if err, ok := r.Validate().(*FieldVersionError); !ok || err.Field != "Topics[0].UnknownTags" {
+		t.Errorf("got unexpected err %#v for unknown tags before flexible versions", err)
+	}
+}
+
+func TestValidateIgnorable(t *testing.T) {
+	epoch := NewOffsetForLeaderEpochRequest() // ReplicaID is v3+, default -2
+	epoch.Version = 2
+	epoch.ReplicaID = -1
+	if err := epoch.Validate(); err != nil {
+		t.Errorf("unexpected err for ignorable field at v2: %v", err)
+	}
+
+	join := NewJoinGroupRequest() // RebalanceTimeoutMillis is v1+
+	join.Version = 0
+	join.RebalanceTimeoutMillis = 60000
+	if err := join.Validate(); err != nil {
+		t.Errorf("unexpected err for ignorable field at v0: %v", err)
+	}
+}
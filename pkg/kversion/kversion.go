@@ -2,6 +2,15 @@
 //
 // Kafka technically has internal broker versions that bump multiple times per
 // release. This package only defines releases and tip.
+//
+// Releases are defined through 3.0. Later releases are not yet defined
+// because kmsg does not yet define the request versions they introduce, and
+// every table here must only use versions that kmsg can encode. Brokers from
+// a later release are guessed as "at least v3.0".
+//
+// Releases describe what a ZooKeeper based broker supports. Keys that only
+// KRaft nodes handle (such as Vote) are -1, meaning a KRaft broker will not
+// exactly match any release in VersionGuess.
 package kversion
 
 import (
@@ -28,6 +37,130 @@ func (vs Versions) String() string {
 	return buf.String()
 }
 
+// FromApiVersionsResponse returns the max versions a broker supports, as
+// reported in its ApiVersions response. Keys the broker does not support are
+// -1.
+func FromApiVersionsResponse(r *kmsg.ApiVersionsResponse) Versions {
+	var vs Versions
+	for _, key := range r.ApiKeys {
+		if key.ApiKey < 0 {
+			continue
+		}
+		for int(key.ApiKey) >= len(vs) {
+			vs = append(vs, -1)
+		}
+		vs[key.ApiKey] = key.MaxVersion
+	}
+	return vs
+}
+
+// lookup returns the max version for key, or -1 if the key is not present.
+func (vs Versions) lookup(key int) int16 {
+	if key < len(vs) {
+		return vs[key]
+	}
+	return -1
+}
+
+// atLeast returns whether vs supports every key of release at at least the
+// release's version.
+func (vs Versions) atLeast(release Versions) bool {
+	for k, v := range release {
+		if vs.lookup(k) < v {
+			return false
+		}
+	}
+	return true
+}
+
+// equal returns whether vs supports exactly the keys and versions of other.
+func (vs Versions) equal(other Versions) bool {
+	return len(vs.Diff(other)) == 0
+}
+
+var releases = []struct {
+	name string
+	vs   func() Versions
+}{
+	{"v0.8.0", V0_8_0},
+	{"v0.8.1", V0_8_1},
+	{"v0.8.2", V0_8_2},
+	{"v0.9.0", V0_9_0},
+	{"v0.10.0", V0_10_0},
+	{"v0.10.1", V0_10_1},
+	{"v0.10.2", V0_10_2},
+	{"v0.11.0", V0_11_0},
+	{"v1.0", V1_0_0},
+	{"v1.1", V1_1_0},
+	{"v2.0", V2_0_0},
+	{"v2.1", V2_1_0},
+	{"v2.2", V2_2_0},
+	{"v2.3", V2_3_0},
+	{"v2.4", V2_4_0},
+	{"v2.5", V2_5_0},
+	{"v2.6", V2_6_0},
+	{"v2.7", V2_7_0},
+	{"v2.8", V2_8_0},
+	{"v3.0", V3_0_0},
+}
+
+// VersionGuess returns the closest known Kafka release for these versions,
+// such as "v2.4" if the versions exactly match 2.4, "between v2.4 and v2.5"
+// if the versions support everything in 2.4 but not everything in 2.5, or
+// "at least v2.5" if the versions support everything in the latest known
+// release. This returns "unknown" if the versions do not support even 0.8.0.
+//
+// This is most useful with versions from FromApiVersionsResponse. Brokers
+// built from Kafka trunk, or forks of Kafka, may not exactly match any
+// release.
+func (vs Versions) VersionGuess() string {
+	last := -1
+	for i, release := range releases {
+		if !vs.atLeast(release.vs()) {
+			break
+		}
+		last = i
+	}
+	switch {
+	case last < 0:
+		return "unknown"
+	case vs.equal(releases[last].vs()):
+		return releases[last].name
+	case last == len(releases)-1:
+		return "at least " + releases[last].name
+	default:
+		return "between " + releases[last].name + " and " + releases[last+1].name
+	}
+}
+
+// VersionDiff is a difference in max version for a single key between two
+// Versions. A version of -1 means the key is not supported.
+type VersionDiff struct {
+	Key   int16
+	Ours  int16
+	Other int16
+}
+
+func (d VersionDiff) String() string {
+	return fmt.Sprintf("%s: %d != %d", kmsg.NameForKey(d.Key), d.Ours, d.Other)
+}
+
+// Diff returns every key whose max version differs between vs and other, in
+// key order. Ours in each difference is the version in vs.
+func (vs Versions) Diff(other Versions) []VersionDiff {
+	n := len(vs)
+	if len(other) > n {
+		n = len(other)
+	}
+	var diffs []VersionDiff
+	for k := 0; k < n; k++ {
+		if ours, theirs := vs.lookup(k), other.lookup(k); ours != theirs {
+			diffs = append(diffs, VersionDiff{int16(k), ours, theirs})
+		}
+	}
+	return diffs
+}
+
 func V0_8_0() Versions {
 	v := []int16{
 		0, // 0 produce
@@ -361,8 +494,7 @@ func V2_5_0() Versions {
 	return v
 }
 
-// Tip is the latest defined Kafka key versions; this may be slightly out of date.
-func Tip() Versions {
+func V2_6_0() Versions {
 	v := V2_5_0()
 
 	v[21]++ // 2 delete records KAFKA-8768 f869e33ab KIP-482 (opportunistic bump for exlible versions)
@@ -380,3 +512,100 @@ func Tip() Versions {
 
 	return v
 }
+
+func V2_7_0() Versions {
+	v := V2_6_0()
+
+	v[19]++ // 6 create topics KIP-599
+	v[20]++ // 5 delete topics (same)
+	v[37]++ // 3 create partitions (same)
+
+	v[22]++ // 4 init producer id KIP-588
+	v[24]++ // 2 add partitions to txn (same)
+	v[25]++ // 2 add offsets to txn (same)
+	v[26]++ // 2 end txn (same)
+
+	v[1]++ // 12 fetch KIP-595 (flexible versions, diverging epochs)
+
+	v = append(v,
+		0,  // 50 describe user scram credentials KIP-554
+		0,  // 51 alter user scram credentials (same)
+		-1, // 52 vote KIP-595 (raft only)
+		-1, // 53 begin quorum epoch (same)
+		-1, // 54 end quorum epoch (same)
+		-1, // 55 describe quorum (same)
+		0,  // 56 alter isr KIP-497
+		0,  // 57 update features KIP-584
+	)
+
+	return v
+}
+
+func V2_8_0() Versions {
+	v := V2_7_0()
+
+	// flexible versions for the remaining requests, KIP-482
+	v[0]++  // 9 produce
+	v[2]++  // 6 list offsets
+	v[23]++ // 4 offset for leader epoch
+	v[24]++ // 3 add partitions to txn
+	v[25]++ // 3 add offsets to txn
+	v[26]++ // 3 end txn
+	v[27]++ // 1 write txn markers
+	v[32]++ // 4 describe configs
+	v[33]++ // 2 alter configs
+	v[34]++ // 2 alter replica log dirs
+	v[48]++ // 1 describe client quotas
+	v[49]++ // 1 alter client quotas
+
+	v[3]++  // 10 metadata KIP-516 (topic IDs)
+	v[4]++  // 5 leader and isr (same)
+	v[6]++  // 7 update metadata (same)
+	v[19]++ // 7 create topics (same)
+	v[20]++ // 6 delete topics (same)
+
+	v[3]++ // 11 metadata KIP-700 (cluster authorized operations moved to describe cluster)
+
+	v = append(v,
+		-1, // 58 envelope KIP-590 (raft only)
+		-1, // 59 fetch snapshot KIP-630 (same)
+		0,  // 60 describe cluster KIP-700
+		0,  // 61 describe producers KIP-664
+		-1, // 62 broker registration KIP-631 (raft only)
+		-1, // 63 broker heartbeat (same)
+		-1, // 64 unregister broker (same)
+	)
+
+	return v
+}
+
+func V3_0_0() Versions {
+	v := V2_8_0()
+
+	v[2]++  // 7 list offsets KIP-734
+	v[9]++  // 8 offset fetch KIP-709
+	v[10]++ // 4 find coordinator KIP-699
+
+	v = append(v,
+		0, // 65 describe transactions KIP-664
+		0, // 66 list transactions (same)
+		0, // 67 allocate producer ids KIP-730
+	)
+
+	return v
+}
+
+// Tip is the latest defined Kafka key versions; this may be slightly out of date.
+//
+// Tip matches the max versions that kmsg defines. It only adds keys that were
+// introduced after 3.0.0; see the package documentation for why later
+// releases are not defined.
+func Tip() Versions {
+	v := V3_0_0()
+
+	v = append(v,
+		0, // 68 consumer group heartbeat KIP-848
+	)
+
+	return v
+}
@@ -0,0 +1,82 @@
+package kversion
+
+import (
+	"testing"
+
+	"github.com/twmb/kafka-go/pkg/kmsg"
+)
+
+func TestFromApiVersionsResponse(t *testing.T) {
+	vs := FromApiVersionsResponse(&kmsg.ApiVersionsResponse{
+		ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+			{ApiKey: 0, MaxVersion: 8},
+			{ApiKey: 3, MaxVersion: 9},
+		},
+	})
+	exp := Versions{8, -1, -1, 9}
+	if diffs := vs.Diff(exp); len(diffs) != 0 {
+		t.Errorf("got diffs %v against %v", diffs, exp)
+	}
+}
+
+func TestVersionGuess(t *testing.T) {
+	between := V2_4_0()
+	between[22]++ // init producer id bumped in 2.5
+
+	beyond := V3_0_0()
+	beyond = append(beyond, 0)
+
+	noFetch := V0_8_0()
+	noFetch[1] = -1
+
+	for _, test := range []struct {
+		vs  Versions
+		exp string
+	}{
+		{V0_8_0(), "v0.8.0"},
+		{V2_4_0(), "v2.4"},
+		{V2_5_0(), "v2.5"},
+		{between, "between v2.4 and v2.5"},
+		{V3_0_0(), "v3.0"},
+		{beyond, "at least v3.0"},
+		{noFetch, "unknown"},
+	} {
+		if got := test.vs.VersionGuess(); got != test.exp {
+			t.Errorf("got guess %q != exp %q", got, test.exp)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	diffs := Versions{1, 2, 3}.Diff(Versions{1, 3})
+	exp := []VersionDiff{
+		{Key: 1, Ours: 2, Other: 3},
+		{Key: 2, Ours: 3, Other: -1},
+	}
+	if len(diffs) != len(exp) {
+		t.Fatalf("got diffs %v != exp %v", diffs, exp)
+	}
+	for i := range diffs {
+		if diffs[i] != exp[i] {
+			t.Errorf("diff %d: got %v != exp %v", i, diffs[i], exp[i])
+		}
+	}
+}
+
+func TestTipMatchesKmsg(t *testing.T) {
+	tip := Tip()
+	if len(tip) != kmsg.MaxKey+1 {
+		t.Errorf("got %d tip keys != exp %d", len(tip), kmsg.MaxKey+1)
+	}
+	for k, v := range tip {
+		req := kmsg.RequestForKey(int16(k))
+		if req == nil {
+			t.Errorf("key %d: missing from kmsg", k)
+			continue
+		}
+		// Keys that brokers do not advertise (-1) can be at any version.
+		if v >= 0 && req.MaxVersion() != v {
+			t.Errorf("key %d: got kmsg max version %d != exp %d", k, req.MaxVersion(), v)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package scram
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/twmb/kafka-go/pkg/kerr"
+	"github.com/twmb/kafka-go/pkg/kmsg"
+)
+
+// Requestor issues Kafka requests. A *kgo.Client satisfies this interface.
+type Requestor interface {
+	Request(context.Context, kmsg.Request) (kmsg.Response, error)
+}
+
+// ErrTokenExpired is returned from a DelegationToken's AuthFn if the token
+// could not be renewed or recreated before it expired.
+var ErrTokenExpired = errors.New("delegation token has expired and could not be renewed")
+
+// ErrTokenClosed is returned from a DelegationToken's AuthFn once the token
+// has been closed.
+var ErrTokenClosed = errors.New("delegation token has been closed")
+
+// DelegationToken manages the lifecycle of a Kafka delegation token (KIP-48).
+//
+// A token is created with a privileged client, and then renewed in the
+// background before it expires. Once a token cannot be renewed further (its
+// expiry has hit its max timestamp), a new token is created to replace it.
+//
+// The token is meant to be handed to worker clients through AuthFn, which can
+// be used with Sha256 or Sha512. Workers then never need to see long-term
+// credentials; they only see the current token.
+//
+// Note that the privileged client must itself not be authenticated with a
+// delegation token: Kafka does not allow token requests over token
+// authenticated connections.
+type DelegationToken struct {
+	cl          Requestor
+	renewers    []kmsg.CreateDelegationTokenRequestRenewer
+	maxLifetime time.Duration
+	clock       clock
+
+	mu      sync.RWMutex
+	id      string
+	hmac    []byte
+	expiry  time.Time
+	max     time.Time
+	lastErr error
+	closed  bool
+
+	ctx    context.Context
+	cancel func()
+	done   chan struct{}
+}
+
+// NewDelegationToken creates a delegation token with the given privileged
+// client and begins renewing it in the background.
+//
+// The maxLifetime is the lifetime requested for every created token; if zero
+// or negative, Kafka's delegation.token.max.lifetime.ms is used. Renewers are
+// "User" principal names that are allowed to renew the token; if empty, only
+// the principal of the privileged client can renew it.
+//
+// The context is only used for the initial token creation.
+func NewDelegationToken(
+	ctx context.Context,
+	cl Requestor,
+	maxLifetime time.Duration,
+	renewers ...string,
+) (*DelegationToken, error) {
+	return newDelegationToken(ctx, cl, maxLifetime, wallClock{}, renewers...)
+}
+
+func newDelegationToken(
+	ctx context.Context,
+	cl Requestor,
+	maxLifetime time.Duration,
+	clock clock,
+	renewers ...string,
+) (*DelegationToken, error) {
+	t := &DelegationToken{
+		cl:          cl,
+		maxLifetime: maxLifetime,
+		clock:       clock,
+		done:        make(chan struct{}),
+	}
+	for _, renewer := range renewers {
+		t.renewers = append(t.renewers, kmsg.CreateDelegationTokenRequestRenewer{
+			PrincipalType: "User",
+			PrincipalName: renewer,
+		})
+	}
+	if err := t.create(ctx); err != nil {
+		return nil, err
+	}
+
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	go t.manage()
+	return t, nil
+}
+
+// AuthFn returns a function that returns the current token as a SCRAM Auth.
+// The returned function is meant to be used with Sha256 or Sha512.
+//
+// If the token expired because renewing or recreating it repeatedly failed,
+// the function returns ErrTokenExpired wrapping the last error.
+func (t *DelegationToken) AuthFn() func(context.Context) (Auth, error) {
+	return func(context.Context) (Auth, error) {
+		t.mu.RLock()
+		defer t.mu.RUnlock()
+
+		if t.closed {
+			return Auth{}, ErrTokenClosed
+		}
+		if t.clock.Now().After(t.expiry) {
+			if t.lastErr != nil {
+				return Auth{}, fmt.Errorf("%w: %v", ErrTokenExpired, t.lastErr)
+			}
+			return Auth{}, ErrTokenExpired
+		}
+		// Kafka registers the token's SCRAM credential from the base64
+		// encoding of the HMAC, not the raw bytes.
+		return Auth{
+			User:    t.id,
+			Pass:    base64.StdEncoding.EncodeToString(t.hmac),
+			IsToken: true,
+		}, nil
+	}
+}
+
+// TokenID returns the ID of the current token.
+func (t *DelegationToken) TokenID() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.id
+}
+
+// Expiry returns when the current token expires, absent further renewal.
+func (t *DelegationToken) Expiry() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.expiry
+}
+
+// Close stops renewing the token and expires it on the broker. The context
+// is used for the expire request.
+//
+// After Close, AuthFn returns ErrTokenClosed. Connections that have already
+// authenticated with the token are not closed by this.
+func (t *DelegationToken) Close(ctx context.Context) error {
+	t.cancel()
+	<-t.done
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	hmac := t.hmac
+	t.mu.Unlock()
+
+	kresp, err := t.cl.Request(ctx, &kmsg.ExpireDelegationTokenRequest{
+		HMAC:               hmac,
+		ExpiryPeriodMillis: -1, // expire immediately
+	})
+	if err != nil {
+		return err
+	}
+	resp := kresp.(*kmsg.ExpireDelegationTokenResponse)
+	return kerr.ErrorForCode(resp.ErrorCode)
+}
+
+// create creates a new delegation token, replacing the current one.
+func (t *DelegationToken) create(ctx context.Context) error {
+	maxLifetimeMillis := int64(-1)
+	if t.maxLifetime > 0 {
+		maxLifetimeMillis = t.maxLifetime.Milliseconds()
+	}
+	kresp, err := t.cl.Request(ctx, &kmsg.CreateDelegationTokenRequest{
+		Renewers:          t.renewers,
+		MaxLifetimeMillis: maxLifetimeMillis,
+	})
+	if err != nil {
+		return err
+	}
+	resp := kresp.(*kmsg.CreateDelegationTokenResponse)
+	if err = kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.id = resp.TokenID
+	t.hmac = resp.HMAC
+	t.expiry = millisToTime(resp.ExpiryTimestamp)
+	t.max = millisToTime(resp.MaxTimestamp)
+	t.lastErr = nil
+	return nil
+}
+
+// renew renews the current delegation token, returning whether the renewal
+// extended the token's expiry.
+func (t *DelegationToken) renew(ctx context.Context) (bool, error) {
+	t.mu.RLock()
+	hmac, prior := t.hmac, t.expiry
+	t.mu.RUnlock()
+
+	kresp, err := t.cl.Request(ctx, &kmsg.RenewDelegationTokenRequest{
+		HMAC:            hmac,
+		RenewTimeMillis: -1, // Kafka's delegation.token.expiry.time.ms
+	})
+	if err != nil {
+		return false, err
+	}
+	resp := kresp.(*kmsg.RenewDelegationTokenResponse)
+	if err = kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return false, err
+	}
+
+	expiry := millisToTime(resp.ExpiryTimestamp)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expiry = expiry
+	t.lastErr = nil
+	return expiry.After(prior), nil
+}
+
+// manage renews or recreates the token in a loop until the token is closed.
+//
+// We act once 80% of the time until expiry has elapsed, which leaves a large
+// enough window to retry failures. If the token's expiry has reached its max
+// timestamp, renewing does nothing, so we create a new token instead.
+func (t *DelegationToken) manage() {
+	defer close(t.done)
+
+	var failures int
+	for {
+		t.mu.RLock()
+		expiry, max := t.expiry, t.max
+		t.mu.RUnlock()
+
+		until := expiry.Sub(t.clock.Now())
+		wait := until * 4 / 5
+		if failures > 0 {
+			wait = tokenRetryBackoff(failures)
+			if until > 0 && wait > until {
+				wait = until
+			}
+		}
+
+		fired, stop := t.clock.After(wait)
+		select {
+		case <-t.ctx.Done():
+			stop()
+			return
+		case <-fired:
+		}
+
+		var err error
+		if !expiry.Before(max) {
+			err = t.create(t.ctx)
+		} else {
+			var extended bool
+			extended, err = t.renew(t.ctx)
+			switch {
+			case err == nil && !extended,
+				err == kerr.DelegationTokenExpired,
+				err == kerr.DelegationTokenNotFound:
+				err = t.create(t.ctx)
+			}
+		}
+
+		if err == nil {
+			failures = 0
+			continue
+		}
+		if t.ctx.Err() != nil {
+			return
+		}
+		failures++
+		t.mu.Lock()
+		t.lastErr = err
+		t.mu.Unlock()
+	}
+}
+
+// clock abstracts time for managing tokens so that tests can control it.
+type clock interface {
+	Now() time.Time
+	// After is time.After, but also returns a function to stop the
+	// underlying timer.
+	After(time.Duration) (<-chan time.Time, func() bool)
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time { return time.Now() }
+
+func (wallClock) After(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+func tokenRetryBackoff(failures int) time.Duration {
+	const (
+		min = time.Second
+		max = 30 * time.Second
+	)
+	if failures > 5 {
+		return max
+	}
+	return min << (failures - 1)
+}
+
+func millisToTime(millis int64) time.Time {
+	return time.Unix(0, millis*1e6)
+}
@@ -0,0 +1,175 @@
+package scram
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/kafka-go/pkg/kmsg"
+)
+
+// fakeClock is a clock that only moves when a test advances it.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits chan fakeWait
+}
+
+type fakeWait struct {
+	d     time.Duration
+	fired chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Unix(1600000000, 0),
+		waits: make(chan fakeWait, 1),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) (<-chan time.Time, func() bool) {
+	w := fakeWait{d, make(chan time.Time, 1)}
+	c.waits <- w
+	return w.fired, func() bool { return true }
+}
+
+// advance waits for the token to begin waiting, checks how long it is
+// waiting for, and then moves time forward by that long to wake it.
+func (c *fakeClock) advance(t *testing.T, exp time.Duration) {
+	t.Helper()
+	w := c.nextWait(t)
+	if w.d != exp {
+		t.Errorf("got wait %v != exp %v", w.d, exp)
+	}
+	c.mu.Lock()
+	c.now = c.now.Add(w.d)
+	now := c.now
+	c.mu.Unlock()
+	w.fired <- now
+}
+
+// settle waits for the token to begin waiting again, which means it has
+// finished acting on the prior advance, and returns how long it is waiting.
+func (c *fakeClock) settle(t *testing.T) time.Duration {
+	t.Helper()
+	w := c.nextWait(t)
+	c.waits <- w // put it back for the next advance
+	return w.d
+}
+
+func (c *fakeClock) nextWait(t *testing.T) fakeWait {
+	t.Helper()
+	select {
+	case w := <-c.waits:
+		return w
+	case <-time.After(10 * time.Second):
+		t.Fatal("token never waited")
+		return fakeWait{}
+	}
+}
+
+type fakeTokenBroker struct {
+	clock    *fakeClock
+	mu       sync.Mutex
+	created  int
+	renewed  int
+	expired  [][]byte
+	lifetime time.Duration
+	max      time.Duration
+}
+
+func (f *fakeTokenBroker) Request(_ context.Context, req kmsg.Request) (kmsg.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.clock.Now()
+	switch t := req.(type) {
+	case *kmsg.CreateDelegationTokenRequest:
+		f.created++
+		return &kmsg.CreateDelegationTokenResponse{
+			TokenID:         "token" + string(rune('0'+f.created)),
+			HMAC:            []byte{byte(f.created)},
+			ExpiryTimestamp: now.Add(f.lifetime).UnixNano() / 1e6,
+			MaxTimestamp:    now.Add(f.max).UnixNano() / 1e6,
+		}, nil
+	case *kmsg.RenewDelegationTokenRequest:
+		f.renewed++
+		return &kmsg.RenewDelegationTokenResponse{
+			ExpiryTimestamp: now.Add(f.lifetime).UnixNano() / 1e6,
+		}, nil
+	case *kmsg.ExpireDelegationTokenRequest:
+		f.expired = append(f.expired, t.HMAC)
+		return &kmsg.ExpireDelegationTokenResponse{}, nil
+	}
+	panic("unexpected request")
+}
+
+func TestDelegationToken(t *testing.T) {
+	clock := newFakeClock()
+	f := &fakeTokenBroker{clock: clock, lifetime: time.Hour, max: 24 * time.Hour}
+	tok, err := newDelegationToken(context.Background(), f, 0, clock, "worker")
+	if err != nil {
+		t.Fatalf("unexpected create err: %v", err)
+	}
+
+	auth, err := tok.AuthFn()(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected auth err: %v", err)
+	}
+	if auth.User != "token1" || auth.Pass != "AQ==" || !auth.IsToken {
+		t.Errorf("unexpected auth %+v", auth)
+	}
+
+	// We renew at 80% of the lifetime, twice, which takes us past the
+	// original expiry.
+	clock.advance(t, 48*time.Minute)
+	clock.advance(t, 48*time.Minute)
+	clock.settle(t)
+	if _, err := tok.AuthFn()(context.Background()); err != nil {
+		t.Errorf("token expired despite renewing: %v", err)
+	}
+
+	if err := tok.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close err: %v", err)
+	}
+	if _, err := tok.AuthFn()(context.Background()); err != ErrTokenClosed {
+		t.Errorf("got err %v != exp ErrTokenClosed", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.created != 1 || f.renewed != 2 || len(f.expired) != 1 {
+		t.Errorf("unexpected create/renew/expire counts %d/%d/%d", f.created, f.renewed, len(f.expired))
+	}
+}
+
+func TestDelegationTokenRecreatesAtMax(t *testing.T) {
+	clock := newFakeClock()
+	f := &fakeTokenBroker{clock: clock, lifetime: time.Hour, max: time.Hour}
+	tok, err := newDelegationToken(context.Background(), f, 0, clock)
+	if err != nil {
+		t.Fatalf("unexpected create err: %v", err)
+	}
+	defer tok.Close(context.Background())
+
+	clock.advance(t, 48*time.Minute)
+	if wait := clock.settle(t); wait != 48*time.Minute {
+		t.Errorf("got wait %v != exp 48m for the recreated token", wait)
+	}
+	if id := tok.TokenID(); id != "token2" {
+		t.Errorf("got token %q != exp token2 recreated after hitting its max timestamp", id)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.created != 2 || f.renewed != 0 {
+		t.Errorf("unexpected create/renew counts %d/%d", f.created, f.renewed)
+	}
+}